@@ -0,0 +1,159 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultAsyncQueueSize for the queue used by PublishAsync. Used when the AsyncQueueSize is not configured.
+const DefaultAsyncQueueSize = 100
+
+// PublishFuture can be used to wait for an event published by PublishAsync to be delivered.
+type PublishFuture[Event any] struct {
+	done   chan struct{}
+	err    error
+	report *PublishReport[Event]
+}
+
+type asyncPublisher[Event any] struct {
+	once  sync.Once
+	queue chan *asyncPublication[Event]
+	wg    sync.WaitGroup
+
+	mu       sync.RWMutex
+	isClosed bool
+}
+
+type asyncPublication[Event any] struct {
+	event     Event
+	topicKeys []string
+	future    *PublishFuture[Event]
+}
+
+// PublishAsync queues the provided event to be sent to all of the listed topics by the bus's dispatcher goroutines.
+// All subscriptions to those topics will be notified of the event.
+//
+// PublishAsync only blocks while the queue is full.
+// The returned future can be used to wait for the event to be delivered.
+func (b *EventBus[Event]) PublishAsync(event Event, topicKeys ...string) *PublishFuture[Event] {
+	future := &PublishFuture[Event]{done: make(chan struct{})}
+
+	b.async.mu.RLock()
+	defer b.async.mu.RUnlock()
+
+	if b.async.isClosed {
+		future.complete(ErrBusClosed)
+		return future
+	}
+
+	b.async.once.Do(b.startDispatchers)
+
+	b.async.queue <- &asyncPublication[Event]{
+		event:     event,
+		topicKeys: append([]string(nil), topicKeys...), // The caller may reuse the slice once we return
+		future:    future,
+	}
+
+	return future
+}
+
+// Done returns a channel that is closed once the event has been delivered to all subscriptions,
+// or once it is known that the event could not be published.
+func (f *PublishFuture[Event]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the event has been delivered to all subscriptions, or until the context is done.
+// It returns ErrBusClosed if the bus was closed before the event could be queued,
+// or the context's error if the context is done first.
+func (f *PublishFuture[Event]) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Report describes the delivery of the event.
+// It returns nil until the event has been delivered, or if the event could not be published.
+func (f *PublishFuture[Event]) Report() *PublishReport[Event] {
+	select {
+	case <-f.done:
+		return f.report
+	default:
+		return nil
+	}
+}
+
+func (f *PublishFuture[Event]) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+func (b *EventBus[Event]) startDispatchers() {
+	b.async.queue = make(chan *asyncPublication[Event], b.asyncQueueSizeOrDefault())
+
+	dispatchers := b.asyncDispatchersOrDefault()
+	b.async.wg.Add(dispatchers)
+
+	for i := 0; i < dispatchers; i++ {
+		go func() {
+			defer b.async.wg.Done()
+
+			for publication := range b.async.queue {
+				report := &PublishReport[Event]{}
+				b.publish(publication.event, publication.topicKeys, b.publishTimeout(), report)
+
+				publication.future.report = report
+				publication.future.complete(nil)
+			}
+		}()
+	}
+}
+
+func (b *EventBus[Event]) closeAsync() {
+	b.async.mu.Lock()
+
+	if b.async.isClosed {
+		b.async.mu.Unlock()
+		return
+	}
+
+	b.async.isClosed = true
+
+	// Closing the queue stops the dispatchers once they finish delivering the queued events
+	if b.async.queue != nil {
+		close(b.async.queue)
+	}
+
+	b.async.mu.Unlock()
+
+	b.async.wg.Wait()
+}
+
+// asyncQueueDepth returns the number of events waiting in the queue used by PublishAsync.
+func (b *EventBus[Event]) asyncQueueDepth() int {
+	b.async.mu.RLock()
+	defer b.async.mu.RUnlock()
+
+	return len(b.async.queue)
+}
+
+func (b *EventBus[Event]) asyncQueueSizeOrDefault() int {
+	if b.rawAsyncQueueSize == 0 {
+		return DefaultAsyncQueueSize
+	} else if b.rawAsyncQueueSize < 0 {
+		return 0
+	}
+
+	return b.rawAsyncQueueSize
+}
+
+func (b *EventBus[Event]) asyncDispatchersOrDefault() int {
+	if b.rawAsyncDispatchers < 1 {
+		return 1
+	}
+
+	return b.rawAsyncDispatchers
+}
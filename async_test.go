@@ -0,0 +1,115 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestPublishAsync(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("delivers the events in order with the default configuration", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		defer bus.Close()
+
+		sub := bus.Subscribe("key1", "key2")
+		buf := bufferSubscription(sub, 3)
+
+		bus.PublishAsync("1", "key1")
+		bus.PublishAsync("2", "key2", "key1")
+		future := bus.PublishAsync("3", "key2")
+
+		ensure(future.Wait(context.Background())).IsNotError()
+		ensure(buf.events()).Equals([]string{"1", "2", "3"})
+	})
+
+	ensure.Run("the future reports the delivery", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: -1, // Sets the buffer to zero, so delivery waits for the receiver
+		})
+		defer bus.Close()
+
+		sub := bus.Subscribe("key1", "key2")
+		future := bus.PublishAsync("1", "key1", "key2")
+		ensure(future.Report()).IsNil()
+
+		ensure(<-sub.Channel()).Equals("1")
+		ensure(future.Wait(context.Background())).IsNotError()
+		ensure(future.Report()).Equals(&eventbus.PublishReport[string]{
+			MatchedTopics: []string{"key1", "key2"},
+			Delivered:     1,
+			Deduplicated:  1,
+		})
+	})
+
+	ensure.Run("delivers all events with multiple dispatchers", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[int](&eventbus.Config{
+			AsyncQueueSize:   -1, // Sets the queue to have no buffer
+			AsyncDispatchers: 4,
+		})
+		defer bus.Close()
+
+		const numEvents = 100
+
+		sub := bus.Subscribe("key1")
+		buf := bufferSubscription(sub, numEvents)
+
+		futures := []*eventbus.PublishFuture[int]{}
+		for i := 0; i < numEvents; i++ {
+			futures = append(futures, bus.PublishAsync(i, "key1"))
+		}
+
+		for _, future := range futures {
+			<-future.Done()
+		}
+
+		ensure(len(buf.events())).Equals(numEvents)
+	})
+
+	ensure.Run("the future waits for the event to be delivered", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: -1, // Sets the buffer to zero, so delivery waits for the receiver
+		})
+		defer bus.Close()
+
+		sub := bus.Subscribe("key1")
+		future := bus.PublishAsync("1", "key1")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		ensure(future.Wait(ctx)).IsError(context.DeadlineExceeded)
+
+		ensure(<-sub.Channel()).Equals("1")
+		ensure(future.Wait(context.Background())).IsNotError()
+	})
+
+	ensure.Run("when the bus is closed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.Subscribe("key1")
+		buf := bufferSubscription(sub, 1)
+
+		bus.PublishAsync("1", "key1")
+		bus.Close()
+		bus.Close() // Closing more than once has no effect
+
+		// Queued events are delivered before Close returns
+		ensure(buf.events()).Equals([]string{"1"})
+
+		future := bus.PublishAsync("2", "key1")
+		ensure(future.Wait(context.Background())).IsError(eventbus.ErrBusClosed)
+	})
+
+	ensure.Run("when the bus is closed without publishing asynchronously", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.EventBus[string]{}
+		bus.Close()
+
+		future := bus.PublishAsync("1", "key1")
+		ensure(future.Wait(context.Background())).IsError(eventbus.ErrBusClosed)
+	})
+}
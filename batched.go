@@ -0,0 +1,119 @@
+package eventbus
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchedSubscription maintains subscriptions to multiple topics, delivering events in batches.
+// Batches of events are sent to the Channel().
+type BatchedSubscription[Event any] struct {
+	sub  *Subscription[Event]
+	ch   chan []Event
+	done chan struct{}
+	once sync.Once
+
+	maxSize int
+	maxWait time.Duration
+}
+
+// SubscribeBatched creates a new subscription to the listed topics, which delivers events in batches.
+// A batch is sent to the subscription's channel once it contains maxSize events,
+// or once maxWait has elapsed since the first event in the batch was received, whichever happens first.
+//
+// If maxSize is less than one, each batch contains a single event.
+// If maxWait is not positive, batches are only sent once they are full.
+//
+// Events are delivered in the same order and with the same only once guarantees as Subscribe.
+// Unsubscribing discards the events of an unfinished batch, so they are never sent.
+func (b *EventBus[Event]) SubscribeBatched(maxSize int, maxWait time.Duration, topicKeys ...string) *BatchedSubscription[Event] {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+
+	s := &BatchedSubscription[Event]{
+		sub:  b.Subscribe(topicKeys...),
+		ch:   make(chan []Event),
+		done: make(chan struct{}),
+
+		maxSize: maxSize,
+		maxWait: maxWait,
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Unsubscribe closes the subscription to the topics.
+// Events that have not yet been sent as part of a batch are discarded without being flushed,
+// and the subscription's channel is closed.
+// Calling Unsubscribe more than once has no effect.
+func (s *BatchedSubscription[Event]) Unsubscribe() {
+	s.sub.Unsubscribe()
+	s.once.Do(func() { close(s.done) })
+}
+
+// Channel exposes a read only view of the subscription's channel.
+// Batches of events published to the subscribed topics will be published to this channel.
+func (s *BatchedSubscription[Event]) Channel() <-chan []Event {
+	return s.ch
+}
+
+func (s *BatchedSubscription[Event]) run() {
+	defer close(s.ch)
+
+	var (
+		batch   []Event
+		timer   *time.Timer
+		timeout <-chan time.Time
+	)
+
+	flush := func() bool {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+
+		select {
+		case s.ch <- batch:
+			batch = nil
+			return true
+		case <-s.done:
+			return false
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-s.sub.Channel():
+			if !ok {
+				return
+			}
+
+			batch = append(batch, event)
+
+			if len(batch) >= s.maxSize {
+				if !flush() {
+					return
+				}
+
+				continue
+			}
+
+			// Start the window when the first event of the batch arrives
+			if len(batch) == 1 && s.maxWait > 0 {
+				timer = time.NewTimer(s.maxWait)
+				timeout = timer.C
+			}
+
+		case <-timeout:
+			if !flush() {
+				return
+			}
+
+		case <-s.done:
+			return
+		}
+	}
+}
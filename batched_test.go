@@ -0,0 +1,108 @@
+package eventbus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestSubscribeBatched(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("sends a batch once it is full", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.SubscribeBatched(3, time.Hour, "key1", "key2")
+		defer sub.Unsubscribe()
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key2", "key1") // Only delivered once
+		bus.Publish("3", "key3")
+		bus.Publish("4", "key2")
+		bus.Publish("5", "key1")
+		bus.Publish("6", "key1")
+		bus.Publish("7", "key2")
+
+		ensure(<-sub.Channel()).Equals([]string{"1", "2", "4"})
+		ensure(<-sub.Channel()).Equals([]string{"5", "6", "7"})
+	})
+
+	ensure.Run("sends a partial batch once the window elapses", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.SubscribeBatched(100, 10*time.Millisecond, "key1")
+		defer sub.Unsubscribe()
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key1")
+
+		ensure(<-sub.Channel()).Equals([]string{"1", "2"})
+
+		bus.Publish("3", "key1")
+
+		ensure(<-sub.Channel()).Equals([]string{"3"})
+	})
+
+	ensure.Run("sends each event in its own batch when the max size is less than one", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.SubscribeBatched(0, 0, "key1")
+		defer sub.Unsubscribe()
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key1")
+
+		ensure(<-sub.Channel()).Equals([]string{"1"})
+		ensure(<-sub.Channel()).Equals([]string{"2"})
+	})
+
+	ensure.Run("only sends full batches when the max wait is not positive", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.SubscribeBatched(2, 0, "key1")
+		defer sub.Unsubscribe()
+
+		bus.Publish("1", "key1")
+
+		select {
+		case batch := <-sub.Channel():
+			ensure.Failf("Unexpected batch: %v", batch)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		bus.Publish("2", "key1")
+
+		ensure(<-sub.Channel()).Equals([]string{"1", "2"})
+	})
+
+	ensure.Run("closes the channel when unsubscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.SubscribeBatched(10, time.Hour, "key1")
+
+		bus.Publish("1", "key1")
+		sub.Unsubscribe()
+
+		_, isOpen := <-sub.Channel()
+		ensure(isOpen).IsFalse()
+
+		// Publishing after unsubscribing is a no-op
+		bus.Publish("2", "key1")
+	})
+	ensure.Run("discards the unfinished batch when unsubscribed more than once", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.SubscribeBatched(10, time.Hour, "key1")
+
+		bus.Publish("1", "key1")
+		sub.Unsubscribe()
+		sub.Unsubscribe()
+
+		batch, isOpen := <-sub.Channel()
+		ensure(batch).IsEmpty()
+		ensure(isOpen).IsFalse()
+	})
+}
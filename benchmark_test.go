@@ -0,0 +1,191 @@
+package eventbus_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/JosiahWitt/eventbus"
+)
+
+func BenchmarkPublish(b *testing.B) {
+	b.Run("single topic, single subscription", func(b *testing.B) {
+		bus := eventbus.New[int]()
+		drainSubscriptions(b, bus.Subscribe("key1"))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			bus.Publish(i, "key1")
+		}
+	})
+
+	b.Run("single topic, many subscriptions", func(b *testing.B) {
+		bus := eventbus.New[int]()
+		for i := 0; i < 100; i++ {
+			drainSubscriptions(b, bus.Subscribe("key1"))
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			bus.Publish(i, "key1")
+		}
+	})
+
+	b.Run("multiple topics, overlapping subscriptions", func(b *testing.B) {
+		bus := eventbus.New[int]()
+		for i := 0; i < 100; i++ {
+			drainSubscriptions(b, bus.Subscribe("key1", "key2", fmt.Sprintf("sub:%d", i)))
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			bus.Publish(i, "key1", "key2", "key3")
+		}
+	})
+
+	b.Run("single topic, with subscription churn", func(b *testing.B) {
+		bus := eventbus.New[int]()
+		for i := 0; i < 10; i++ {
+			drainSubscriptions(b, bus.Subscribe("key1"))
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					bus.Subscribe("key1").Unsubscribe()
+				}
+			}
+		}()
+
+		// Allocations aren't reported, since they would include those made by subscribing
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			bus.Publish(i, "key1")
+		}
+	})
+
+	for _, threshold := range []int{0, 1000} {
+		threshold := threshold
+
+		b.Run(fmt.Sprintf("single topic, 10000 subscriptions, parallel fanout threshold %d", threshold), func(b *testing.B) {
+			bus := eventbus.NewWithConfig[int](&eventbus.Config{
+				ParallelFanoutThreshold: threshold,
+			})
+			b.Cleanup(bus.Close)
+
+			for i := 0; i < 10000; i++ {
+				drainSubscriptions(b, bus.Subscribe("key1"))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				bus.Publish(i, "key1")
+			}
+		})
+	}
+
+	b.Run("multiple topics, no subscriptions", func(b *testing.B) {
+		bus := eventbus.New[int]()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			bus.Publish(i, "key1", "key2", "key3")
+		}
+	})
+
+	b.Run("multiple topics, parallel publishers", func(b *testing.B) {
+		bus := eventbus.New[int]()
+		for i := 0; i < 10; i++ {
+			drainSubscriptions(b, bus.Subscribe("key1", "key2"))
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				bus.Publish(i, "key1", "key2")
+			}
+		})
+	})
+}
+
+func BenchmarkTopicChurn(b *testing.B) {
+	registries := []struct {
+		name   string
+		config *eventbus.Config
+	}{
+		{name: "sync.Map registry", config: &eventbus.Config{}},
+		{name: "sharded registry", config: &eventbus.Config{TopicShards: 64}},
+	}
+
+	for _, registry := range registries {
+		registry := registry
+
+		b.Run(registry.name+", unique topics", func(b *testing.B) {
+			bus := eventbus.NewWithConfig[int](registry.config)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					topicKey := strconv.Itoa(i)
+
+					sub := bus.Subscribe(topicKey)
+					bus.Publish(i, topicKey)
+					sub.Unsubscribe()
+				}
+			})
+		})
+
+		b.Run(registry.name+", shared topics", func(b *testing.B) {
+			bus := eventbus.NewWithConfig[int](registry.config)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					topicKey := strconv.Itoa(i % 8)
+
+					sub := bus.Subscribe(topicKey)
+					_ = bus.TryPublish(i, topicKey) // Other subscriptions may be full, since nothing receives from them
+					sub.Unsubscribe()
+				}
+			})
+		})
+	}
+}
+
+func drainSubscriptions[E any](b *testing.B, subs ...*eventbus.Subscription[E]) {
+	b.Helper()
+
+	for _, sub := range subs {
+		sub := sub
+
+		go func() {
+			for range sub.Channel() {
+			}
+		}()
+
+		b.Cleanup(sub.Unsubscribe)
+	}
+}
@@ -0,0 +1,55 @@
+package eventbus
+
+// Broker routes published events to the subscriptions of their topics.
+//
+// EventBus is the default in-memory Broker, which can be used as one by calling Broker.
+// Adapters for external brokers can implement the interface, so code depending on a Broker
+// can use either one, without changing how it publishes and subscribes.
+// Implementations should pass the conformance tests in the brokertest package.
+//
+// The handlers and servers in this module's packages, such as sse, websocket, and remote,
+// accept a Broker or one of the narrower Publisher and Subscriber interfaces,
+// so an external broker can be plugged in behind them in place of an EventBus.
+type Broker[Event any] interface {
+	// Publisher sends the provided event to all of the listed topics.
+	// All subscriptions to those topics will be notified of the event.
+	// Each subscription receives the events from a single publisher in order, and only once,
+	// even if the event is published to multiple of its topics.
+	Publisher[Event]
+
+	// Subscriber creates subscriptions to the topics, whose Receive and Err behave like Subscription's.
+	Subscriber[Event]
+
+	// Topics lists the keys of the topics that currently have subscriptions, in no particular order.
+	Topics() []string
+}
+
+// Broker returns the bus as a Broker, which can also be used as a ConfigurableSubscriber and a ReportingPublisher.
+func (b *EventBus[Event]) Broker() Broker[Event] {
+	return memoryBroker[Event]{bus: b}
+}
+
+// memoryBroker adapts an EventBus into a Broker, since Subscribe returns the concrete Subscription.
+type memoryBroker[Event any] struct {
+	bus *EventBus[Event]
+}
+
+func (m memoryBroker[Event]) Publish(event Event, topicKeys ...string) {
+	m.bus.Publish(event, topicKeys...)
+}
+
+func (m memoryBroker[Event]) PublishWithReport(event Event, topicKeys ...string) *PublishReport[Event] {
+	return m.bus.PublishWithReport(event, topicKeys...)
+}
+
+func (m memoryBroker[Event]) Subscribe(topicKeys ...string) Receiver[Event] {
+	return m.bus.Subscribe(topicKeys...)
+}
+
+func (m memoryBroker[Event]) SubscribeWithConfig(config *SubscriptionConfig[Event], topicKeys ...string) Receiver[Event] {
+	return m.bus.SubscribeWithConfig(config, topicKeys...)
+}
+
+func (m memoryBroker[Event]) Topics() []string {
+	return m.bus.Topics()
+}
@@ -0,0 +1,41 @@
+package eventbus_test
+
+import (
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/brokertest"
+)
+
+func TestBroker(t *testing.T) {
+	ensure := ensure.New(t)
+
+	buses := map[string]func() *eventbus.EventBus[string]{
+		"when created by New":                     eventbus.New[string],
+		"when created by initializing the struct": func() *eventbus.EventBus[string] { return &eventbus.EventBus[string]{} },
+		"with no buffer": func() *eventbus.EventBus[string] {
+			return eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: -1})
+		},
+		"with parallel fanout": func() *eventbus.EventBus[string] {
+			return eventbus.NewWithConfig[string](&eventbus.Config{ParallelFanoutThreshold: 1, FanoutWorkers: 2})
+		},
+		"with a sharded topic registry": func() *eventbus.EventBus[string] {
+			return eventbus.NewWithConfig[string](&eventbus.Config{TopicShards: 4})
+		},
+	}
+
+	for name, newBus := range buses {
+		newBus := newBus
+
+		ensure.Run(name, func(ensure ensurepkg.Ensure) {
+			brokertest.Run(ensure.T(), func(t *testing.T) eventbus.Broker[string] {
+				bus := newBus()
+				t.Cleanup(bus.Close)
+
+				return bus.Broker()
+			})
+		})
+	}
+}
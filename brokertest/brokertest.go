@@ -0,0 +1,457 @@
+// Package brokertest provides conformance tests for implementations of eventbus.Broker,
+// so adapters for external brokers can check they behave like the in-memory EventBus.
+//
+// Use it from a test in the adapter's package:
+//
+//	func TestBroker(t *testing.T) {
+//		brokertest.Run(t, func(t *testing.T) eventbus.Broker[string] {
+//			broker := NewAdapter(...)
+//			t.Cleanup(broker.Close)
+//			return broker
+//		})
+//	}
+package brokertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+)
+
+const (
+	// DefaultTimeout for each expected event or change to arrive. Used when the Timeout is not configured.
+	DefaultTimeout = 5 * time.Second
+
+	// DefaultQuietPeriod waited to make sure no unexpected events arrive. Used when the QuietPeriod is not configured.
+	DefaultQuietPeriod = 50 * time.Millisecond
+)
+
+// Config can be passed to RunWithConfig to customize the tests.
+type Config struct {
+	// Timeout for each expected event or change to arrive.
+	// If not set or zero, it defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// QuietPeriod is how long to wait to make sure no unexpected events arrive.
+	// Brokers that deliver events over a network may need a longer period.
+	// If not set or zero, it defaults to DefaultQuietPeriod.
+	QuietPeriod time.Duration
+}
+
+// Run runs the conformance tests against the brokers returned by newBroker, which is called once for each test.
+// Brokers can be cleaned up using t.Cleanup.
+func Run(t *testing.T, newBroker func(t *testing.T) eventbus.Broker[string]) {
+	t.Helper()
+	RunWithConfig(t, newBroker, &Config{})
+}
+
+// RunWithConfig runs the customized conformance tests against the brokers returned by newBroker,
+// which is called once for each test.
+// Brokers can be cleaned up using t.Cleanup.
+func RunWithConfig(t *testing.T, newBroker func(t *testing.T) eventbus.Broker[string], config *Config) {
+	t.Helper()
+
+	s := &suite{
+		timeout:     config.Timeout,
+		quietPeriod: config.QuietPeriod,
+	}
+
+	if s.timeout <= 0 {
+		s.timeout = DefaultTimeout
+	}
+
+	if s.quietPeriod <= 0 {
+		s.quietPeriod = DefaultQuietPeriod
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, broker eventbus.Broker[string])
+	}{
+		{"when nothing is subscribed", s.testNothingSubscribed},
+		{"when one topic is subscribed and one event is published", s.testOneEvent},
+		{"when one topic is subscribed and two events are published", s.testTwoEvents},
+		{"when two topics are subscribed to different keys and two events are published", s.testDifferentKeys},
+		{"when two topics are subscribed to the same key and two events are published", s.testSameKey},
+		{"only receives event once when the same event is published to duplicate topics", s.testDuplicateTopics},
+		{"when subscribed to multiple topics", s.testMultipleTopics},
+		{"when two topics are subscribed to different keys and two non-overlapping events are published", s.testNonOverlappingEvents},
+		{"when two topics are subscribed to different keys and variously-overlapping events are published", s.testOverlappingEvents},
+		{"when one subscription is unsubscribed part way through", s.testUnsubscribePartWay},
+		{"when all subscriptions are unsubscribed on one topic and then a new one is subscribed", s.testResubscribe},
+		{"receives events until unsubscribed", s.testReceive},
+		{"lists the topics with subscriptions", s.testTopics},
+		{"concurrent publishing", s.testConcurrentPublishing},
+		{"concurrent subscriptions, publishing, and unsubscriptions", s.testConcurrentSubscriptions},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newBroker(t))
+		})
+	}
+}
+
+type suite struct {
+	timeout     time.Duration
+	quietPeriod time.Duration
+}
+
+func (s *suite) testNothingSubscribed(t *testing.T, broker eventbus.Broker[string]) {
+	broker.Publish("123", "key1", "key2")
+}
+
+func (s *suite) testOneEvent(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+
+	broker.Publish("123", "key1", "key2")
+
+	rec1.expect(t, "123")
+}
+
+func (s *suite) testTwoEvents(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+
+	broker.Publish("123", "key1", "key2")
+	broker.Publish("456", "key1", "key2")
+
+	rec1.expect(t, "123", "456")
+}
+
+func (s *suite) testDifferentKeys(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key2")
+
+	broker.Publish("123", "key1", "key2")
+	broker.Publish("456", "key1", "key2")
+
+	rec1.expect(t, "123", "456")
+	rec2.expect(t, "123", "456")
+}
+
+func (s *suite) testSameKey(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key1")
+
+	broker.Publish("123", "key1", "key2")
+	broker.Publish("456", "key1", "key2")
+
+	rec1.expect(t, "123", "456")
+	rec2.expect(t, "123", "456")
+}
+
+func (s *suite) testDuplicateTopics(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+
+	broker.Publish("123", "key1", "key2", "key1")
+	broker.Publish("456", "key1", "key2", "key1")
+
+	rec1.expect(t, "123", "456")
+}
+
+func (s *suite) testMultipleTopics(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1", "key2")
+
+	broker.Publish("123", "key1", "key2", "key3") // Shows that events are only delivered once
+	broker.Publish("456", "key3")
+	broker.Publish("789", "key1")
+	broker.Publish("42", "key2")
+
+	rec1.expect(t, "123", "789", "42")
+}
+
+func (s *suite) testNonOverlappingEvents(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key2")
+
+	broker.Publish("123", "key1")
+	broker.Publish("456", "key2")
+
+	rec1.expect(t, "123")
+	rec2.expect(t, "456")
+}
+
+func (s *suite) testOverlappingEvents(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key2")
+
+	broker.Publish("123", "key1")
+	broker.Publish("456", "key2")
+	broker.Publish("789", "key2", "key1")
+
+	rec1.expect(t, "123", "789")
+	rec2.expect(t, "456", "789")
+}
+
+func (s *suite) testUnsubscribePartWay(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key1")
+
+	broker.Publish("123", "key1")
+	broker.Publish("456", "key1")
+	rec1.expect(t, "123", "456")
+
+	rec1.unsubscribe()
+	broker.Publish("789", "key1")
+
+	rec1.expectClosed(t)
+	rec2.expect(t, "123", "456", "789")
+}
+
+func (s *suite) testResubscribe(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key1")
+	rec3 := s.subscribe(t, broker, "key2")
+
+	broker.Publish("123", "key1")
+	broker.Publish("456", "key1", "key2")
+	rec1.expect(t, "123", "456")
+
+	rec1.unsubscribe()
+	broker.Publish("789", "key1")
+	rec2.expect(t, "123", "456", "789")
+
+	rec2.unsubscribe()
+	rec1.expectClosed(t)
+	rec2.expectClosed(t)
+	rec3.expect(t, "456")
+
+	rec4 := s.subscribe(t, broker, "key1")
+	broker.Publish("42", "key1")
+
+	rec4.expect(t, "42")
+}
+
+func (s *suite) testReceive(t *testing.T, broker eventbus.Broker[string]) {
+	sub := broker.Subscribe("key1")
+	go broker.Publish("123", "key1") // Brokers may wait for the event to be received
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	event, err := sub.Receive(ctx)
+	if err != nil || event != "123" {
+		t.Fatalf("expected to receive %q, got %q with error %v", "123", event, err)
+	}
+
+	if err := sub.Err(); err != nil {
+		t.Fatalf("expected no error while subscribed, got %v", err)
+	}
+
+	sub.Unsubscribe()
+
+	if _, err := sub.Receive(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Receive to return an error once unsubscribed, got %v", err)
+	}
+
+	if err := sub.Err(); err == nil {
+		t.Fatal("expected Err to return an error once unsubscribed")
+	}
+}
+
+func (s *suite) testTopics(t *testing.T, broker eventbus.Broker[string]) {
+	s.expectTopics(t, broker)
+
+	rec1 := s.subscribe(t, broker, "key1", "key2")
+	rec2 := s.subscribe(t, broker, "key2")
+	s.expectTopics(t, broker, "key1", "key2")
+
+	rec1.unsubscribe()
+	s.expectTopics(t, broker, "key2")
+
+	rec2.unsubscribe()
+	s.expectTopics(t, broker)
+}
+
+func (s *suite) testConcurrentPublishing(t *testing.T, broker eventbus.Broker[string]) {
+	rec1 := s.subscribe(t, broker, "key1")
+	rec2 := s.subscribe(t, broker, "key1")
+	rec3 := s.subscribe(t, broker, "key2")
+
+	publish := func(event string, topicKeys ...string) {
+		go broker.Publish(event, topicKeys...)
+	}
+
+	publish("1", "key1")
+	publish("2", "key2")
+	publish("3", "key1")
+	publish("4", "key2")
+	publish("5", "key1")
+
+	// Concurrently published events may arrive in any order
+	rec1.expectUnordered(t, "1", "3", "5")
+	rec2.expectUnordered(t, "1", "3", "5")
+	rec3.expectUnordered(t, "2", "4")
+}
+
+func (s *suite) testConcurrentSubscriptions(t *testing.T, broker eventbus.Broker[string]) {
+	// This test is largely designed to help surface any race condition issues, thus the results are not checked
+
+	const numParallel = 1000
+
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(numParallel)
+
+		for i := 0; i < numParallel; i++ {
+			go func() {
+				defer wg.Done()
+				f()
+			}()
+		}
+	}
+
+	// Make sure the subscription and unsubscription can happen in separate goroutines
+	run(func() {
+		subscribed := make(chan eventbus.Receiver[string])
+		go func() { subscribed <- broker.Subscribe("key1") }()
+		(<-subscribed).Unsubscribe()
+	})
+
+	run(func() { broker.Publish("1", "key1") })
+	run(func() { broker.Subscribe("key1").Unsubscribe() })
+	run(func() { broker.Subscribe("key2").Unsubscribe() })
+	run(func() { broker.Publish("2", "key2") })
+	run(func() { broker.Publish("3", "key1", "key2") })
+
+	wg.Wait()
+}
+
+func (s *suite) expectTopics(t *testing.T, broker eventbus.Broker[string], expected ...string) {
+	t.Helper()
+
+	sort.Strings(expected)
+
+	var topicKeys []string
+	s.waitFor(func() bool {
+		topicKeys = broker.Topics()
+		sort.Strings(topicKeys)
+
+		return fmt.Sprint(topicKeys) == fmt.Sprint(expected)
+	})
+
+	if fmt.Sprint(topicKeys) != fmt.Sprint(expected) {
+		t.Fatalf("expected topics %q, got %q", expected, topicKeys)
+	}
+}
+
+func (s *suite) waitFor(condition func() bool) {
+	deadline := time.Now().Add(s.timeout)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recorder receives the events of a subscription in the background,
+// so brokers that wait for subscriptions to receive events are never blocked.
+type recorder struct {
+	suite *suite
+	sub   eventbus.Receiver[string]
+	once  sync.Once
+
+	mu       sync.Mutex
+	events   []string
+	isClosed bool
+}
+
+func (s *suite) subscribe(t *testing.T, broker eventbus.Broker[string], topicKeys ...string) *recorder {
+	rec := &recorder{suite: s, sub: broker.Subscribe(topicKeys...)}
+	t.Cleanup(rec.unsubscribe)
+
+	go func() {
+		for event := range rec.sub.Channel() {
+			rec.mu.Lock()
+			rec.events = append(rec.events, event)
+			rec.mu.Unlock()
+		}
+
+		rec.mu.Lock()
+		rec.isClosed = true
+		rec.mu.Unlock()
+	}()
+
+	return rec
+}
+
+func (r *recorder) unsubscribe() {
+	r.once.Do(r.sub.Unsubscribe)
+}
+
+// expect waits for the expected events since the last expectation, and then makes sure no other events arrive.
+func (r *recorder) expect(t *testing.T, expected ...string) {
+	t.Helper()
+
+	if events := r.wait(len(expected)); fmt.Sprintf("%q", events) != fmt.Sprintf("%q", expected) {
+		t.Fatalf("expected events %q, got %q", expected, events)
+	}
+}
+
+// expectUnordered waits for the expected events since the last expectation in any order,
+// and then makes sure no other events arrive.
+func (r *recorder) expectUnordered(t *testing.T, expected ...string) {
+	t.Helper()
+
+	events := r.wait(len(expected))
+	sort.Strings(events)
+	sort.Strings(expected)
+
+	if fmt.Sprintf("%q", events) != fmt.Sprintf("%q", expected) {
+		t.Fatalf("expected events %q in any order, got %q", expected, events)
+	}
+}
+
+// expectClosed waits for the channel to be closed, and makes sure no other events arrived.
+func (r *recorder) expectClosed(t *testing.T) {
+	t.Helper()
+
+	expectedCount := r.snapshotCount()
+
+	r.suite.waitFor(func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		return r.isClosed
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isClosed {
+		t.Fatal("expected the channel to be closed once unsubscribed")
+	}
+
+	if len(r.events) != expectedCount {
+		t.Fatalf("expected no events after unsubscribing, got %q", r.events[expectedCount:])
+	}
+}
+
+// wait returns the events once the count is reached, or the timeout elapses,
+// and once no more events arrive during the quiet period.
+func (r *recorder) wait(count int) []string {
+	r.suite.waitFor(func() bool { return r.snapshotCount() >= count })
+	time.Sleep(r.suite.quietPeriod)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Events already checked are cleared, so the next call only checks newer events
+	events := r.events
+	r.events = nil
+
+	return events
+}
+
+func (r *recorder) snapshotCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.events)
+}
@@ -0,0 +1,525 @@
+// Package cluster connects the EventBus instances of several processes, such as the replicas of a service,
+// so events published on one node reach the subscriptions on the other nodes.
+//
+// Nodes connect to their configured peers, and tell them which topics have subscriptions on the node.
+// Events published with Node.Publish are published to the local bus, and forwarded to the peers with
+// subscriptions to any of the event's topics, so events only cross the wire when a peer is interested in them.
+// Events received from peers are only published to the local bus, and are never forwarded again,
+// which prevents forwarding loops. That means every node must be connected to every other node.
+// Connections are used in both directions, so it is enough for one of each pair of nodes to list the other as a peer.
+//
+// Interest in topics is exchanged asynchronously, so events published right after a peer subscribes
+// to a new topic may not be forwarded to it.
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+const (
+	// DefaultMaxMessageSize of messages sent between nodes, in bytes. Used when the MaxMessageSize is not configured.
+	DefaultMaxMessageSize = 1 << 20
+
+	// DefaultMinReconnectDelay before reconnecting to a peer. Used when the MinReconnectDelay is not configured.
+	DefaultMinReconnectDelay = 100 * time.Millisecond
+
+	// DefaultMaxReconnectDelay between attempts to reconnect to a peer. Used when the MaxReconnectDelay is not configured.
+	DefaultMaxReconnectDelay = 10 * time.Second
+
+	// DefaultHandshakeTimeout for connecting to a peer. Used when the HandshakeTimeout is not configured.
+	DefaultHandshakeTimeout = 10 * time.Second
+
+	// DefaultWriteTimeout for sending a message to a peer. Used when the WriteTimeout is not configured.
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// ErrNodeClosed is returned by Serve once the node is closed.
+var ErrNodeClosed = errors.New("cluster: node closed")
+
+// Config can be passed to NewNode to customize the Node.
+type Config[Event any] struct {
+	// Peers lists the addresses of the other nodes to connect to.
+	// The node's own address may be listed, which is ignored, so all nodes can share the same list.
+	Peers []string
+
+	// Network of the peer addresses, such as "tcp" or "unix".
+	// If not set, it defaults to "tcp".
+	Network string
+
+	// Codec encodes and decodes the events sent between nodes.
+	// All nodes must use the same codec.
+	// If not set, events are encoded as JSON.
+	Codec codec.Codec[Event]
+
+	// MaxMessageSize is the maximum size of messages sent by peers, in bytes.
+	// Peers sending larger messages are disconnected.
+	// If not set or zero, it defaults to DefaultMaxMessageSize.
+	// If negative, the size is not limited.
+	MaxMessageSize int
+
+	// MinReconnectDelay is the delay before reconnecting to a peer after the connection fails.
+	// The delay doubles after each failed attempt, up to the MaxReconnectDelay, with some jitter added.
+	// If not set or zero, it defaults to DefaultMinReconnectDelay.
+	MinReconnectDelay time.Duration
+
+	// MaxReconnectDelay is the maximum delay between attempts to reconnect to a peer.
+	// If not set or zero, it defaults to DefaultMaxReconnectDelay.
+	MaxReconnectDelay time.Duration
+
+	// HandshakeTimeout limits how long connecting to a peer can take.
+	// If not set or zero, it defaults to DefaultHandshakeTimeout.
+	HandshakeTimeout time.Duration
+
+	// WriteTimeout limits how long sending a message to a peer can take.
+	// Peers that are too slow are disconnected.
+	// If not set or zero, it defaults to DefaultWriteTimeout.
+	WriteTimeout time.Duration
+
+	// OnError is called when a connection to a peer fails, or an event can't be sent or received.
+	// If not set, errors are ignored.
+	OnError func(err error)
+}
+
+// PeerInfo describes a connected peer.
+type PeerInfo struct {
+	// ID of the peer's node.
+	ID string
+
+	// Topics that have subscriptions on the peer, sorted by key.
+	Topics []string
+
+	// EventsSent to the peer.
+	EventsSent uint64
+
+	// EventsReceived from the peer.
+	EventsReceived uint64
+}
+
+// Bus is the local bus a Node connects to the cluster.
+// It is implemented by EventBus.
+type Bus[Event any] interface {
+	eventbus.Publisher[Event]
+
+	// Topics returns the keys of the topics that have at least one subscription.
+	Topics() []string
+
+	// HasTopic reports whether the topic has at least one subscription.
+	HasTopic(topicKey string) bool
+
+	// WatchTopics calls notify with the key of each topic that is created or deleted, until unwatch is called.
+	WatchTopics(notify func(topicKey string)) (unwatch func())
+}
+
+var _ Bus[any] = &eventbus.EventBus[any]{}
+
+// Node connects a local Bus to the other nodes of the cluster.
+type Node[Event any] struct {
+	bus    Bus[Event]
+	config *Config[Event]
+	id     string
+
+	unwatch func()
+
+	mu        sync.Mutex
+	peers     map[string]*peer[Event] // By node ID
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	isClosed  bool
+
+	// interestMu guards the advertised topics, and orders the interest messages sent to the peers
+	interestMu sync.Mutex
+	advertised map[string]bool
+
+	pendingMu     sync.Mutex
+	pendingTopics map[string]bool
+	pendingSignal chan struct{}
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+var _ eventbus.Publisher[any] = &Node[any]{}
+
+// NewNode creates a new Node for the bus, which starts connecting to the configured peers.
+// Other nodes can connect to it once Serve is called.
+func NewNode[Event any](bus Bus[Event], config *Config[Event]) *Node[Event] {
+	n := &Node[Event]{
+		bus:    bus,
+		config: config,
+		id:     newNodeID(),
+
+		peers:     make(map[string]*peer[Event]),
+		listeners: make(map[net.Listener]bool),
+		conns:     make(map[net.Conn]bool),
+
+		advertised:    make(map[string]bool),
+		pendingTopics: make(map[string]bool),
+		pendingSignal: make(chan struct{}, 1),
+
+		closing: make(chan struct{}),
+	}
+
+	n.unwatch = bus.WatchTopics(n.topicChanged)
+
+	// Topics created before watching are advertised too
+	for _, topicKey := range bus.Topics() {
+		n.topicChanged(topicKey)
+	}
+
+	n.wg.Add(1 + len(config.Peers))
+	go n.syncInterest()
+
+	for _, address := range config.Peers {
+		go n.dialPeer(address)
+	}
+
+	return n
+}
+
+// ID of the node, which is randomly generated.
+func (n *Node[Event]) ID() string {
+	return n.id
+}
+
+// Serve accepts connections from peers on the listener, until the node is closed or accepting fails.
+// It always returns an error, which is ErrNodeClosed once the node is closed.
+func (n *Node[Event]) Serve(listener net.Listener) error {
+	n.mu.Lock()
+	if n.isClosed {
+		n.mu.Unlock()
+		return ErrNodeClosed
+	}
+
+	n.listeners[listener] = true
+	n.mu.Unlock()
+
+	defer func() {
+		n.mu.Lock()
+		delete(n.listeners, listener)
+		n.mu.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if n.closed() {
+				return ErrNodeClosed
+			}
+
+			return err
+		}
+
+		if !n.trackConn(conn) {
+			conn.Close()
+			return ErrNodeClosed
+		}
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+
+			// Only handshake failures are reported, since the dialing node reports the others
+			var duplicate *duplicateLinkError
+			err := n.runLink(conn, false)
+			if err != nil && !n.closed() && !errors.Is(err, errLinkEnded) && !errors.Is(err, errSelfLink) && !errors.As(err, &duplicate) {
+				n.onError(err)
+			}
+		}()
+	}
+}
+
+// Publish sends the provided event to all of the listed topics of the local bus,
+// and forwards it to the peers with subscriptions to any of those topics.
+// All subscriptions to those topics across the cluster will be notified of the event.
+func (n *Node[Event]) Publish(event Event, topicKeys ...string) {
+	n.bus.Publish(event, topicKeys...)
+
+	var data []byte
+	for _, p := range n.connectedPeers() {
+		interestingTopicKeys := p.interestingTopics(topicKeys)
+		if len(interestingTopicKeys) == 0 {
+			continue
+		}
+
+		// Only encode the event once a peer is interested in it
+		if data == nil {
+			var err error
+			if data, err = n.codec().Encode(event); err != nil {
+				n.onError(fmt.Errorf("cluster: unable to encode event: %w", err))
+				return
+			}
+		}
+
+		p.sendEvent(interestingTopicKeys, data)
+	}
+}
+
+// Peers lists the connected peers, sorted by ID.
+func (n *Node[Event]) Peers() []PeerInfo {
+	peers := n.connectedPeers()
+	infos := make([]PeerInfo, 0, len(peers))
+
+	for _, p := range peers {
+		infos = append(infos, p.info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+
+	return infos
+}
+
+// Close disconnects from the peers, and stops accepting connections.
+// It doesn't close the bus.
+func (n *Node[Event]) Close() error {
+	n.mu.Lock()
+	if n.isClosed {
+		n.mu.Unlock()
+		return nil
+	}
+
+	n.isClosed = true
+	close(n.closing)
+
+	var err error
+	for listener := range n.listeners {
+		if closeErr := listener.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	for conn := range n.conns {
+		conn.Close()
+	}
+	n.mu.Unlock()
+
+	n.unwatch()
+	n.wg.Wait()
+
+	return err
+}
+
+// topicChanged is called by the bus when a topic is created or deleted, so it must not block.
+func (n *Node[Event]) topicChanged(topicKey string) {
+	n.pendingMu.Lock()
+	n.pendingTopics[topicKey] = true
+	n.pendingMu.Unlock()
+
+	select {
+	case n.pendingSignal <- struct{}{}:
+	default: // Already signaled
+	}
+}
+
+// syncInterest tells the peers when topics on the local bus gain or lose all of their subscriptions.
+func (n *Node[Event]) syncInterest() {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case <-n.pendingSignal:
+		case <-n.closing:
+			return
+		}
+
+		n.pendingMu.Lock()
+		pendingTopics := n.pendingTopics
+		n.pendingTopics = make(map[string]bool)
+		n.pendingMu.Unlock()
+
+		n.interestMu.Lock()
+
+		var added, removed []string
+		for topicKey := range pendingTopics {
+			// Notifications may arrive out of order, so the topic's current state is used
+			hasTopic := n.bus.HasTopic(topicKey)
+
+			if hasTopic && !n.advertised[topicKey] {
+				n.advertised[topicKey] = true
+				added = append(added, topicKey)
+			} else if !hasTopic && n.advertised[topicKey] {
+				delete(n.advertised, topicKey)
+				removed = append(removed, topicKey)
+			}
+		}
+
+		for _, p := range n.connectedPeers() {
+			p.sendInterest(messageInterest, added)
+			p.sendInterest(messageDisinterest, removed)
+		}
+
+		n.interestMu.Unlock()
+	}
+}
+
+// dialPeer keeps the node connected to the peer at the address, until the node is closed.
+func (n *Node[Event]) dialPeer(address string) {
+	defer n.wg.Done()
+
+	network := n.config.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	delay := n.minReconnectDelayOrDefault()
+
+	for {
+		conn, err := net.DialTimeout(network, address, n.handshakeTimeoutOrDefault())
+		if err == nil {
+			if !n.trackConn(conn) {
+				conn.Close()
+				return
+			}
+
+			err = n.runLink(conn, true)
+		}
+
+		var duplicate *duplicateLinkError
+		switch {
+		case n.closed():
+			return
+
+		case errors.Is(err, errSelfLink):
+			return // The address is our own
+
+		case errors.As(err, &duplicate):
+			// The peer connected to us, so wait for that connection to fail before reconnecting
+			select {
+			case <-duplicate.existingDone:
+				delay = n.minReconnectDelayOrDefault()
+				continue
+			case <-n.closing:
+				return
+			}
+
+		case errors.Is(err, errLinkEnded):
+			delay = n.minReconnectDelayOrDefault() // The connection worked, so reconnect quickly
+
+		case err != nil:
+			n.onError(fmt.Errorf("cluster: unable to connect to peer %s: %w", address, err))
+		}
+
+		// Up to 20% jitter keeps nodes from reconnecting in lockstep after a peer restarts
+		timer := time.NewTimer(delay + time.Duration(mathrand.Int63n(int64(delay)/5+1))) //nolint:gosec
+
+		select {
+		case <-timer.C:
+		case <-n.closing:
+			timer.Stop()
+			return
+		}
+
+		delay *= 2
+		if maxDelay := n.maxReconnectDelayOrDefault(); delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+func (n *Node[Event]) connectedPeers() []*peer[Event] {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	peers := make([]*peer[Event], 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+
+	return peers
+}
+
+func (n *Node[Event]) trackConn(conn net.Conn) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.isClosed {
+		return false
+	}
+
+	n.conns[conn] = true
+	return true
+}
+
+func (n *Node[Event]) untrackConn(conn net.Conn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.conns, conn)
+}
+
+func (n *Node[Event]) closed() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.isClosed
+}
+
+func (n *Node[Event]) codec() codec.Codec[Event] {
+	if n.config.Codec != nil {
+		return n.config.Codec
+	}
+
+	return codec.JSON[Event]{}
+}
+
+func (n *Node[Event]) onError(err error) {
+	if n.config.OnError != nil {
+		n.config.OnError(err)
+	}
+}
+
+func newNodeID() string {
+	id := make([]byte, 16)
+	rand.Read(id) //nolint:errcheck // Never returns an error
+	return hex.EncodeToString(id)
+}
+
+func (n *Node[Event]) maxMessageSizeOrDefault() int {
+	if n.config.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+
+	return n.config.MaxMessageSize
+}
+
+func (n *Node[Event]) minReconnectDelayOrDefault() time.Duration {
+	if n.config.MinReconnectDelay <= 0 {
+		return DefaultMinReconnectDelay
+	}
+
+	return n.config.MinReconnectDelay
+}
+
+func (n *Node[Event]) maxReconnectDelayOrDefault() time.Duration {
+	if n.config.MaxReconnectDelay <= 0 {
+		return DefaultMaxReconnectDelay
+	}
+
+	return n.config.MaxReconnectDelay
+}
+
+func (n *Node[Event]) handshakeTimeoutOrDefault() time.Duration {
+	if n.config.HandshakeTimeout <= 0 {
+		return DefaultHandshakeTimeout
+	}
+
+	return n.config.HandshakeTimeout
+}
+
+func (n *Node[Event]) writeTimeoutOrDefault() time.Duration {
+	if n.config.WriteTimeout <= 0 {
+		return DefaultWriteTimeout
+	}
+
+	return n.config.WriteTimeout
+}
@@ -0,0 +1,243 @@
+package cluster_test
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/cluster"
+)
+
+type Message struct {
+	ID string
+}
+
+func TestCluster(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("forwards events to the peers with subscriptions", func(ensure ensurepkg.Ensure) {
+		listeners := listen(ensure, 3)
+		nodeA, busA := startNode(ensure, listeners[0], nil)
+		nodeB, busB := startNode(ensure, listeners[1], addresses(listeners[:1]))
+		nodeC, busC := startNode(ensure, listeners[2], addresses(listeners[:2]))
+
+		waitFor(func() bool { return len(nodeA.Peers()) == 2 && len(nodeB.Peers()) == 2 && len(nodeC.Peers()) == 2 })
+
+		subB := busB.Subscribe("key1")
+		defer subB.Unsubscribe()
+
+		subC := busC.Subscribe("key2", "key3")
+		defer subC.Unsubscribe()
+
+		waitFor(func() bool {
+			return peerTopics(nodeA, nodeB.ID()) == "key1" && peerTopics(nodeA, nodeC.ID()) == "key2,key3" &&
+				peerTopics(nodeB, nodeC.ID()) == "key2,key3" && peerTopics(nodeC, nodeB.ID()) == "key1"
+		})
+
+		nodeA.Publish(&Message{ID: "1"}, "key1", "key2", "key3") // Only delivered once to each subscription
+		nodeB.Publish(&Message{ID: "2"}, "key1", "key3")
+		nodeC.Publish(&Message{ID: "3"}, "key4") // Not forwarded, since no peer is interested
+
+		// Events from different nodes may arrive in any order
+		ensure(receiveIDs(subB, 2)).Equals([]string{"1", "2"})
+		ensure(receiveIDs(subC, 2)).Equals([]string{"1", "2"})
+		ensureNoEvent(ensure, subB, subC)
+
+		ensure(peerInfo(nodeA, nodeB.ID()).EventsSent).Equals(uint64(1))
+		ensure(peerInfo(nodeA, nodeC.ID()).EventsSent).Equals(uint64(1))
+		ensure(peerInfo(nodeB, nodeA.ID()).EventsSent).Equals(uint64(0))
+		ensure(peerInfo(nodeB, nodeC.ID()).EventsSent).Equals(uint64(1))
+		ensure(peerInfo(nodeC, nodeA.ID()).EventsSent).Equals(uint64(0))
+		ensure(peerInfo(nodeC, nodeB.ID()).EventsSent).Equals(uint64(0))
+		ensure(peerInfo(nodeC, nodeA.ID()).EventsReceived).Equals(uint64(1))
+		ensure(busA.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("stops forwarding once the peer's subscriptions are gone", func(ensure ensurepkg.Ensure) {
+		listeners := listen(ensure, 2)
+		nodeA, _ := startNode(ensure, listeners[0], nil)
+		nodeB, busB := startNode(ensure, listeners[1], addresses(listeners[:1]))
+
+		sub := busB.Subscribe("key1")
+		waitFor(func() bool { return peerTopics(nodeA, nodeB.ID()) == "key1" })
+
+		sub.Unsubscribe()
+		waitFor(func() bool { return peerTopics(nodeA, nodeB.ID()) == "" })
+
+		nodeA.Publish(&Message{ID: "1"}, "key1")
+		ensure(peerTopics(nodeA, nodeB.ID())).Equals("")
+		ensure(peerInfo(nodeA, nodeB.ID()).EventsSent).Equals(uint64(0))
+	})
+
+	ensure.Run("advertises topics subscribed before connecting", func(ensure ensurepkg.Ensure) {
+		listeners := listen(ensure, 2)
+
+		busB := eventbus.New[*Message]()
+		sub := busB.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		nodeA, _ := startNode(ensure, listeners[0], nil)
+		nodeB := cluster.NewNode[*Message](busB, &cluster.Config[*Message]{Peers: addresses(listeners[:1])})
+		go nodeB.Serve(listeners[1]) //nolint:errcheck // Closed below
+		defer nodeB.Close()
+
+		waitFor(func() bool { return peerTopics(nodeA, nodeB.ID()) == "key1" })
+		nodeA.Publish(&Message{ID: "1"}, "key1")
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1"})
+	})
+
+	ensure.Run("keeps one connection when peers list each other and themselves", func(ensure ensurepkg.Ensure) {
+		listeners := listen(ensure, 2)
+		peers := addresses(listeners)
+		nodeA, busA := startNode(ensure, listeners[0], peers)
+		nodeB, busB := startNode(ensure, listeners[1], peers)
+
+		subA := busA.Subscribe("key1")
+		defer subA.Unsubscribe()
+
+		subB := busB.Subscribe("key1")
+		defer subB.Unsubscribe()
+
+		waitFor(func() bool { return peerTopics(nodeA, nodeB.ID()) == "key1" && peerTopics(nodeB, nodeA.ID()) == "key1" })
+		time.Sleep(50 * time.Millisecond) // Allow duplicate connections to settle
+
+		nodeA.Publish(&Message{ID: "1"}, "key1")
+		nodeB.Publish(&Message{ID: "2"}, "key1")
+
+		ensure(receiveIDs(subA, 2)).Equals([]string{"1", "2"})
+		ensure(receiveIDs(subB, 2)).Equals([]string{"1", "2"})
+		ensureNoEvent(ensure, subA, subB)
+
+		ensure(len(nodeA.Peers())).Equals(1)
+		ensure(len(nodeB.Peers())).Equals(1)
+	})
+
+	ensure.Run("reconnects to peers that restart", func(ensure ensurepkg.Ensure) {
+		listeners := listen(ensure, 1)
+		address := listeners[0].Addr().String()
+
+		nodeB, busB := startNode(ensure, listeners[0], nil)
+		nodeC, _ := startNode(ensure, nil, []string{address})
+		waitFor(func() bool { return len(nodeC.Peers()) == 1 })
+		ensure(nodeC.Peers()[0].ID).Equals(nodeB.ID())
+
+		nodeB.Close()
+		waitFor(func() bool { return len(nodeC.Peers()) == 0 })
+
+		listener, err := net.Listen("tcp", address)
+		ensure(err).IsNotError()
+
+		nodeB, busB = startNode(ensure, listener, nil)
+		sub := busB.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		waitFor(func() bool { return peerTopics(nodeC, nodeB.ID()) == "key1" })
+		nodeC.Publish(&Message{ID: "1"}, "key1")
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1"})
+	})
+
+	ensure.Run("serving after closing returns ErrNodeClosed", func(ensure ensurepkg.Ensure) {
+		listeners := listen(ensure, 1)
+		node := cluster.NewNode[*Message](eventbus.New[*Message](), &cluster.Config[*Message]{})
+		ensure(node.Close()).IsNotError()
+		ensure(node.Serve(listeners[0])).IsError(cluster.ErrNodeClosed)
+	})
+}
+
+func listen(ensure ensurepkg.Ensure, count int) []net.Listener {
+	ensure.T().Helper()
+
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		ensure(err).IsNotError()
+		ensure.T().Cleanup(func() { listener.Close() })
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners
+}
+
+func addresses(listeners []net.Listener) []string {
+	addresses := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		addresses = append(addresses, listener.Addr().String())
+	}
+
+	return addresses
+}
+
+func startNode(ensure ensurepkg.Ensure, listener net.Listener, peers []string) (*cluster.Node[*Message], *eventbus.EventBus[*Message]) {
+	bus := eventbus.New[*Message]()
+	node := cluster.NewNode[*Message](bus, &cluster.Config[*Message]{
+		Peers:             peers,
+		MinReconnectDelay: 10 * time.Millisecond,
+		MaxReconnectDelay: 50 * time.Millisecond,
+		OnError:           func(err error) { ensure.T().Log(err) },
+	})
+	ensure.T().Cleanup(func() { node.Close() })
+
+	if listener != nil {
+		go node.Serve(listener) //nolint:errcheck // Closed by the cleanup
+	}
+
+	return node, bus
+}
+
+func peerInfo(node *cluster.Node[*Message], peerID string) cluster.PeerInfo {
+	for _, info := range node.Peers() {
+		if info.ID == peerID {
+			return info
+		}
+	}
+
+	return cluster.PeerInfo{}
+}
+
+func peerTopics(node *cluster.Node[*Message], peerID string) string {
+	topics := ""
+	for i, topicKey := range peerInfo(node, peerID).Topics {
+		if i > 0 {
+			topics += ","
+		}
+
+		topics += topicKey
+	}
+
+	return topics
+}
+
+func receiveIDs(sub *eventbus.Subscription[*Message], count int) []string {
+	ids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		ids = append(ids, (<-sub.Channel()).ID)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+func ensureNoEvent(ensure ensurepkg.Ensure, subs ...*eventbus.Subscription[*Message]) {
+	ensure.T().Helper()
+	time.Sleep(50 * time.Millisecond)
+
+	for _, sub := range subs {
+		select {
+		case event := <-sub.Channel():
+			ensure.T().Fatalf("unexpected event: %+v", event)
+		default:
+		}
+	}
+}
+
+func waitFor(condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,285 @@
+package cluster
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JosiahWitt/eventbus/internal/wire"
+)
+
+// The kinds of messages sent between nodes.
+const (
+	messageHello       byte = iota + 1 // Identifies the node with the ID in the data
+	messageInterest                    // Lists topics that gained subscriptions on the node
+	messageDisinterest                 // Lists topics that lost all of their subscriptions on the node
+	messagePublish                     // Publishes the event to the topics on the receiving node
+)
+
+var (
+	// errSelfLink is returned by runLink when the node connected to itself.
+	errSelfLink = errors.New("cluster: connected to self")
+
+	// errLinkEnded is returned by runLink when an established connection ends.
+	errLinkEnded = errors.New("cluster: connection ended")
+)
+
+// duplicateLinkError is returned by runLink when the node is already connected to the peer.
+type duplicateLinkError struct {
+	// existingDone is closed once the existing connection ends.
+	existingDone <-chan struct{}
+}
+
+func (e *duplicateLinkError) Error() string {
+	return "cluster: already connected to peer"
+}
+
+// peer is a connection to another node.
+type peer[Event any] struct {
+	node   *Node[Event]
+	id     string
+	dialer string // ID of the node that dialed the connection
+	conn   net.Conn
+
+	writeMu sync.Mutex
+
+	mu       sync.RWMutex
+	interest map[string]bool
+
+	eventsSent     uint64
+	eventsReceived uint64
+
+	done chan struct{}
+}
+
+// runLink performs the handshake on the connection, and then handles its messages until it fails.
+func (n *Node[Event]) runLink(conn net.Conn, dialed bool) error {
+	defer n.untrackConn(conn)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	peerID, err := n.handshake(conn, reader)
+	if err != nil {
+		return err
+	}
+
+	if peerID == n.id {
+		return errSelfLink
+	}
+
+	p := &peer[Event]{
+		node:     n,
+		id:       peerID,
+		dialer:   peerID,
+		conn:     conn,
+		interest: make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+
+	if dialed {
+		p.dialer = n.id
+	}
+
+	// Messages are read before registering, since the peer sends its interest while we send ours
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		p.readMessages(reader)
+	}()
+
+	existing := n.registerPeer(p)
+	if existing != nil {
+		conn.Close()
+		<-readDone
+		close(p.done)
+
+		return &duplicateLinkError{existingDone: existing.done}
+	}
+
+	<-readDone
+	n.unregisterPeer(p)
+	close(p.done)
+
+	return errLinkEnded
+}
+
+func (n *Node[Event]) handshake(conn net.Conn, reader *bufio.Reader) (string, error) {
+	conn.SetDeadline(time.Now().Add(n.handshakeTimeoutOrDefault())) //nolint:errcheck // Failures surface when reading
+	defer conn.SetDeadline(time.Time{})                             //nolint:errcheck
+
+	if err := wire.Write(conn, &wire.Message{Kind: messageHello, Data: []byte(n.id)}); err != nil {
+		return "", fmt.Errorf("cluster: unable to send hello: %w", err)
+	}
+
+	msg, err := wire.Read(reader, n.maxMessageSizeOrDefault())
+	if err != nil {
+		return "", fmt.Errorf("cluster: unable to receive hello: %w", err)
+	}
+
+	if msg.Kind != messageHello || len(msg.Data) == 0 {
+		return "", fmt.Errorf("cluster: expected hello, got message kind %d", msg.Kind)
+	}
+
+	return string(msg.Data), nil
+}
+
+// registerPeer adds the peer, and sends it the topics with local subscriptions.
+// If the node is already connected to the peer, only one of the connections is kept.
+// The connection dialed by the node with the lower ID is preferred, so both nodes keep the same connection.
+// It returns the existing peer if the new one was rejected.
+func (n *Node[Event]) registerPeer(p *peer[Event]) *peer[Event] {
+	n.interestMu.Lock()
+	defer n.interestMu.Unlock()
+
+	n.mu.Lock()
+	if existing, ok := n.peers[p.id]; ok {
+		preferredDialer := n.id
+		if p.id < preferredDialer {
+			preferredDialer = p.id
+		}
+
+		if existing.dialer == preferredDialer && p.dialer != preferredDialer {
+			n.mu.Unlock()
+			return existing
+		}
+
+		existing.conn.Close()
+	}
+
+	n.peers[p.id] = p
+	n.mu.Unlock()
+
+	topicKeys := make([]string, 0, len(n.advertised))
+	for topicKey := range n.advertised {
+		topicKeys = append(topicKeys, topicKey)
+	}
+
+	p.sendInterest(messageInterest, topicKeys)
+
+	return nil
+}
+
+func (n *Node[Event]) unregisterPeer(p *peer[Event]) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// The peer may have been replaced by a newer connection
+	if n.peers[p.id] == p {
+		delete(n.peers, p.id)
+	}
+}
+
+func (p *peer[Event]) readMessages(reader *bufio.Reader) {
+	n := p.node
+
+	for {
+		msg, err := wire.Read(reader, n.maxMessageSizeOrDefault())
+		if err != nil {
+			if !n.closed() && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				n.onError(fmt.Errorf("cluster: unable to read from peer %s: %w", p.id, err))
+			}
+
+			p.conn.Close()
+			return
+		}
+
+		switch msg.Kind {
+		case messageInterest, messageDisinterest:
+			p.mu.Lock()
+			for _, topicKey := range msg.TopicKeys {
+				if msg.Kind == messageInterest {
+					p.interest[topicKey] = true
+				} else {
+					delete(p.interest, topicKey)
+				}
+			}
+			p.mu.Unlock()
+
+		case messagePublish:
+			event, err := n.codec().Decode(msg.Data)
+			if err != nil {
+				n.onError(fmt.Errorf("cluster: unable to decode event from peer %s: %w", p.id, err))
+				continue
+			}
+
+			atomic.AddUint64(&p.eventsReceived, 1)
+
+			// Events from peers are never forwarded, which prevents loops
+			n.bus.Publish(event, msg.TopicKeys...)
+
+		default:
+			n.onError(fmt.Errorf("cluster: unexpected message kind %d from peer %s", msg.Kind, p.id))
+		}
+	}
+}
+
+// interestingTopics returns the topics that have subscriptions on the peer.
+func (p *peer[Event]) interestingTopics(topicKeys []string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var interesting []string
+	for _, topicKey := range topicKeys {
+		if p.interest[topicKey] {
+			interesting = append(interesting, topicKey)
+		}
+	}
+
+	return interesting
+}
+
+func (p *peer[Event]) sendEvent(topicKeys []string, data []byte) {
+	if p.send(&wire.Message{Kind: messagePublish, TopicKeys: topicKeys, Data: data}) {
+		atomic.AddUint64(&p.eventsSent, 1)
+	}
+}
+
+func (p *peer[Event]) sendInterest(kind byte, topicKeys []string) {
+	if len(topicKeys) > 0 {
+		p.send(&wire.Message{Kind: kind, TopicKeys: topicKeys})
+	}
+}
+
+// send writes the message, closing the connection if it fails, which reconnects if the node dialed the peer.
+func (p *peer[Event]) send(msg *wire.Message) bool {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	p.conn.SetWriteDeadline(time.Now().Add(p.node.writeTimeoutOrDefault())) //nolint:errcheck // Failures surface when writing
+
+	if err := wire.Write(p.conn, msg); err != nil {
+		if !p.node.closed() && !errors.Is(err, net.ErrClosed) {
+			p.node.onError(fmt.Errorf("cluster: unable to write to peer %s: %w", p.id, err))
+		}
+
+		p.conn.Close()
+		return false
+	}
+
+	return true
+}
+
+func (p *peer[Event]) info() PeerInfo {
+	p.mu.RLock()
+	topicKeys := make([]string, 0, len(p.interest))
+	for topicKey := range p.interest {
+		topicKeys = append(topicKeys, topicKey)
+	}
+	p.mu.RUnlock()
+
+	sort.Strings(topicKeys)
+
+	return PeerInfo{
+		ID:             p.id,
+		Topics:         topicKeys,
+		EventsSent:     atomic.LoadUint64(&p.eventsSent),
+		EventsReceived: atomic.LoadUint64(&p.eventsReceived),
+	}
+}
@@ -0,0 +1,134 @@
+// Package codec encodes and decodes events, so every integration that serializes events,
+// such as persistence and network bridges, can share the same encoding.
+//
+// Codecs for JSON, gob and encoding.BinaryMarshaler events are included,
+// along with Versioned, which allows the encoding to change over time,
+// and framing helpers for writing a stream of encoded events.
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnsupported is matched by the errors returned when a codec does not support the event's type.
+var ErrUnsupported = errors.New("codec: unsupported event type")
+
+// Codec encodes and decodes events.
+// Implementations must be safe for concurrent use.
+type Codec[Event any] interface {
+	// Encode encodes the event.
+	Encode(event Event) ([]byte, error)
+
+	// Decode decodes an event from data returned by Encode.
+	Decode(data []byte) (Event, error)
+}
+
+// Funcs adapts a pair of functions into a Codec.
+type Funcs[Event any] struct {
+	// EncodeFunc encodes the event. If not set, encoding fails with ErrUnsupported.
+	EncodeFunc func(event Event) ([]byte, error)
+
+	// DecodeFunc decodes the event. If not set, decoding fails with ErrUnsupported.
+	DecodeFunc func(data []byte) (Event, error)
+}
+
+var _ Codec[any] = Funcs[any]{}
+
+// Encode encodes the event using EncodeFunc.
+func (f Funcs[Event]) Encode(event Event) ([]byte, error) {
+	if f.EncodeFunc == nil {
+		return nil, fmt.Errorf("%w: encoding is not supported", ErrUnsupported)
+	}
+
+	return f.EncodeFunc(event)
+}
+
+// Decode decodes the event using DecodeFunc.
+func (f Funcs[Event]) Decode(data []byte) (Event, error) {
+	if f.DecodeFunc == nil {
+		var event Event
+		return event, fmt.Errorf("%w: decoding is not supported", ErrUnsupported)
+	}
+
+	return f.DecodeFunc(data)
+}
+
+// JSON encodes events as JSON.
+type JSON[Event any] struct{}
+
+var _ Codec[any] = JSON[any]{}
+
+// Encode encodes the event as JSON.
+func (JSON[Event]) Encode(event Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Decode decodes the event from JSON.
+func (JSON[Event]) Decode(data []byte) (Event, error) {
+	var event Event
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
+// Gob encodes events using encoding/gob.
+// Each event is encoded on its own, so it includes the description of its type.
+type Gob[Event any] struct{}
+
+var _ Codec[any] = Gob[any]{}
+
+// Encode encodes the event using encoding/gob.
+func (Gob[Event]) Encode(event Event) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(&event)
+	return buf.Bytes(), err
+}
+
+// Decode decodes the event using encoding/gob.
+func (Gob[Event]) Decode(data []byte) (Event, error) {
+	var event Event
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&event)
+	return event, err
+}
+
+// Binary encodes events that implement encoding.BinaryMarshaler,
+// and decodes events that implement encoding.BinaryUnmarshaler.
+// When the event is a pointer, a new value is allocated for each decoded event.
+// Otherwise, the pointer to the event must implement encoding.BinaryUnmarshaler.
+type Binary[Event any] struct{}
+
+var _ Codec[any] = Binary[any]{}
+
+// Encode encodes the event using its MarshalBinary method.
+func (Binary[Event]) Encode(event Event) ([]byte, error) {
+	marshaler, ok := any(event).(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T does not implement encoding.BinaryMarshaler", ErrUnsupported, event)
+	}
+
+	return marshaler.MarshalBinary()
+}
+
+// Decode decodes the event using its UnmarshalBinary method.
+func (Binary[Event]) Decode(data []byte) (Event, error) {
+	var event Event
+	target := any(&event)
+
+	if typ := reflect.TypeOf(&event).Elem(); typ.Kind() == reflect.Pointer {
+		event = reflect.New(typ.Elem()).Interface().(Event) //nolint:forcetypeassert // The type matches
+		target = event
+	}
+
+	unmarshaler, ok := target.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return event, fmt.Errorf("%w: %T does not implement encoding.BinaryUnmarshaler", ErrUnsupported, target)
+	}
+
+	err := unmarshaler.UnmarshalBinary(data)
+	return event, err
+}
@@ -0,0 +1,134 @@
+package codec_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+type Message struct {
+	ID   string
+	Body string
+}
+
+type Note struct {
+	Title string
+	Body  string
+}
+
+func (n *Note) MarshalBinary() ([]byte, error) {
+	return []byte(n.Title + "\n" + n.Body), nil
+}
+
+func (n *Note) UnmarshalBinary(data []byte) error {
+	title, body, ok := strings.Cut(string(data), "\n")
+	if !ok {
+		return errors.New("missing separator")
+	}
+
+	n.Title, n.Body = title, body
+	return nil
+}
+
+type Counter uint32
+
+func (c Counter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, uint32(c))
+	return data, nil
+}
+
+func (c *Counter) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return errors.New("invalid length")
+	}
+
+	*c = Counter(binary.BigEndian.Uint32(data))
+	return nil
+}
+
+func TestCodecs(t *testing.T) {
+	ensure := ensure.New(t)
+
+	codecs := map[string]codec.Codec[*Message]{
+		"json":      codec.JSON[*Message]{},
+		"gob":       codec.Gob[*Message]{},
+		"versioned": &codec.Versioned[*Message]{Version: 2, Codec: codec.JSON[*Message]{}},
+	}
+
+	for name, c := range codecs {
+		c := c
+
+		ensure.Run(name+" round trips events", func(ensure ensurepkg.Ensure) {
+			data, err := c.Encode(&Message{ID: "1", Body: "hello"})
+			ensure(err).IsNotError()
+
+			event, err := c.Decode(data)
+			ensure(err).IsNotError()
+			ensure(event).Equals(&Message{ID: "1", Body: "hello"})
+		})
+
+		ensure.Run(name+" fails to decode invalid data", func(ensure ensurepkg.Ensure) {
+			_, err := c.Decode([]byte("invalid"))
+			ensure(err != nil).IsTrue()
+		})
+	}
+
+	ensure.Run("binary round trips pointers to unmarshalers", func(ensure ensurepkg.Ensure) {
+		data, err := codec.Binary[*Note]{}.Encode(&Note{Title: "1", Body: "hello"})
+		ensure(err).IsNotError()
+		ensure(string(data)).Equals("1\nhello")
+
+		note, err := codec.Binary[*Note]{}.Decode(data)
+		ensure(err).IsNotError()
+		ensure(note).Equals(&Note{Title: "1", Body: "hello"})
+
+		_, err = codec.Binary[*Note]{}.Decode([]byte("invalid"))
+		ensure(err != nil).IsTrue()
+	})
+
+	ensure.Run("binary round trips values whose pointers are unmarshalers", func(ensure ensurepkg.Ensure) {
+		data, err := codec.Binary[Counter]{}.Encode(42)
+		ensure(err).IsNotError()
+
+		counter, err := codec.Binary[Counter]{}.Decode(data)
+		ensure(err).IsNotError()
+		ensure(counter).Equals(Counter(42))
+	})
+
+	ensure.Run("binary fails for events that are not marshalers", func(ensure ensurepkg.Ensure) {
+		_, err := codec.Binary[string]{}.Encode("hello")
+		ensure(err).IsError(codec.ErrUnsupported)
+
+		_, err = codec.Binary[string]{}.Decode([]byte("hello"))
+		ensure(err).IsError(codec.ErrUnsupported)
+	})
+
+	ensure.Run("funcs uses the functions", func(ensure ensurepkg.Ensure) {
+		c := codec.Funcs[string]{
+			EncodeFunc: func(event string) ([]byte, error) { return []byte(strings.ToUpper(event)), nil },
+			DecodeFunc: func(data []byte) (string, error) { return strings.ToLower(string(data)), nil },
+		}
+
+		data, err := c.Encode("hello")
+		ensure(err).IsNotError()
+		ensure(string(data)).Equals("HELLO")
+
+		event, err := c.Decode(data)
+		ensure(err).IsNotError()
+		ensure(event).Equals("hello")
+	})
+
+	ensure.Run("funcs without functions are unsupported", func(ensure ensurepkg.Ensure) {
+		_, err := codec.Funcs[string]{}.Encode("hello")
+		ensure(err).IsError(codec.ErrUnsupported)
+
+		_, err = codec.Funcs[string]{}.Decode([]byte("hello"))
+		ensure(err).IsError(codec.ErrUnsupported)
+	})
+}
@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrFrameTooLarge is returned by ReadFrame when the frame is larger than the maximum size.
+var ErrFrameTooLarge = errors.New("codec: frame too large")
+
+// frameChunkSize is the size of frames that are allocated up front.
+// Larger frames are read as they arrive, so a frame's header can't allocate more memory than the data that was sent.
+const frameChunkSize = 64 << 10
+
+// WriteFrame writes the data to the writer, prefixed by its length as a 4 byte big endian integer,
+// using a single call to Write.
+func WriteFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads the data of the next frame written by WriteFrame.
+// If maxSize is positive, frames larger than it return ErrFrameTooLarge without reading their data.
+// Otherwise, memory for large frames is only allocated as their data is read.
+// It returns io.EOF if the reader ends before the frame starts, or io.ErrUnexpectedEOF if it ends within the frame.
+func ReadFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if maxSize > 0 && size > uint32(maxSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	if size <= frameChunkSize {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		return data, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, frameChunkSize))
+	if _, err := io.CopyN(buf, r, int64(size)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Encoder writes a stream of framed events.
+type Encoder[Event any] struct {
+	w     io.Writer
+	codec Codec[Event]
+}
+
+// NewEncoder creates an Encoder writing events encoded by the codec to the writer.
+func NewEncoder[Event any](w io.Writer, codec Codec[Event]) *Encoder[Event] {
+	return &Encoder[Event]{w: w, codec: codec}
+}
+
+// Encode writes the event as the next frame.
+func (e *Encoder[Event]) Encode(event Event) error {
+	data, err := e.codec.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	return WriteFrame(e.w, data)
+}
+
+// Decoder reads a stream of framed events written by an Encoder.
+type Decoder[Event any] struct {
+	r       io.Reader
+	codec   Codec[Event]
+	maxSize int
+}
+
+// NewDecoder creates a Decoder reading events from the reader, which are decoded by the codec.
+// If maxSize is positive, frames larger than it return ErrFrameTooLarge.
+func NewDecoder[Event any](r io.Reader, codec Codec[Event], maxSize int) *Decoder[Event] {
+	return &Decoder[Event]{r: r, codec: codec, maxSize: maxSize}
+}
+
+// Decode reads the next event. It returns io.EOF once the stream ends.
+func (d *Decoder[Event]) Decode() (Event, error) {
+	data, err := ReadFrame(d.r, d.maxSize)
+	if err != nil {
+		var event Event
+		return event, err
+	}
+
+	return d.codec.Decode(data)
+}
@@ -0,0 +1,90 @@
+package codec_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+func TestFrames(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("round trips frames", func(ensure ensurepkg.Ensure) {
+		buf := &bytes.Buffer{}
+		ensure(codec.WriteFrame(buf, []byte("hello"))).IsNotError()
+		ensure(codec.WriteFrame(buf, nil)).IsNotError()
+		ensure(buf.Bytes()[:4]).Equals([]byte{0, 0, 0, 5})
+
+		data, err := codec.ReadFrame(buf, 0)
+		ensure(err).IsNotError()
+		ensure(string(data)).Equals("hello")
+
+		data, err = codec.ReadFrame(buf, 0)
+		ensure(err).IsNotError()
+		ensure(len(data)).Equals(0)
+
+		_, err = codec.ReadFrame(buf, 0)
+		ensure(err).IsError(io.EOF)
+	})
+
+	ensure.Run("rejects frames larger than the maximum size", func(ensure ensurepkg.Ensure) {
+		buf := &bytes.Buffer{}
+		ensure(codec.WriteFrame(buf, []byte("hello"))).IsNotError()
+
+		_, err := codec.ReadFrame(buf, 4)
+		ensure(err).IsError(codec.ErrFrameTooLarge)
+	})
+
+	ensure.Run("reports truncated frames", func(ensure ensurepkg.Ensure) {
+		_, err := codec.ReadFrame(bytes.NewReader([]byte{0, 0, 0, 5, 'h'}), 0)
+		ensure(err).IsError(io.ErrUnexpectedEOF)
+	})
+
+	ensure.Run("round trips frames larger than a chunk", func(ensure ensurepkg.Ensure) {
+		expected := bytes.Repeat([]byte("0123456789"), 20000)
+
+		buf := &bytes.Buffer{}
+		ensure(codec.WriteFrame(buf, expected)).IsNotError()
+
+		data, err := codec.ReadFrame(buf, -1)
+		ensure(err).IsNotError()
+		ensure(data).Equals(expected)
+	})
+
+	ensure.Run("only allocates memory for the data that was sent", func(ensure ensurepkg.Ensure) {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		// The header claims the frame is 4 GiB, but only a few bytes are sent
+		_, err := codec.ReadFrame(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 'h', 'i'}), -1)
+		ensure(err).IsError(io.ErrUnexpectedEOF)
+
+		runtime.ReadMemStats(&after)
+		ensure(after.TotalAlloc-before.TotalAlloc < 1<<20).IsTrue()
+	})
+
+	ensure.Run("streams events with encoders and decoders", func(ensure ensurepkg.Ensure) {
+		buf := &bytes.Buffer{}
+		encoder := codec.NewEncoder[*Message](buf, codec.JSON[*Message]{})
+		ensure(encoder.Encode(&Message{ID: "1"})).IsNotError()
+		ensure(encoder.Encode(&Message{ID: "2"})).IsNotError()
+
+		decoder := codec.NewDecoder[*Message](buf, codec.JSON[*Message]{}, 0)
+
+		event, err := decoder.Decode()
+		ensure(err).IsNotError()
+		ensure(event).Equals(&Message{ID: "1"})
+
+		event, err = decoder.Decode()
+		ensure(err).IsNotError()
+		ensure(event).Equals(&Message{ID: "2"})
+
+		_, err = decoder.Decode()
+		ensure(err).IsError(io.EOF)
+	})
+}
@@ -0,0 +1,63 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownVersion is matched by the errors returned when decoding a payload with an unknown version.
+var ErrUnknownVersion = errors.New("codec: unknown payload version")
+
+// Versioned prefixes the encoded events with a version, so the encoding can change over time
+// while events encoded by older versions, such as persisted events, can still be decoded.
+//
+// Payloads start with the version as a uvarint, followed by the data of the codec for that version.
+type Versioned[Event any] struct {
+	// Version of newly encoded events.
+	Version uint64
+
+	// Codec encodes events, and decodes events with the current Version.
+	// It is required.
+	Codec Codec[Event]
+
+	// Previous decodes events encoded by earlier versions, by version.
+	// Their Encode methods are never called, so Funcs with only a DecodeFunc can be used.
+	Previous map[uint64]Codec[Event]
+}
+
+var _ Codec[any] = &Versioned[any]{}
+
+// Encode encodes the event with the Codec, prefixed by the Version.
+func (v *Versioned[Event]) Encode(event Event) ([]byte, error) {
+	data, err := v.Codec.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	payload = append(payload[:binary.PutUvarint(payload, v.Version)], data...)
+
+	return payload, nil
+}
+
+// Decode decodes the event with the codec for the payload's version.
+func (v *Versioned[Event]) Decode(payload []byte) (Event, error) {
+	version, n := binary.Uvarint(payload)
+	if n <= 0 {
+		var event Event
+		return event, errors.New("codec: payload is missing its version")
+	}
+
+	data := payload[n:]
+	if version == v.Version {
+		return v.Codec.Decode(data)
+	}
+
+	if codec, ok := v.Previous[version]; ok {
+		return codec.Decode(data)
+	}
+
+	var event Event
+	return event, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+}
@@ -0,0 +1,50 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+func TestVersioned(t *testing.T) {
+	ensure := ensure.New(t)
+
+	v1 := &codec.Versioned[*Message]{Version: 1, Codec: codec.Gob[*Message]{}}
+	v2 := &codec.Versioned[*Message]{
+		Version: 2,
+		Codec:   codec.JSON[*Message]{},
+		Previous: map[uint64]codec.Codec[*Message]{
+			1: codec.Gob[*Message]{},
+		},
+	}
+
+	ensure.Run("prefixes the payload with the version", func(ensure ensurepkg.Ensure) {
+		data, err := v2.Encode(&Message{ID: "1"})
+		ensure(err).IsNotError()
+		ensure(string(data)).Equals("\x02" + `{"ID":"1","Body":""}`)
+	})
+
+	ensure.Run("decodes payloads of previous versions", func(ensure ensurepkg.Ensure) {
+		data, err := v1.Encode(&Message{ID: "1", Body: "old"})
+		ensure(err).IsNotError()
+
+		event, err := v2.Decode(data)
+		ensure(err).IsNotError()
+		ensure(event).Equals(&Message{ID: "1", Body: "old"})
+	})
+
+	ensure.Run("fails to decode unknown versions", func(ensure ensurepkg.Ensure) {
+		data, err := v2.Encode(&Message{ID: "1"})
+		ensure(err).IsNotError()
+
+		_, err = v1.Decode(data)
+		ensure(err).IsError(codec.ErrUnknownVersion)
+	})
+
+	ensure.Run("fails to decode payloads without a version", func(ensure ensurepkg.Ensure) {
+		_, err := v2.Decode(nil)
+		ensure(err != nil).IsTrue()
+	})
+}
@@ -0,0 +1,60 @@
+package eventbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrWouldBlock is matched by the error returned from TryPublish when publishing would have blocked.
+	ErrWouldBlock = errors.New("eventbus: publishing would block")
+
+	// ErrBusClosed is returned when using an EventBus that has been closed.
+	ErrBusClosed = errors.New("eventbus: bus is closed")
+
+	// ErrSubscriptionClosed is returned by Subscription.Receive once the subscription is unsubscribed.
+	ErrSubscriptionClosed = errors.New("eventbus: subscription is closed")
+
+	// ErrSubscriptionOverflowed is returned by Subscription.Receive once the subscription is unsubscribed,
+	// because its buffer overflowed. See SubscriptionConfig.DisconnectOnOverflow.
+	ErrSubscriptionOverflowed = errors.New("eventbus: subscription overflowed")
+
+	// ErrSubscriptionStalled is matched by the error returned from Subscription.Receive once the subscription
+	// is disconnected by a watchdog, because it stalled. See WatchdogConfig.Disconnect.
+	ErrSubscriptionStalled = errors.New("eventbus: subscription stalled")
+)
+
+// WouldBlockError is returned from TryPublish when some subscriptions could not be notified without blocking.
+type WouldBlockError[Event any] struct {
+	// Subscriptions whose channel's buffer was full, so they were not notified of the event.
+	Subscriptions []*Subscription[Event]
+}
+
+func (e *WouldBlockError[Event]) Error() string {
+	return fmt.Sprintf("%v: %d subscription(s) are full", ErrWouldBlock, len(e.Subscriptions))
+}
+
+// Is allows the error to match ErrWouldBlock when using errors.Is.
+func (e *WouldBlockError[Event]) Is(target error) bool {
+	return target == ErrWouldBlock
+}
+
+// StalledError is returned by Subscription.Receive once the subscription is disconnected by a watchdog,
+// because it stalled. It matches ErrSubscriptionStalled when using errors.Is.
+type StalledError struct {
+	// Duration the subscription was stalled for when it was disconnected.
+	Duration time.Duration
+
+	// QueueDepth is the number of events that were waiting in the subscription's channel.
+	QueueDepth int
+}
+
+func (e *StalledError) Error() string {
+	return fmt.Sprintf("%v for %v with %d event(s) waiting", ErrSubscriptionStalled, e.Duration, e.QueueDepth)
+}
+
+// Is allows the error to match ErrSubscriptionStalled when using errors.Is.
+func (e *StalledError) Is(target error) bool {
+	return target == ErrSubscriptionStalled
+}
@@ -2,35 +2,151 @@
 package eventbus
 
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/JosiahWitt/eventbus/internal/shardedmap"
 	"github.com/JosiahWitt/eventbus/internal/typedsyncmap"
 )
 
 // DefaultBufferSize for the subscription channels. Used when the BufferSize is not configured.
 const DefaultBufferSize = 10
 
+// waitIndefinitely is passed to deliver to wait until there is room in the subscription's channel.
+const waitIndefinitely time.Duration = -1
+
 // Config can be passed to NewWithConfig to customize the EventBus.
 type Config struct {
 	// BufferSize for the subscription channels.
 	// If not set or zero, it defaults to DefaultBufferSize.
 	// If negative, it creates the channels with no buffer.
 	BufferSize int
+
+	// PublishTimeout is how long publishing waits for room in a subscription's channel.
+	// If the timeout elapses, the event is dropped for that subscription.
+	// If not set or zero, publishing waits indefinitely.
+	PublishTimeout time.Duration
+
+	// AsyncQueueSize is the number of events that can be waiting in the queue used by PublishAsync.
+	// If not set or zero, it defaults to DefaultAsyncQueueSize.
+	// If negative, it creates the queue with no buffer.
+	AsyncQueueSize int
+
+	// AsyncDispatchers is the number of goroutines delivering the events queued by PublishAsync.
+	// If not set or less than one, it defaults to one, which preserves the order of asynchronously published events.
+	AsyncDispatchers int
+
+	// ParallelFanoutThreshold enables parallel fanout for topics with more subscriptions than the threshold.
+	// The topic's subscriptions are partitioned across a pool of FanoutWorkers goroutines, which deliver concurrently.
+	// Publishing still waits for all partitions to be delivered, so each subscription receives the events
+	// from a single publisher in order.
+	// If not set or zero, subscriptions are always delivered to sequentially.
+	ParallelFanoutThreshold int
+
+	// FanoutWorkers is the number of goroutines used for parallel fanout.
+	// If not set or less than one, it defaults to runtime.GOMAXPROCS(0).
+	FanoutWorkers int
+
+	// TopicShards enables a sharded topic registry with the number of shards, instead of the default sync.Map.
+	// Each shard is guarded by its own lock, so creating and deleting topics never needs to be retried,
+	// which can perform better when topics are frequently created and deleted.
+	// It also allows Topics to return a consistent snapshot.
+	// If not set or zero, topics are stored in a sync.Map.
+	TopicShards int
+
+	// Logger receives diagnostics from the bus, such as topics being created and deleted, subscriptions,
+	// blocked deliveries, drops, and shutdown. A *slog.Logger can be used.
+	// If not set, nothing is logged.
+	Logger Logger
+}
+
+// SubscriptionConfig can be passed to SubscribeWithConfig to customize the Subscription.
+type SubscriptionConfig[Event any] struct {
+	// ConflationKey enables conflation when set, which is useful when only the latest value for each key matters.
+	// While events are waiting to be received, the subscription holds at most one pending event per key.
+	// Newer events replace the pending event with the same key in place, preserving the position of the first arrival.
+	//
+	// Conflated subscriptions never block Publish, and their channels are created with no buffer,
+	// since the pending events are held by the subscription instead.
+	ConflationKey func(event Event) string
+
+	// Debounce delays delivering an event until no other event with the same rate limit key
+	// has been received for the duration. Only the latest event is delivered once the quiet period elapses.
+	// If not set or zero, events are not debounced.
+	Debounce time.Duration
+
+	// Throttle delivers at most one event with the same rate limit key per interval of the duration.
+	// Which events are delivered is configured by ThrottleMode.
+	// If not set or zero, events are not throttled.
+	//
+	// If both Debounce and Throttle are set, the debounced events are throttled.
+	Throttle time.Duration
+
+	// ThrottleMode configures which events are delivered by a throttled subscription.
+	// If not set, it defaults to ThrottleLeading.
+	ThrottleMode ThrottleMode
+
+	// RateLimitKey groups events for Debounce and Throttle.
+	// If not set, events are grouped by the topic they were published to.
+	//
+	// Like conflated subscriptions, debounced and throttled subscriptions never block Publish,
+	// and their channels are created with no buffer.
+	RateLimitKey func(event Event) string
+
+	// DisconnectOnOverflow unsubscribes the subscription when an event can't be delivered
+	// because its channel's buffer is full, instead of waiting for room.
+	// Afterwards, Err and Receive return ErrSubscriptionOverflowed.
+	// It allows slow consumers to be cut off without blocking Publish.
+	// It has no effect on conflated, debounced, and throttled subscriptions, since they never block Publish.
+	DisconnectOnOverflow bool
+
+	// Name identifies the subscription in the bus's logs.
+	Name string
 }
 
 // EventBus is a straightforward concurrent EventBus for Go 1.18+, supporting fanout, and in order, only once delivery.
 //
 // It can be used by initializing a copy of the struct, or by calling the New or NewWithConfig functions.
 type EventBus[Event any] struct {
-	topics typedsyncmap.Map[string, *topic[Event]]
+	topics        typedsyncmap.Map[string, *topic[Event]]
+	shardedTopics *shardedmap.Map[*topic[Event]] // Used instead of topics when set
+
+	async  asyncPublisher[Event]
+	fanout fanoutPool[Event]
+
+	lastSubscriptionID uint64
+
+	watchersMu sync.Mutex
+	watchers   atomic.Value // []*topicWatcher, replaced with a copy when changed
 
-	rawBufferSize int
+	closedMu sync.Mutex
+	closed   chan struct{} // Created on first use, and closed by Close
+	drained  chan struct{} // Created on first use, and closed by Close once the events queued by PublishAsync are delivered
+	isClosed bool
+
+	publishingClosed int32 // Set atomically by Close, so publishing can check it without locking
+
+	rawBufferSize       int
+	rawPublishTimeout   time.Duration
+	rawAsyncQueueSize   int
+	rawAsyncDispatchers int
+
+	rawParallelFanoutThreshold int
+	rawFanoutWorkers           int
+
+	logger      Logger
+	formatTopic func(topicKey string) string // Formats the topic keys in diagnostics, if set
 }
 
 type topic[Event any] struct {
-	key  string
-	subs map[*Subscription[Event]]*Subscription[Event]
-	mu   sync.RWMutex
+	key string
+
+	// subs holds a []*Subscription[Event] sorted by subscription ID, so publishing can read it without locking.
+	// The slice is never modified; changes replace it with a copy behind the mutex.
+	subs atomic.Value
+	mu   sync.Mutex
 
 	bus *EventBus[Event]
 
@@ -40,11 +156,36 @@ type topic[Event any] struct {
 // Subscription maintains subscriptions to multiple topics.
 // Events are sent to the Channel().
 type Subscription[Event any] struct {
-	ch chan Event
-	mu sync.Mutex
+	id   uint64
+	sent uint64 // Number of events sent to the channel, set atomically so the watchdog can tell whether they are received
+	ch   chan Event
+	mu   sync.Mutex
+
+	// Publishing holds a read lock while sending to the channel, so it isn't closed during a send.
+	// The done channel is closed first to stop any sends that are waiting for room in the channel.
+	sendMu   sync.RWMutex
+	done     chan struct{}
+	isClosed bool
+
+	queue        *queue[Event]
+	rateLimiters rateLimiters[Event]
+	rateLimitKey func(event Event) string
+
+	disconnectOnOverflow bool
+	isOverflowed         int32 // Set atomically once the subscription overflows, so it is only disconnected once
+	err                  error // Why the subscription was closed, guarded by mu
 
+	name      string
+	isBlocked int32 // Set atomically while a delivery is blocked, so it is only logged once
+	waiting   int32 // Number of deliveries waiting for room in the channel, set atomically
+
+	bus    *EventBus[Event]
 	topics []*topic[Event]
 	self   *Subscription[Event]
+
+	// topicKeys holds a []string of the subscribed topic keys, so they can be listed without locking.
+	// The slice is never modified; changes replace it with a copy while holding mu.
+	topicKeys atomic.Value
 }
 
 // New creates a new EventBus.
@@ -54,18 +195,94 @@ func New[Event any]() *EventBus[Event] {
 
 // NewWithConfig creates a new customized EventBus.
 func NewWithConfig[Event any](config *Config) *EventBus[Event] {
-	return &EventBus[Event]{
-		rawBufferSize: config.BufferSize,
+	b := &EventBus[Event]{}
+	b.configure(config)
+
+	return b
+}
+
+// configure applies the config to a new bus.
+func (b *EventBus[Event]) configure(config *Config) {
+	b.rawBufferSize = config.BufferSize
+	b.rawPublishTimeout = config.PublishTimeout
+	b.rawAsyncQueueSize = config.AsyncQueueSize
+	b.rawAsyncDispatchers = config.AsyncDispatchers
+
+	b.rawParallelFanoutThreshold = config.ParallelFanoutThreshold
+	b.rawFanoutWorkers = config.FanoutWorkers
+	b.logger = config.Logger
+
+	if config.TopicShards > 0 {
+		b.shardedTopics = shardedmap.New[*topic[Event]](config.TopicShards)
 	}
 }
 
 // Publish sends the provided event to all of the listed topics.
 // All subscriptions to those topics will be notified of the event.
+// Once the bus is closed, the event is ignored.
 func (b *EventBus[Event]) Publish(event Event, topicKeys ...string) {
-	publishedSubscriptions := map[*Subscription[Event]]bool{}
+	if b.isPublishingClosed() {
+		return
+	}
 
-	for _, topicKey := range topicKeys {
-		b.publishToTopic(topicKey, event, publishedSubscriptions)
+	b.publish(event, topicKeys, b.publishTimeout(), nil)
+}
+
+// TryPublish sends the provided event to all of the listed topics, without blocking.
+// All subscriptions to those topics will be notified of the event, unless their channel's buffer is full.
+//
+// If any subscriptions could not be notified, a *WouldBlockError listing them is returned,
+// which matches ErrWouldBlock when using errors.Is.
+// Once the bus is closed, the event is ignored, and ErrBusClosed is returned.
+func (b *EventBus[Event]) TryPublish(event Event, topicKeys ...string) error {
+	if b.isPublishingClosed() {
+		return ErrBusClosed
+	}
+
+	report := &PublishReport[Event]{}
+	b.publish(event, topicKeys, 0, report)
+
+	if len(report.Dropped) > 0 {
+		return &WouldBlockError[Event]{Subscriptions: report.Dropped}
+	}
+
+	return nil
+}
+
+// Close stops the bus from accepting published events, and waits for the events queued by PublishAsync to be delivered.
+// Events published afterwards are ignored, such as by Publish, or rejected with ErrBusClosed, such as by PublishAsync.
+// Receive returns ErrBusClosed once the bus is closed and the queued events are delivered,
+// after the subscription's buffered events are received.
+// It also stops the goroutines used for parallel fanout, after which fanout is sequential.
+// Calling Close more than once has no effect.
+func (b *EventBus[Event]) Close() {
+	closed := b.closedChan()
+
+	b.closedMu.Lock()
+	wasClosed := b.isClosed
+	if !b.isClosed {
+		b.isClosed = true
+		atomic.StoreInt32(&b.publishingClosed, 1)
+		close(closed)
+	}
+	b.closedMu.Unlock()
+
+	if b.logger != nil && !wasClosed {
+		b.logger.Info("eventbus: closing", "queue_depth", b.asyncQueueDepth(), "topics", b.NumTopics())
+	}
+
+	drained := b.drainedChan()
+	if wasClosed {
+		<-drained // The first call delivers the queued events
+		return
+	}
+
+	b.closeAsync()
+	close(drained)
+	b.closeFanout()
+
+	if b.logger != nil {
+		b.logger.Info("eventbus: closed")
 	}
 }
 
@@ -74,16 +291,41 @@ func (b *EventBus[Event]) Publish(event Event, topicKeys ...string) {
 //
 // If the same event is sent to multiple of the listed topics, the event will only be delivered once.
 func (b *EventBus[Event]) Subscribe(topicKeys ...string) *Subscription[Event] {
+	return b.SubscribeWithConfig(&SubscriptionConfig[Event]{}, topicKeys...)
+}
+
+// SubscribeWithConfig creates a new customized subscription to the listed topics.
+// All events published to any of those topics will be sent to the subscription's channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func (b *EventBus[Event]) SubscribeWithConfig(config *SubscriptionConfig[Event], topicKeys ...string) *Subscription[Event] {
 	sub := &Subscription[Event]{
-		ch: make(chan Event, b.bufferSizeOrDefault()),
+		id:   atomic.AddUint64(&b.lastSubscriptionID, 1),
+		done: make(chan struct{}),
+		bus:  b,
+
+		disconnectOnOverflow: config.DisconnectOnOverflow,
+		name:                 config.Name,
 	}
 	sub.self = sub
 
+	if config.ConflationKey != nil || config.Debounce > 0 || config.Throttle > 0 {
+		sub.ch = make(chan Event)
+		sub.queue = newQueue(sub.ch, config.ConflationKey)
+		sub.rateLimiters = newRateLimiters(config, sub.queue.push)
+		sub.rateLimitKey = config.RateLimitKey
+	} else {
+		sub.ch = make(chan Event, b.bufferSizeOrDefault())
+	}
+
+	sub.topicKeys.Store(append([]string(nil), topicKeys...))
+
 	for _, topicKey := range topicKeys {
-		topic := b.findOrCreateTopic(topicKey)
-		topic.addSubscription(sub)
+		sub.topics = append(sub.topics, b.subscribeToTopic(topicKey, sub))
+	}
 
-		sub.topics = append(sub.topics, topic)
+	if b.logger != nil {
+		b.logger.Debug("eventbus: subscribed", sub.logAttrs("topics", b.topicNames(topicKeys))...)
 	}
 
 	return sub
@@ -91,19 +333,135 @@ func (b *EventBus[Event]) Subscribe(topicKeys ...string) *Subscription[Event] {
 
 // Unsubscribe closes the subscription to the topics.
 // It also closes the subscription's channel.
+// Calling Unsubscribe more than once has no effect.
+//
+// For conflated, debounced, and throttled subscriptions, any pending events that have not been received are discarded.
 func (s *Subscription[Event]) Unsubscribe() {
+	s.unsubscribe(ErrSubscriptionClosed)
+}
+
+// unsubscribe closes the subscription, recording the error returned by Err.
+// It has no effect once the subscription is unsubscribed, so the first error is kept.
+func (s *Subscription[Event]) unsubscribe(err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Remove the subscription from the topics behind a mutex (inside t.removeSubscription)
-	// before closing the channel to prevent writing to a closed channel
+	if s.isUnsubscribed() {
+		return
+	}
+
+	s.err = err
+
+	if s.bus.logger != nil {
+		s.bus.logger.Debug("eventbus: unsubscribed", s.logAttrs("reason", err)...)
+	}
+
 	for _, t := range s.topics {
-		t.removeSubscription(s)
+		t.bus.unsubscribeFromTopic(t, s)
+	}
+
+	// Publishing may have loaded the topic's subscriptions before they were removed,
+	// so wait for any in progress sends to stop before closing the channel to prevent writing to a closed channel
+	close(s.done)
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.isClosed = true
+
+	if s.rateLimiters != nil {
+		s.rateLimiters.stop()
+	}
+
+	if s.queue != nil {
+		s.queue.close() // The queue closes the channel once it stops sending
+		return
 	}
 
 	close(s.ch)
 }
 
+// AddTopics subscribes the subscription to the listed topics, in addition to the topics it is already subscribed to.
+// Topics the subscription is already subscribed to are ignored.
+// It has no effect once the subscription is unsubscribed.
+//
+// Events published while the topics are changing may not be delivered to the subscription,
+// but they are never delivered more than once.
+func (s *Subscription[Event]) AddTopics(topicKeys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isUnsubscribed() {
+		return
+	}
+
+	oldTopicKeys := s.subscribedTopicKeys()
+	newTopicKeys := append([]string(nil), oldTopicKeys...)
+
+	var addedTopicKeys []string
+	for _, topicKey := range topicKeys {
+		if !containsString(newTopicKeys, topicKey) {
+			newTopicKeys = append(newTopicKeys, topicKey)
+			addedTopicKeys = append(addedTopicKeys, topicKey)
+		}
+	}
+
+	s.topicKeys.Store(newTopicKeys)
+
+	for _, topicKey := range addedTopicKeys {
+		s.topics = append(s.topics, s.bus.subscribeToTopic(topicKey, s))
+	}
+}
+
+// RemoveTopics unsubscribes the subscription from the listed topics.
+// Topics the subscription isn't subscribed to are ignored.
+// The subscription's channel stays open, even if the subscription is no longer subscribed to any topics.
+//
+// Events published while the topics are changing may not be delivered to the subscription,
+// but they are never delivered more than once.
+func (s *Subscription[Event]) RemoveTopics(topicKeys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isUnsubscribed() {
+		return
+	}
+
+	remainingTopics := make([]*topic[Event], 0, len(s.topics))
+	for _, t := range s.topics {
+		if containsString(topicKeys, t.key) {
+			t.bus.unsubscribeFromTopic(t, s)
+			continue
+		}
+
+		remainingTopics = append(remainingTopics, t)
+	}
+
+	s.topics = remainingTopics
+
+	var remainingTopicKeys []string
+	for _, topicKey := range s.subscribedTopicKeys() {
+		if !containsString(topicKeys, topicKey) {
+			remainingTopicKeys = append(remainingTopicKeys, topicKey)
+		}
+	}
+
+	s.topicKeys.Store(remainingTopicKeys)
+}
+
+// Topics lists the keys of the topics the subscription is subscribed to.
+func (s *Subscription[Event]) Topics() []string {
+	return append([]string{}, s.subscribedTopicKeys()...)
+}
+
+// ID returns the subscription's ID, which is unique within its bus.
+func (s *Subscription[Event]) ID() uint64 {
+	return s.id
+}
+
+// Name returns the name the subscription was configured with.
+func (s *Subscription[Event]) Name() string {
+	return s.name
+}
+
 // Channel exposes a read only view of the subscription's channel.
 // All events published to the subscribed topics will be published to this channel.
 //
@@ -117,16 +475,19 @@ func (b *EventBus[Event]) findOrCreateTopic(topicKey string) *topic[Event] {
 	if !ok {
 		// If another goroutine created a topic at the same time,
 		// we'd want to use it and not create a duplicate
-		t, _ = b.topics.LoadOrStore(topicKey, &topic[Event]{
+		var loaded bool
+		t, loaded = b.topics.LoadOrStore(topicKey, &topic[Event]{
 			key: topicKey,
 			bus: b,
-
-			subs: make(map[*Subscription[Event]]*Subscription[Event]),
 		})
+
+		if !loaded {
+			b.topicCreated(topicKey)
+		}
 	}
 
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	// Make sure the topic wasn't closed while we were loading it
 	if t.isClosed {
@@ -136,43 +497,299 @@ func (b *EventBus[Event]) findOrCreateTopic(topicKey string) *topic[Event] {
 	return t
 }
 
+// subscriptions returns the current subscriptions to the topic, sorted by subscription ID.
+// The returned slice must not be modified.
+func (t *topic[Event]) subscriptions() []*Subscription[Event] {
+	subs, _ := t.subs.Load().([]*Subscription[Event])
+	return subs
+}
+
 func (t *topic[Event]) addSubscription(s *Subscription[Event]) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	t.subs[s] = s
+	oldSubs := t.subscriptions()
+	i := sort.Search(len(oldSubs), func(i int) bool { return oldSubs[i].id >= s.id })
+
+	// The subscription may be listed multiple times when subscribing
+	if i < len(oldSubs) && oldSubs[i] == s {
+		return
+	}
+
+	newSubs := make([]*Subscription[Event], 0, len(oldSubs)+1)
+	newSubs = append(newSubs, oldSubs[:i]...)
+	newSubs = append(newSubs, s)
+	newSubs = append(newSubs, oldSubs[i:]...)
+	t.subs.Store(newSubs)
 }
 
-func (t *topic[Event]) removeSubscription(sub *Subscription[Event]) {
+// removeSubscription removes the subscription from the topic.
+// It reports whether the topic is closed, since it no longer has any subscriptions,
+// and whether it was deleted by removing this subscription, so the caller notifies the watchers once unlocked.
+func (t *topic[Event]) removeSubscription(s *Subscription[Event]) (isClosed, deleted bool) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	delete(t.subs, sub)
+	oldSubs := t.subscriptions()
+	i := sort.Search(len(oldSubs), func(i int) bool { return oldSubs[i].id >= s.id })
+
+	// The subscription may be listed multiple times when subscribing
+	if i == len(oldSubs) || oldSubs[i] != s {
+		return t.isClosed, false
+	}
 
-	if len(t.subs) == 0 {
+	if len(oldSubs) == 1 {
 		t.isClosed = true
-		t.bus.topics.Delete(t.key)
+
+		// The sharded registry deletes the topic itself, since it removes subscriptions while the shard is locked
+		if t.bus.shardedTopics == nil {
+			t.bus.topics.Delete(t.key)
+		}
+
+		deleted = true
 	}
+
+	newSubs := make([]*Subscription[Event], 0, len(oldSubs)-1)
+	newSubs = append(newSubs, oldSubs[:i]...)
+	newSubs = append(newSubs, oldSubs[i+1:]...)
+	t.subs.Store(newSubs)
+
+	return t.isClosed, deleted
 }
 
-func (b *EventBus[Event]) publishToTopic(topicKey string, event Event, publishedSubscriptions map[*Subscription[Event]]bool) {
-	t, ok := b.topics.Load(topicKey)
+// maxInlinePublishedTopics is the number of topics a publish can track without allocating.
+const maxInlinePublishedTopics = 8
+
+// publish sends the event to all subscriptions of the listed topics.
+// The timeout is passed to deliver for each subscription.
+// If a report is provided, it is filled with the details of the delivery.
+//
+// Publishing doesn't allocate unless a report is provided, a timeout needs to be waited on,
+// or more than maxInlinePublishedTopics topics have subscriptions.
+func (b *EventBus[Event]) publish(event Event, topicKeys []string, timeout time.Duration, report *PublishReport[Event]) {
+	// The snapshots of the subscriptions that were already published to are owned by this publish,
+	// so changes to the topics while publishing can't cause the event to be delivered more than once
+	var inline [maxInlinePublishedTopics][]*Subscription[Event]
+	published := inline[:0]
+
+	for _, topicKey := range topicKeys {
+		if subs := b.publishToTopic(topicKey, published, event, timeout, report); subs != nil {
+			published = append(published, subs)
+		}
+	}
+}
+
+// publishToTopic sends the event to the topic's subscriptions, except those in the published snapshots.
+// It returns the snapshot of the topic's subscriptions that was published to, or nil if the topic has no subscriptions.
+func (b *EventBus[Event]) publishToTopic(
+	topicKey string,
+	published [][]*Subscription[Event],
+	event Event,
+	timeout time.Duration,
+	report *PublishReport[Event],
+) []*Subscription[Event] {
+	t, ok := b.loadTopic(topicKey)
 	if !ok {
-		return
+		return nil
 	}
 
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	subs := t.subscriptions()
+	if len(subs) == 0 {
+		return nil // The topic was closed after we loaded it
+	}
+
+	if report != nil {
+		report.addMatchedTopic(t.key)
+	}
+
+	if b.shouldFanoutInParallel(len(subs)) {
+		b.fanoutInParallel(subs, t.key, published, event, timeout, report)
+		return subs
+	}
+
+	fanout(subs, t.key, published, event, timeout, report)
+	return subs
+}
+
+// fanout delivers the event to each of the subscriptions, except those that were already published to.
+func fanout[Event any](
+	subs []*Subscription[Event],
+	topicKey string,
+	published [][]*Subscription[Event],
+	event Event,
+	timeout time.Duration,
+	report *PublishReport[Event],
+) {
+	for _, sub := range subs {
+		// If we already published to this subscription, don't publish again to guarantee only once delivery.
+		// This is also true if the event was dropped, so we don't try again on the other topics.
+		if wasPublishedTo(published, sub) {
+			if report != nil {
+				report.Deduplicated++
+			}
 
-	for _, sub := range t.subs {
-		// If we already published to this subscription, don't publish again to guarantee only once delivery
-		if _, alreadyPublished := publishedSubscriptions[sub]; alreadyPublished {
 			continue
 		}
 
-		sub.ch <- event
-		publishedSubscriptions[sub] = true
+		result := sub.deliver(topicKey, event, timeout)
+
+		if report != nil {
+			report.addDelivery(sub, result)
+		}
+	}
+}
+
+// wasPublishedTo reports whether the subscription is in any of the published snapshots.
+//
+// The snapshots are the subscriptions of the topics that were already published to, sorted by subscription ID,
+// so each is binary searched. They are never modified, so subscriptions that change their topics while publishing
+// are still found in the snapshots they were published to.
+// (Stamping each subscription with a per-publish generation would also avoid allocating, but concurrent publishes
+// to the same subscription would overwrite each other's stamps, breaking only once delivery.)
+func wasPublishedTo[Event any](published [][]*Subscription[Event], sub *Subscription[Event]) bool {
+	for _, subs := range published {
+		low, high := 0, len(subs)
+		for low < high {
+			mid := int(uint(low+high) >> 1)
+			if subs[mid].id < sub.id {
+				low = mid + 1
+			} else {
+				high = mid
+			}
+		}
+
+		if low < len(subs) && subs[low] == sub {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscribedTopicKeys returns the keys of the topics the subscription is subscribed to.
+// The returned slice must not be modified.
+func (s *Subscription[Event]) subscribedTopicKeys() []string {
+	topicKeys, _ := s.topicKeys.Load().([]string)
+	return topicKeys
+}
+
+// isUnsubscribed reports whether Unsubscribe was called. The caller must hold mu.
+func (s *Subscription[Event]) isUnsubscribed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type deliveryResult int
+
+const (
+	delivered deliveryResult = iota
+	dropped
+	unsubscribed
+)
+
+// deliver sends the event to the subscription, waiting up to the timeout for room in the subscription's channel.
+// If the timeout is zero, it doesn't wait at all, and if it is waitIndefinitely, it waits until there is room.
+func (s *Subscription[Event]) deliver(topicKey string, event Event, timeout time.Duration) deliveryResult {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+
+	if s.isClosed {
+		return unsubscribed
+	}
+
+	if s.rateLimiters != nil {
+		key := topicKey
+		if s.rateLimitKey != nil {
+			key = s.rateLimitKey(event)
+		}
+
+		s.rateLimiters.push(key, event)
+		return delivered
+	}
+
+	if s.queue != nil {
+		s.queue.push(event)
+		return delivered
+	}
+
+	// Try without waiting first, to avoid creating a timer when there is room
+	select {
+	case s.ch <- event:
+		atomic.AddUint64(&s.sent, 1)
+		s.logUnblocked()
+		return delivered
+	default:
+	}
+
+	if s.disconnectOnOverflow {
+		// Unsubscribing waits for sends like this one to stop, so it happens in the background
+		if atomic.CompareAndSwapInt32(&s.isOverflowed, 0, 1) {
+			if s.bus.logger != nil {
+				s.bus.logger.Warn("eventbus: disconnecting subscription that overflowed", s.logAttrs("topic", s.bus.topicName(topicKey), "queue_depth", len(s.ch))...)
+			}
+
+			go s.unsubscribe(ErrSubscriptionOverflowed)
+		}
+
+		return dropped
+	}
+
+	if timeout == 0 {
+		s.logDropped(topicKey)
+		return dropped
 	}
+
+	s.logBlocked(topicKey)
+
+	var timeoutCh <-chan time.Time
+	if timeout != waitIndefinitely {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		timeoutCh = timer.C
+	}
+
+	atomic.AddInt32(&s.waiting, 1)
+	defer atomic.AddInt32(&s.waiting, -1)
+
+	select {
+	case s.ch <- event:
+		atomic.AddUint64(&s.sent, 1)
+		return delivered
+	case <-timeoutCh:
+		s.logDropped(topicKey)
+		return dropped
+	case <-s.done:
+		return unsubscribed
+	}
+}
+
+// isPublishingClosed reports whether Close was called, after which synchronously published events are ignored.
+// Events queued by PublishAsync are still delivered while closing.
+func (b *EventBus[Event]) isPublishingClosed() bool {
+	return atomic.LoadInt32(&b.publishingClosed) == 1
+}
+
+func (b *EventBus[Event]) publishTimeout() time.Duration {
+	if b.rawPublishTimeout <= 0 {
+		return waitIndefinitely
+	}
+
+	return b.rawPublishTimeout
 }
 
 func (b *EventBus[Event]) bufferSizeOrDefault() int {
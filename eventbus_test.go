@@ -1,8 +1,10 @@
 package eventbus_test
 
 import (
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/JosiahWitt/ensure"
 	"github.com/JosiahWitt/ensure/ensurepkg"
@@ -16,12 +18,6 @@ type MyEvent struct {
 func TestEventBus(t *testing.T) {
 	ensure := ensure.New(t)
 
-	ensure.Run("when nothing is subscribed", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
-
-		bus.Publish(&MyEvent{ID: "123"}, "key1", "key2")
-	})
-
 	ensure.Run("when one topic is subscribed and one event is published", func(ensure ensurepkg.Ensure) {
 		ensure.Run("when created by New", func(ensure ensurepkg.Ensure) {
 			bus := eventbus.New[*MyEvent]()
@@ -70,331 +66,556 @@ func TestEventBus(t *testing.T) {
 		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}})
 	})
 
-	ensure.Run("when one topic is subscribed and two events are published", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	ensure.Run("when a subscription is unsubscribed while publishing is waiting on it", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: -1, // Sets the buffer to zero, so publishing waits for the receiver
+		})
 
 		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
-
-		bus.Publish(&MyEvent{ID: "123"}, "key1", "key2")
-		bus.Publish(&MyEvent{ID: "456"}, "key1", "key2")
+		buf1 := bufferSubscription(sub1, 1)
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
-	})
+		sub2 := bus.Subscribe("key1") // Nothing receives from sub2
 
-	ensure.Run("when two topics are subscribed to different keys and two events are published", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+		published := make(chan *eventbus.PublishReport[string])
+		go func() {
+			published <- bus.PublishWithReport("1", "key1")
+		}()
 
-		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
+		// Subscriptions are delivered to in order, so publishing is now waiting on sub2
+		ensure(buf1.events()).Equals([]string{"1"})
 
-		sub2 := bus.Subscribe("key2")
-		buf2 := bufferSubscription(sub2, 2)
+		sub2.Unsubscribe()
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1", "key2")
-		bus.Publish(&MyEvent{ID: "456"}, "key1", "key2")
+		report := <-published
+		ensure(report.Delivered).Equals(1)
+		ensure(len(report.Dropped)).Equals(0)
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
-		ensure(buf2.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
+		_, sub2IsOpen := <-sub2.Channel()
+		ensure(sub2IsOpen).IsFalse()
 	})
 
-	ensure.Run("when two topics are subscribed to the same key and two events are published", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	ensure.Run("delivers to subscriptions in the order they subscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: -1, // Sets the buffer to zero, so nothing can be delivered without a receiver
+		})
 
-		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
+		subs := []*eventbus.Subscription[string]{}
+		for i := 0; i < 20; i++ {
+			subs = append(subs, bus.Subscribe("key1", "key2"))
+		}
 
-		sub2 := bus.Subscribe("key1")
-		buf2 := bufferSubscription(sub2, 2)
+		err := bus.TryPublish("1", "key1")
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1", "key2")
-		bus.Publish(&MyEvent{ID: "456"}, "key1", "key2")
+		var wouldBlockErr *eventbus.WouldBlockError[string]
+		ensure(errors.As(err, &wouldBlockErr)).IsTrue()
+		ensure(len(wouldBlockErr.Subscriptions)).Equals(len(subs))
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
-		ensure(buf2.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
+		for i, sub := range wouldBlockErr.Subscriptions {
+			ensure(sub == subs[i]).IsTrue()
+		}
 	})
 
-	ensure.Run("only receives event once when the same event is published to duplicate topics", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
-
-		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
+	ensure.Run("ignores events published once closed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1", "key2", "key1")
-		bus.Publish(&MyEvent{ID: "456"}, "key1", "key2", "key1")
+		bus.Close()
+		bus.Publish("1", "key1")
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
+		ensure(len(sub.Channel())).Equals(0)
 	})
+}
+
+func TestPublishAllocations(t *testing.T) {
+	ensure := ensure.New(t)
 
-	ensure.Run("when subscribed to multiple topics", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	const runs = 100
 
-		sub1 := bus.Subscribe("key1", "key2")
-		buf1 := bufferSubscription(sub1, 3)
+	newBus := func() *eventbus.EventBus[int] {
+		return eventbus.NewWithConfig[int](&eventbus.Config{
+			BufferSize: runs + 1, // AllocsPerRun does a warm up run
+		})
+	}
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1", "key2", "key3") // Shows that events are only delivered once
-		bus.Publish(&MyEvent{ID: "456"}, "key3")
-		bus.Publish(&MyEvent{ID: "789"}, "key1")
-		bus.Publish(&MyEvent{ID: "42"}, "key2")
+	ensure.Run("publishing to a single topic does not allocate", func(ensure ensurepkg.Ensure) {
+		bus := newBus()
+		bus.Subscribe("key1")
+		bus.Subscribe("key1")
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "789"}, {ID: "42"}})
+		allocs := testing.AllocsPerRun(runs, func() {
+			bus.Publish(1, "key1")
+		})
+		ensure(allocs).Equals(float64(0))
 	})
 
-	ensure.Run("when two topics are subscribed to the different key and two non-overlapping events are published", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	ensure.Run("publishing to multiple topics does not allocate", func(ensure ensurepkg.Ensure) {
+		bus := newBus()
+		bus.Subscribe("key1", "key2")
+		bus.Subscribe("key2")
+		bus.Subscribe("key3", "key1")
 
-		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 1)
+		allocs := testing.AllocsPerRun(runs, func() {
+			bus.Publish(1, "key1", "key2", "key3", "key4")
+		})
+		ensure(allocs).Equals(float64(0))
+	})
+}
 
-		sub2 := bus.Subscribe("key2")
-		buf2 := bufferSubscription(sub2, 1)
+func TestTryPublish(t *testing.T) {
+	ensure := ensure.New(t)
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1")
-		bus.Publish(&MyEvent{ID: "456"}, "key2")
+	ensure.Run("when nothing is subscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}})
-		ensure(buf2.events()).Equals([]*MyEvent{{ID: "456"}})
+		err := bus.TryPublish("1", "key1")
+		ensure(err).IsNotError()
 	})
 
-	ensure.Run("when two topics are subscribed to the different key and three variously-overlapping events are published", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	ensure.Run("when all subscriptions have room", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
 		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
+		sub2 := bus.Subscribe("key1", "key2")
 
-		sub2 := bus.Subscribe("key2")
-		buf2 := bufferSubscription(sub2, 2)
+		ensure(bus.TryPublish("1", "key1", "key2")).IsNotError()
+		ensure(bus.TryPublish("2", "key2")).IsNotError()
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1")
-		bus.Publish(&MyEvent{ID: "456"}, "key2")
-		bus.Publish(&MyEvent{ID: "789"}, "key2", "key1")
-
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "789"}})
-		ensure(buf2.events()).Equals([]*MyEvent{{ID: "456"}, {ID: "789"}})
+		ensure(bufferSubscription(sub1, 1).events()).Equals([]string{"1"})
+		ensure(bufferSubscription(sub2, 2).events()).Equals([]string{"1", "2"})
 	})
 
-	ensure.Run("when one subscription is unsubscribed part way through", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	ensure.Run("when some subscriptions are full", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: 1,
+		})
 
 		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
+		sub2 := bus.Subscribe("key1", "key2")
+		sub3 := bus.Subscribe("key2")
 
-		sub2 := bus.Subscribe("key1")
-		buf2 := bufferSubscription(sub2, 3)
+		ensure(bus.TryPublish("1", "key1")).IsNotError()
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1")
-		bus.Publish(&MyEvent{ID: "456"}, "key1")
-		sub1.Unsubscribe()
-		bus.Publish(&MyEvent{ID: "789"}, "key1")
+		err := bus.TryPublish("2", "key1", "key2")
+		ensure(err).IsError(eventbus.ErrWouldBlock)
+		ensure(err.Error()).Equals("eventbus: publishing would block: 2 subscription(s) are full")
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
-		ensure(buf2.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}, {ID: "789"}})
+		var wouldBlockErr *eventbus.WouldBlockError[string]
+		ensure(errors.As(err, &wouldBlockErr)).IsTrue()
+		ensure(len(wouldBlockErr.Subscriptions)).Equals(2)
+		ensure(wouldBlockErr.Subscriptions).Contains(sub1)
+		ensure(wouldBlockErr.Subscriptions).Contains(sub2)
 
-		_, sub1IsOpen := <-sub1.Channel()
-		ensure(sub1IsOpen).IsFalse()
+		ensure(bufferSubscription(sub1, 1).events()).Equals([]string{"1"})
+		ensure(bufferSubscription(sub2, 1).events()).Equals([]string{"1"})
+		ensure(bufferSubscription(sub3, 1).events()).Equals([]string{"2"})
 	})
 
-	ensure.Run("when all subscriptions are unsubscribed on one topic and then a new one is subscribed", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[*MyEvent]()
+	ensure.Run("never blocks on conflated subscriptions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
-		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 2)
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{
+			ConflationKey: func(event string) string { return event },
+		}, "key1")
 
-		sub2 := bus.Subscribe("key1")
-		buf2 := bufferSubscription(sub2, 3)
+		ensure(bus.TryPublish("1", "key1")).IsNotError()
+		ensure(bus.TryPublish("2", "key1")).IsNotError()
 
-		sub3 := bus.Subscribe("key2")
-		buf3 := bufferSubscription(sub3, 1)
+		ensure(bufferSubscription(sub, 2).events()).Equals([]string{"1", "2"})
+	})
 
-		bus.Publish(&MyEvent{ID: "123"}, "key1")
-		bus.Publish(&MyEvent{ID: "456"}, "key1", "key2")
-		sub1.Unsubscribe()
-		bus.Publish(&MyEvent{ID: "789"}, "key1")
-		sub2.Unsubscribe()
+	ensure.Run("when the bus is closed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
 
-		ensure(buf1.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}})
-		ensure(buf2.events()).Equals([]*MyEvent{{ID: "123"}, {ID: "456"}, {ID: "789"}})
-		ensure(buf3.events()).Equals([]*MyEvent{{ID: "456"}})
+		bus.Close()
+		ensure(bus.TryPublish("1", "key1")).IsError(eventbus.ErrBusClosed)
+		ensure(len(sub.Channel())).Equals(0)
+	})
+}
 
-		_, sub1IsOpen := <-sub1.Channel()
-		ensure(sub1IsOpen).IsFalse()
+func TestConfig(t *testing.T) {
+	ensure := ensure.New(t)
 
-		_, sub2IsOpen := <-sub2.Channel()
-		ensure(sub2IsOpen).IsFalse()
+	ensure.Run("default configuration via New", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
-		sub4 := bus.Subscribe("key1")
-		buf4 := bufferSubscription(sub4, 1)
+		sub := bus.Subscribe()
+		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
+	})
 
-		bus.Publish(&MyEvent{ID: "42"}, "key1")
+	ensure.Run("default configuration via initializing struct", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.EventBus[string]{}
 
-		ensure(buf4.events()).Equals([]*MyEvent{{ID: "42"}})
+		sub := bus.Subscribe()
+		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
 	})
 
-	ensure.Run("concurrent publishing", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.New[string]()
+	ensure.Run("default configuration via NewWithConfig", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{})
 
-		sub1 := bus.Subscribe("key1")
-		buf1 := bufferSubscription(sub1, 3)
+		sub := bus.Subscribe()
+		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
+	})
 
-		sub2 := bus.Subscribe("key1")
-		buf2 := bufferSubscription(sub2, 3)
+	ensure.Run("force a zero buffer size", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: -1, // Sets the buffer to zero
+		})
 
-		sub3 := bus.Subscribe("key2")
-		buf3 := bufferSubscription(sub3, 2)
+		sub := bus.Subscribe()
+		ensure(cap(sub.Channel())).Equals(0)
+	})
 
-		go func() {
-			bus.Publish("1", "key1")
-		}()
+	ensure.Run("override buffer size", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize: 100,
+		})
 
-		go func() {
-			bus.Publish("2", "key2")
-		}()
+		sub := bus.Subscribe()
+		ensure(cap(sub.Channel())).Equals(100)
+	})
+}
 
-		go func() {
-			bus.Publish("3", "key1")
-		}()
+func TestSubscribeWithConfig(t *testing.T) {
+	ensure := ensure.New(t)
 
-		go func() {
-			bus.Publish("4", "key2")
-		}()
+	type Quote struct {
+		Symbol string
+		Price  int
+	}
 
-		go func() {
-			bus.Publish("5", "key1")
-		}()
+	ensure.Run("default configuration", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
-		ensure(len(buf1.events())).Equals(3)
-		ensure(buf1.events()).Contains("1")
-		ensure(buf1.events()).Contains("3")
-		ensure(buf1.events()).Contains("5")
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{}, "key1")
+		buf := bufferSubscription(sub, 2)
 
-		ensure(len(buf2.events())).Equals(3)
-		ensure(buf2.events()).Contains("1")
-		ensure(buf2.events()).Contains("3")
-		ensure(buf2.events()).Contains("5")
+		bus.Publish("1", "key1", "key2")
+		bus.Publish("2", "key1")
 
-		ensure(len(buf3.events())).Equals(2)
-		ensure(buf3.events()).Contains("2")
-		ensure(buf3.events()).Contains("4")
+		ensure(buf.events()).Equals([]string{"1", "2"})
+		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
 	})
 
-	ensure.Run("concurrent subscriptions, publishing, and unsubscriptions", func(ensure ensurepkg.Ensure) {
-		// This test is largely designed to help surface any race condition issues, thus the results are not checked
+	ensure.Run("conflation", func(ensure ensurepkg.Ensure) {
+		ensure.Run("only delivers the latest pending event for each key in order of first arrival", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[*Quote]()
+
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[*Quote]{
+				ConflationKey: func(q *Quote) string { return q.Symbol },
+			}, "quotes", "other")
+
+			// Nothing is receiving yet, so all events are pending
+			bus.Publish(&Quote{Symbol: "FIRST", Price: 0}, "quotes")
+			bus.Publish(&Quote{Symbol: "AAA", Price: 1}, "quotes")
+			bus.Publish(&Quote{Symbol: "BBB", Price: 1}, "quotes", "other") // Only delivered once
+			bus.Publish(&Quote{Symbol: "AAA", Price: 2}, "quotes")
+			bus.Publish(&Quote{Symbol: "CCC", Price: 1}, "other")
+			bus.Publish(&Quote{Symbol: "BBB", Price: 2}, "quotes")
+			bus.Publish(&Quote{Symbol: "AAA", Price: 3}, "quotes")
+			bus.Publish(&Quote{Symbol: "DDD", Price: 1}, "unsubscribed")
+
+			buf := bufferSubscription(sub, 4)
+			ensure(buf.events()).Equals([]*Quote{
+				{Symbol: "FIRST", Price: 0},
+				{Symbol: "AAA", Price: 3},
+				{Symbol: "BBB", Price: 2},
+				{Symbol: "CCC", Price: 1},
+			})
+
+			bus.Publish(&Quote{Symbol: "AAA", Price: 4}, "quotes")
+
+			buf = bufferSubscription(sub, 1)
+			ensure(buf.events()).Equals([]*Quote{{Symbol: "AAA", Price: 4}})
+		})
 
-		bus := eventbus.New[*MyEvent]()
+		ensure.Run("creates the channel with no buffer", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[*Quote]()
 
-		// Thoroughly exercise the concurrent code
-		const numParallel = 1000
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[*Quote]{
+				ConflationKey: func(q *Quote) string { return q.Symbol },
+			})
 
-		// Make sure the subscription and unsubscription can happen in separate goroutines
-		for i := 0; i < numParallel; i++ {
-			var (
-				sub *eventbus.Subscription[*MyEvent]
-				mu  sync.Mutex
-			)
+			ensure(cap(sub.Channel())).Equals(0)
+		})
 
-			mu.Lock() // Force the subscription to happen before unsubscribe is called
-			go func() {
-				defer mu.Unlock()
-				sub = bus.Subscribe("key1")
-			}()
+		ensure.Run("closes the channel when unsubscribed", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[*Quote]()
 
-			go func() {
-				mu.Lock()
-				defer mu.Unlock()
-				sub.Unsubscribe()
-			}()
-		}
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[*Quote]{
+				ConflationKey: func(q *Quote) string { return q.Symbol },
+			}, "quotes")
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Publish(&MyEvent{ID: "1"}, "key1")
-			}()
-		}
+			bus.Publish(&Quote{Symbol: "AAA", Price: 1}, "quotes")
+			sub.Unsubscribe()
+			bus.Publish(&Quote{Symbol: "AAA", Price: 2}, "quotes")
 
-		for i := 0; i < numParallel; i++ {
+			// The pending event may already be being sent, but the event published after unsubscribing never is
+			var prices []int
+			drained := make(chan struct{})
 			go func() {
-				bus.Subscribe("key1").Unsubscribe()
-			}()
-		}
+				defer close(drained)
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Subscribe("key2").Unsubscribe()
+				for quote := range sub.Channel() {
+					prices = append(prices, quote.Price)
+				}
 			}()
-		}
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Publish(&MyEvent{ID: "2"}, "key2")
-			}()
-		}
+			select {
+			case <-drained:
+			case <-time.After(time.Second):
+				ensure.Failf("Channel was not closed")
+			}
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Publish(&MyEvent{ID: "3"}, "key1")
-			}()
-		}
+			ensure(len(prices) <= 1).IsTrue()
+			for _, price := range prices {
+				ensure(price).Equals(1)
+			}
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Subscribe("key3").Unsubscribe()
-			}()
-		}
+			_, isOpen := <-sub.Channel()
+			ensure(isOpen).IsFalse()
+		})
+	})
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Publish(&MyEvent{ID: "4"}, "key2")
-			}()
-		}
+	ensure.Run("debounce", func(ensure ensurepkg.Ensure) {
+		ensure.Run("delivers the latest event for each topic once it is quiet", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[string]()
 
-		for i := 0; i < numParallel; i++ {
-			go func() {
-				bus.Publish(&MyEvent{ID: "5"}, "key1")
-			}()
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{
+				Debounce: 20 * time.Millisecond,
+			}, "key1", "key2")
+			defer sub.Unsubscribe()
+
+			bus.Publish("1", "key1")
+			bus.Publish("2", "key1")
+			bus.Publish("3", "key2")
+			bus.Publish("4", "key1")
+
+			// Each topic is debounced separately, so the events can arrive in either order
+			events := receiveAll(sub, 2, 100*time.Millisecond)
+			ensure(len(events)).Equals(2)
+			ensure(events).Contains("3")
+			ensure(events).Contains("4")
+
+			bus.Publish("5", "key1")
+			ensure(receiveAll(sub, 1, 100*time.Millisecond)).Equals([]string{"5"})
+		})
+
+		ensure.Run("groups events by the rate limit key", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[*Quote]()
+
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[*Quote]{
+				Debounce:     20 * time.Millisecond,
+				RateLimitKey: func(q *Quote) string { return q.Symbol },
+			}, "key1", "key2")
+			defer sub.Unsubscribe()
+
+			bus.Publish(&Quote{Symbol: "AAA", Price: 1}, "key1")
+			bus.Publish(&Quote{Symbol: "AAA", Price: 2}, "key2")
+			bus.Publish(&Quote{Symbol: "AAA", Price: 3}, "key1")
+
+			ensure(receiveAll(sub, 1, 100*time.Millisecond)).Equals([]*Quote{{Symbol: "AAA", Price: 3}})
+		})
+	})
+
+	ensure.Run("throttle", func(ensure ensurepkg.Ensure) {
+		publishBurst := func(bus *eventbus.EventBus[string]) {
+			bus.Publish("1", "key1")
+			bus.Publish("2", "key1")
+			bus.Publish("3", "key1")
 		}
+
+		ensure.Run("delivers the first event of each interval by default", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[string]()
+
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{
+				Throttle: 50 * time.Millisecond,
+			}, "key1")
+			defer sub.Unsubscribe()
+
+			publishBurst(bus)
+			ensure(receiveAll(sub, 1, 100*time.Millisecond)).Equals([]string{"1"})
+
+			time.Sleep(60 * time.Millisecond)
+			bus.Publish("4", "key1")
+			ensure(receiveAll(sub, 1, 100*time.Millisecond)).Equals([]string{"4"})
+		})
+
+		ensure.Run("delivers the latest event of each interval when trailing", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[string]()
+
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{
+				Throttle:     20 * time.Millisecond,
+				ThrottleMode: eventbus.ThrottleTrailing,
+			}, "key1")
+			defer sub.Unsubscribe()
+
+			publishBurst(bus)
+			ensure(receiveAll(sub, 1, 100*time.Millisecond)).Equals([]string{"3"})
+		})
+
+		ensure.Run("delivers the first and latest events of each interval when leading and trailing", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[string]()
+
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{
+				Throttle:     20 * time.Millisecond,
+				ThrottleMode: eventbus.ThrottleLeadingAndTrailing,
+			}, "key1")
+			defer sub.Unsubscribe()
+
+			publishBurst(bus)
+			ensure(receiveAll(sub, 2, 100*time.Millisecond)).Equals([]string{"1", "3"})
+		})
+
+		ensure.Run("throttles each topic separately", func(ensure ensurepkg.Ensure) {
+			bus := eventbus.New[string]()
+
+			sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{
+				Throttle: time.Hour,
+			}, "key1", "key2")
+			defer sub.Unsubscribe()
+
+			bus.Publish("1", "key1")
+			bus.Publish("2", "key1")
+			bus.Publish("3", "key2")
+
+			ensure(receiveAll(sub, 2, 100*time.Millisecond)).Equals([]string{"1", "3"})
+		})
 	})
 }
 
-func TestConfig(t *testing.T) {
+func TestSubscriptionTopics(t *testing.T) {
 	ensure := ensure.New(t)
 
-	ensure.Run("default configuration via New", func(ensure ensurepkg.Ensure) {
+	ensure.Run("adding topics delivers their events", func(ensure ensurepkg.Ensure) {
 		bus := eventbus.New[string]()
 
-		sub := bus.Subscribe()
-		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
+		sub := bus.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		sub.AddTopics("key2", "key1", "key3")
+		ensure(sub.Topics()).Equals([]string{"key1", "key2", "key3"})
+		ensure(bus.NumTopics()).Equals(3)
+
+		bus.Publish("1", "key2")
+		bus.Publish("2", "key3", "key1") // Only delivered once
+		bus.Publish("3", "key4")
+
+		ensure(receiveAll(sub, 2, 20*time.Millisecond)).Equals([]string{"1", "2"})
 	})
 
-	ensure.Run("default configuration via initializing struct", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.EventBus[string]{}
+	ensure.Run("removing topics stops delivering their events", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
-		sub := bus.Subscribe()
-		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
+		sub := bus.Subscribe("key1", "key2", "key3")
+		defer sub.Unsubscribe()
+
+		sub.RemoveTopics("key2", "key4")
+		ensure(sub.Topics()).Equals([]string{"key1", "key3"})
+		ensure(bus.Topics()).Contains("key1")
+		ensure(bus.NumTopics()).Equals(2)
+
+		bus.Publish("1", "key2")
+		bus.Publish("2", "key2", "key3")
+		bus.Publish("3", "key1")
+
+		ensure(receiveAll(sub, 2, 20*time.Millisecond)).Equals([]string{"2", "3"})
 	})
 
-	ensure.Run("default configuration via NewWithConfig", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.NewWithConfig[string](&eventbus.Config{})
+	ensure.Run("removing a topic that was already published to while publishing doesn't deliver twice", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: 1})
 
-		sub := bus.Subscribe()
-		ensure(cap(sub.Channel())).Equals(eventbus.DefaultBufferSize)
+		sub := bus.Subscribe("key1", "key2")
+		defer sub.Unsubscribe()
+
+		blocking := bus.Subscribe("key1")
+		defer blocking.Unsubscribe()
+
+		bus.Publish("fill", "key1")
+		ensure(<-sub.Channel()).Equals("fill")
+
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			bus.Publish("e", "key1", "key2") // Blocks on the full subscription after delivering to sub through key1
+		}()
+
+		ensure(<-sub.Channel()).Equals("e")
+		sub.RemoveTopics("key1")
+
+		ensure(receiveAll(blocking, 2, 20*time.Millisecond)).Equals([]string{"fill", "e"})
+		<-published
+
+		ensure(receiveAll(sub, 0, 20*time.Millisecond)).IsEmpty()
 	})
 
-	ensure.Run("force a zero buffer size", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.NewWithConfig[string](&eventbus.Config{
-			BufferSize: -1, // Sets the buffer to zero
-		})
+	ensure.Run("removing all topics keeps the channel open", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
 
-		sub := bus.Subscribe()
-		ensure(cap(sub.Channel())).Equals(0)
+		sub := bus.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		sub.RemoveTopics("key1")
+		ensure(sub.Topics()).Equals([]string{})
+		ensure(bus.NumTopics()).Equals(0)
+
+		sub.AddTopics("key2")
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key2")
+
+		ensure(receiveAll(sub, 1, 20*time.Millisecond)).Equals([]string{"2"})
 	})
 
-	ensure.Run("override buffer size", func(ensure ensurepkg.Ensure) {
-		bus := eventbus.NewWithConfig[string](&eventbus.Config{
-			BufferSize: 100,
-		})
+	ensure.Run("changes the topics of a subscription without any topics", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{TopicShards: 4})
 
 		sub := bus.Subscribe()
-		ensure(cap(sub.Channel())).Equals(100)
+		defer sub.Unsubscribe()
+
+		sub.AddTopics("key1")
+		bus.Publish("1", "key1")
+
+		ensure(<-sub.Channel()).Equals("1")
+	})
+
+	ensure.Run("has no effect once unsubscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub := bus.Subscribe("key1")
+		sub.Unsubscribe()
+
+		sub.AddTopics("key2")
+		sub.RemoveTopics("key1")
+
+		ensure(bus.NumTopics()).Equals(0)
+		_, ok := <-sub.Channel()
+		ensure(ok).IsFalse()
+	})
+
+	ensure.Run("never delivers an event twice while topics are changing", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[int]()
+
+		sub := bus.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		const numEvents = 1000
+
+		go func() {
+			for i := 0; i < numEvents; i++ {
+				bus.Publish(i, "key1", "key2")
+			}
+		}()
+
+		for i := 0; i < numEvents/10; i++ {
+			sub.AddTopics("key2")
+			sub.RemoveTopics("key2")
+		}
+
+		seen := map[int]bool{}
+		for _, event := range receiveAll(sub, numEvents, 50*time.Millisecond) {
+			ensure(seen[event]).IsFalse()
+			seen[event] = true
+		}
 	})
 }
 
@@ -419,6 +640,29 @@ func bufferSubscription[E any](sub *eventbus.Subscription[E], expectedCount int)
 	return buf
 }
 
+// receiveAll receives the expected number of events, and then waits to make sure no more events arrive within the duration.
+func receiveAll[E any](sub *eventbus.Subscription[E], expectedCount int, wait time.Duration) []E {
+	return receiveFrom(sub.Channel(), expectedCount, wait)
+}
+
+// receiveFrom receives the expected number of events from the channel, like receiveAll.
+func receiveFrom[E any](ch <-chan E, expectedCount int, wait time.Duration) []E {
+	events := make([]E, 0, expectedCount)
+
+	for {
+		select {
+		case event := <-ch:
+			events = append(events, event)
+		case <-time.After(wait):
+			return events
+		}
+
+		if len(events) > expectedCount {
+			return events
+		}
+	}
+}
+
 func (buf *buffer[E]) events() []E {
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
@@ -0,0 +1,160 @@
+package eventbustest
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/JosiahWitt/eventbus"
+)
+
+// Publication is an event published to a FakeBus.
+type Publication[Event any] struct {
+	Event     Event
+	TopicKeys []string
+}
+
+// FakeBus is a Broker that records the published events, for unit testing code that publishes events.
+// Published events are also delivered to its subscriptions, like an EventBus.
+//
+// It can be used by initializing a copy of the struct, or by calling NewFakeBus.
+type FakeBus[Event any] struct {
+	bus eventbus.EventBus[Event]
+
+	mu           sync.Mutex
+	publications []Publication[Event]
+}
+
+var _ eventbus.Broker[any] = &FakeBus[any]{}
+
+// NewFakeBus creates a new FakeBus.
+func NewFakeBus[Event any]() *FakeBus[Event] {
+	return &FakeBus[Event]{}
+}
+
+// Publish records the event, and sends it to all of the listed topics.
+func (f *FakeBus[Event]) Publish(event Event, topicKeys ...string) {
+	f.mu.Lock()
+	f.publications = append(f.publications, Publication[Event]{
+		Event:     event,
+		TopicKeys: append([]string{}, topicKeys...),
+	})
+	f.mu.Unlock()
+
+	f.bus.Publish(event, topicKeys...)
+}
+
+// Subscribe creates a new subscription to the listed topics.
+func (f *FakeBus[Event]) Subscribe(topicKeys ...string) eventbus.Receiver[Event] {
+	return f.bus.Subscribe(topicKeys...)
+}
+
+// Topics lists the keys of the topics that currently have subscriptions, in no particular order.
+func (f *FakeBus[Event]) Topics() []string {
+	return f.bus.Topics()
+}
+
+// Publications returns the recorded publications, in the order they were published.
+func (f *FakeBus[Event]) Publications() []Publication[Event] {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Publication[Event]{}, f.publications...)
+}
+
+// Reset forgets the recorded publications.
+func (f *FakeBus[Event]) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.publications = nil
+}
+
+// AssertPublished fails the test unless the event was published to exactly the listed topics, in any order.
+// Events are compared with reflect.DeepEqual.
+func (f *FakeBus[Event]) AssertPublished(t testing.TB, event Event, topicKeys ...string) {
+	t.Helper()
+
+	expected := sortedTopicKeys(uniqueTopicKeys(topicKeys))
+
+	var published [][]string
+	for _, publication := range f.Publications() {
+		if !reflect.DeepEqual(publication.Event, event) {
+			continue
+		}
+
+		actual := sortedTopicKeys(uniqueTopicKeys(publication.TopicKeys))
+		if reflect.DeepEqual(actual, expected) {
+			return
+		}
+
+		published = append(published, actual)
+	}
+
+	if len(published) == 0 {
+		t.Errorf("eventbustest: event %+v was not published", event)
+		return
+	}
+
+	t.Errorf("eventbustest: event %+v was published to topics %q, expected exactly %q", event, published, expected)
+}
+
+// AssertNotPublished fails the test if the event was published to any topic.
+// Events are compared with reflect.DeepEqual.
+func (f *FakeBus[Event]) AssertNotPublished(t testing.TB, event Event) {
+	t.Helper()
+
+	for _, publication := range f.Publications() {
+		if reflect.DeepEqual(publication.Event, event) {
+			t.Errorf("eventbustest: event %+v was published to topics %q", event, publication.TopicKeys)
+			return
+		}
+	}
+}
+
+// AssertPublishedTo fails the test unless the events were published to exactly the listed topics, in any order.
+// Each topic counts once, no matter how many events were published to it.
+func (f *FakeBus[Event]) AssertPublishedTo(t testing.TB, topicKeys ...string) {
+	t.Helper()
+
+	seen := map[string]bool{}
+	var actual []string
+
+	for _, publication := range f.Publications() {
+		for _, topicKey := range publication.TopicKeys {
+			if !seen[topicKey] {
+				seen[topicKey] = true
+				actual = append(actual, topicKey)
+			}
+		}
+	}
+
+	actual = sortedTopicKeys(actual)
+	expected := sortedTopicKeys(uniqueTopicKeys(topicKeys))
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("eventbustest: events were published to topics %q, expected exactly %q", actual, expected)
+	}
+}
+
+func sortedTopicKeys(topicKeys []string) []string {
+	sorted := append([]string{}, topicKeys...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func uniqueTopicKeys(topicKeys []string) []string {
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(topicKeys))
+
+	for _, topicKey := range topicKeys {
+		if !seen[topicKey] {
+			seen[topicKey] = true
+			unique = append(unique, topicKey)
+		}
+	}
+
+	return unique
+}
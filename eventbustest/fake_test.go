@@ -0,0 +1,75 @@
+package eventbustest_test
+
+import (
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/eventbustest"
+)
+
+type Message struct {
+	ID string
+}
+
+// notifier is an example of code under test, which depends on a Broker.
+type notifier struct {
+	broker eventbus.Broker[*Message]
+}
+
+func (n *notifier) notify(id string, rooms ...string) {
+	n.broker.Publish(&Message{ID: id}, rooms...)
+}
+
+func TestFakeBus(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("records publications", func(ensure ensurepkg.Ensure) {
+		bus := eventbustest.NewFakeBus[*Message]()
+		n := &notifier{broker: bus}
+
+		n.notify("1", "room1", "room2")
+		n.notify("2", "room2")
+
+		ensure(bus.Publications()).Equals([]eventbustest.Publication[*Message]{
+			{Event: &Message{ID: "1"}, TopicKeys: []string{"room1", "room2"}},
+			{Event: &Message{ID: "2"}, TopicKeys: []string{"room2"}},
+		})
+
+		bus.AssertPublished(ensure.T(), &Message{ID: "1"}, "room2", "room1")
+		bus.AssertPublished(ensure.T(), &Message{ID: "2"}, "room2")
+		bus.AssertNotPublished(ensure.T(), &Message{ID: "3"})
+		bus.AssertPublishedTo(ensure.T(), "room1", "room2")
+
+		bus.Reset()
+		ensure(bus.Publications()).Equals([]eventbustest.Publication[*Message]{})
+	})
+
+	ensure.Run("delivers to subscriptions", func(ensure ensurepkg.Ensure) {
+		bus := &eventbustest.FakeBus[*Message]{}
+		rec := eventbustest.Record[*Message](ensure.T(), bus.Subscribe("room1"))
+		ensure(bus.Topics()).Equals([]string{"room1"})
+
+		bus.Publish(&Message{ID: "1"}, "room1")
+		ensure(rec.WaitFor(1, eventbustest.DefaultTimeout)).Equals([]*Message{{ID: "1"}})
+	})
+
+	ensure.Run("fails assertions that don't match", func(ensure ensurepkg.Ensure) {
+		bus := eventbustest.NewFakeBus[*Message]()
+		bus.Publish(&Message{ID: "1"}, "room1", "room2")
+
+		t := &fakeT{TB: ensure.T()}
+		bus.AssertPublished(t, &Message{ID: "1"}, "room1")
+		bus.AssertPublished(t, &Message{ID: "2"}, "room1")
+		bus.AssertNotPublished(t, &Message{ID: "1"})
+		bus.AssertPublishedTo(t, "room1")
+
+		ensure(t.failures).Equals([]string{
+			`eventbustest: event &{ID:1} was published to topics [["room1" "room2"]], expected exactly ["room1"]`,
+			`eventbustest: event &{ID:2} was not published`,
+			`eventbustest: event &{ID:1} was published to topics ["room1" "room2"]`,
+			`eventbustest: events were published to topics ["room1" "room2"], expected exactly ["room1"]`,
+		})
+	})
+}
@@ -0,0 +1,129 @@
+// Package eventbustest provides helpers for testing code that uses an EventBus.
+//
+// A Recorder captures the events of a subscription in the background, so tests can wait for them,
+// and a FakeBus records the events that are published, so publishers can be tested on their own.
+package eventbustest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+)
+
+// DefaultTimeout is a reasonable timeout to pass to WaitFor.
+const DefaultTimeout = 5 * time.Second
+
+// Recorder receives the events of a subscription in the background, and records them.
+type Recorder[Event any] struct {
+	t    testing.TB
+	sub  eventbus.Receiver[Event]
+	once sync.Once
+
+	mu       sync.Mutex
+	events   []Event
+	isClosed bool
+	changed  chan struct{} // Closed and replaced whenever an event is recorded
+	stopped  chan struct{} // Closed once the subscription's channel is closed
+}
+
+// NewRecorder subscribes to the listed topics using the subscriber, such as the Broker returned by EventBus.Broker, and records their events.
+// The subscription is unsubscribed when the test finishes.
+func NewRecorder[Event any](t testing.TB, subscriber eventbus.Subscriber[Event], topicKeys ...string) *Recorder[Event] {
+	t.Helper()
+	return Record[Event](t, subscriber.Subscribe(topicKeys...))
+}
+
+// Record records the events of the subscription, which can be created by any Broker.
+// The subscription is unsubscribed when the test finishes.
+func Record[Event any](t testing.TB, sub eventbus.Receiver[Event]) *Recorder[Event] {
+	t.Helper()
+
+	r := &Recorder[Event]{
+		t:       t,
+		sub:     sub,
+		changed: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	t.Cleanup(r.Stop)
+
+	go r.run()
+
+	return r
+}
+
+// Events returns a copy of the events recorded so far.
+func (r *Recorder[Event]) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Event{}, r.events...)
+}
+
+// Len returns the number of events recorded so far.
+func (r *Recorder[Event]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.events)
+}
+
+// WaitFor waits until at least count events are recorded, and returns the recorded events.
+// The test fails if they aren't recorded before the timeout, or if the subscription is closed first.
+func (r *Recorder[Event]) WaitFor(count int, timeout time.Duration) []Event {
+	r.t.Helper()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		r.mu.Lock()
+		events := append([]Event{}, r.events...)
+		isClosed := r.isClosed
+		changed := r.changed
+		r.mu.Unlock()
+
+		if len(events) >= count {
+			return events
+		}
+
+		if isClosed {
+			r.t.Fatalf("eventbustest: subscription closed after %d of %d events", len(events), count)
+			return events
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			r.t.Fatalf("eventbustest: timed out after %v waiting for %d events, got %d", timeout, count, len(events))
+			return events
+		}
+	}
+}
+
+// Stop unsubscribes the subscription, and waits for the recording to stop.
+// Calling Stop more than once has no effect.
+func (r *Recorder[Event]) Stop() {
+	r.once.Do(r.sub.Unsubscribe)
+	<-r.stopped
+}
+
+func (r *Recorder[Event]) run() {
+	defer close(r.stopped)
+
+	for event := range r.sub.Channel() {
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		close(r.changed)
+		r.changed = make(chan struct{})
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.isClosed = true
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+}
@@ -0,0 +1,107 @@
+package eventbustest_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/eventbustest"
+)
+
+func TestRecorder(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("records the events of the topics", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		rec := eventbustest.NewRecorder[string](ensure.T(), bus.Broker(), "key1", "key2")
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key3")
+		bus.Publish("3", "key1", "key2")
+
+		ensure(rec.WaitFor(2, eventbustest.DefaultTimeout)).Equals([]string{"1", "3"})
+		ensure(rec.Events()).Equals([]string{"1", "3"})
+		ensure(rec.Len()).Equals(2)
+	})
+
+	ensure.Run("records subscriptions created by any broker", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyed[int, string]()
+		rec := eventbustest.Record[string](ensure.T(), bus.Subscribe(1))
+
+		go bus.Publish("1", 1)
+
+		ensure(rec.WaitFor(1, eventbustest.DefaultTimeout)).Equals([]string{"1"})
+	})
+
+	ensure.Run("stops recording once stopped", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		rec := eventbustest.NewRecorder[string](ensure.T(), bus.Broker(), "key1")
+
+		rec.Stop()
+		rec.Stop() // Has no effect
+
+		bus.Publish("1", "key1")
+		ensure(rec.Events()).Equals([]string{})
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("fails the test when the events don't arrive in time", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		t := &fakeT{TB: ensure.T()}
+		rec := eventbustest.NewRecorder[string](t, bus.Broker(), "key1")
+
+		bus.Publish("1", "key1")
+		t.run(func() { rec.WaitFor(2, 10*time.Millisecond) })
+
+		ensure(t.failures).Equals([]string{"eventbustest: timed out after 10ms waiting for 2 events, got 1"})
+	})
+
+	ensure.Run("fails the test when the subscription is closed while waiting", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		t := &fakeT{TB: ensure.T()}
+		sub := bus.Subscribe("key1")
+		rec := eventbustest.Record[string](t, sub)
+
+		sub.Unsubscribe()
+		t.run(func() { rec.WaitFor(1, eventbustest.DefaultTimeout) })
+
+		ensure(t.failures).Equals([]string{"eventbustest: subscription closed after 0 of 1 events"})
+	})
+}
+
+// fakeT records the failures of a test, without failing the real test.
+type fakeT struct {
+	testing.TB
+
+	mu       sync.Mutex
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls f in a goroutine, so Fatalf can stop it like it stops a test.
+func (t *fakeT) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
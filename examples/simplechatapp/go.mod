@@ -1,5 +1,7 @@
 module github.com/JosiahWitt/eventbus/examples/simplechatapp
 
-go 1.18
+go 1.21
 
 require github.com/JosiahWitt/eventbus v0.1.0
+
+replace github.com/JosiahWitt/eventbus => ../..
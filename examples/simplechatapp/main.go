@@ -2,12 +2,14 @@ package main
 
 import (
 	"embed"
-	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/ingest"
+	"github.com/JosiahWitt/eventbus/sse"
 )
 
 type Message struct {
@@ -21,59 +23,32 @@ type Message struct {
 var staticFiles embed.FS
 
 func main() {
-	bus := eventbus.New[*Message]()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	bus := eventbus.NewWithConfig[*Message](&eventbus.Config{Logger: logger})
 
 	mux := http.NewServeMux()
 
 	mux.Handle("/", http.FileServer(http.FS(staticFiles)))
 
-	mux.HandleFunc("/api/send-message", func(w http.ResponseWriter, r *http.Request) {
-		msg := &Message{}
-		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-			http.Error(w, err.Error(), 400)
-			log.Printf("Invalid message payload: %v\n", err)
-			return
-		}
-
-		log.Printf("Client sending message with ID: %v\n", msg.ID)
-		bus.Publish(msg, msg.Hashtags...)
-
-		w.WriteHeader(200)
-	})
-
-	mux.HandleFunc("/api/message-stream", func(w http.ResponseWriter, r *http.Request) {
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			http.Error(w, "Streaming unsupported", 500)
-			log.Println("Streaming unsupported")
-			return
-		}
-
-		hashtags := strings.Split(r.URL.Query().Get("hashtags"), ",")
-		log.Printf("Client listening for hashtags: %+v\n", hashtags)
-
-		w.Header().Add("Connection", "Keep-Alive")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Add("Content-Type", "text/event-stream")
-		w.WriteHeader(200)
-
-		sub := bus.Subscribe(hashtags...)
-
-		go func() {
-			<-r.Context().Done()
-			log.Println("Client closed the connection")
-			sub.Unsubscribe()
-		}()
-
-		for msg := range sub.Channel() {
-			msgJSON, _ := json.Marshal(msg)
-			w.Write([]byte("data: " + string(msgJSON) + "\n\n"))
-			flusher.Flush()
-		}
-	})
-
-	log.Println("Listening on http://localhost:1234")
+	mux.Handle("/api/send-message", ingest.NewHandler[*Message](bus, &ingest.Config[*Message]{
+		Topics: func(r *http.Request, msg *Message) []string { return msg.Hashtags },
+	}))
+
+	mux.Handle("/api/message-stream", sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+		Topics: func(r *http.Request) []string {
+			return strings.Split(r.URL.Query().Get("hashtags"), ",")
+		},
+		EventID: func(msg *Message) string { return msg.ID },
+		OnError: func(r *http.Request, err error) {
+			logger.Error("Unable to stream messages", "error", err)
+		},
+		SubscriptionConfig: &eventbus.SubscriptionConfig[*Message]{Name: "message-stream"},
+	}))
+
+	logger.Info("Listening on http://localhost:1234")
 	if err := http.ListenAndServe(":1234", mux); err != nil {
-		log.Fatalln("Unable to start server:", err)
+		logger.Error("Unable to start server", "error", err)
+		os.Exit(1)
 	}
 }
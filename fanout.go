@@ -0,0 +1,163 @@
+package eventbus
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// fanoutPool is a bounded pool of goroutines, which deliver partitions of a topic's subscriptions in parallel.
+type fanoutPool[Event any] struct {
+	once sync.Once
+	jobs chan *fanoutJob[Event]
+	wg   sync.WaitGroup
+
+	mu       sync.RWMutex
+	isClosed bool
+}
+
+type fanoutJob[Event any] struct {
+	subs      []*Subscription[Event]
+	topicKey  string
+	published [][]*Subscription[Event]
+	event     Event
+	timeout   time.Duration
+	report    *PublishReport[Event]
+
+	done *sync.WaitGroup
+}
+
+func (j *fanoutJob[Event]) run() {
+	defer j.done.Done()
+	fanout(j.subs, j.topicKey, j.published, j.event, j.timeout, j.report)
+}
+
+func (b *EventBus[Event]) shouldFanoutInParallel(numSubs int) bool {
+	return b.rawParallelFanoutThreshold > 0 && numSubs > b.rawParallelFanoutThreshold
+}
+
+// fanoutInParallel splits the subscriptions into a partition per worker, and delivers the partitions concurrently.
+// The publishing goroutine delivers the first partition, and any partitions that can't be handed to an idle worker.
+// It returns once all partitions have been delivered.
+func (b *EventBus[Event]) fanoutInParallel(
+	subs []*Subscription[Event],
+	topicKey string,
+	published [][]*Subscription[Event],
+	event Event,
+	timeout time.Duration,
+	report *PublishReport[Event],
+) {
+	workers := b.fanoutWorkersOrDefault()
+	partitionSize := (len(subs) + workers - 1) / workers
+
+	// Copy the published snapshots, since the jobs are shared with the workers.
+	// Otherwise, the snapshots tracked by publish would escape to the heap, making sequential fanout allocate.
+	sharedPublished := append([][]*Subscription[Event](nil), published...)
+
+	var done sync.WaitGroup
+	jobs := make([]*fanoutJob[Event], 0, workers)
+
+	for start := 0; start < len(subs); start += partitionSize {
+		end := start + partitionSize
+		if end > len(subs) {
+			end = len(subs)
+		}
+
+		job := &fanoutJob[Event]{
+			subs:      subs[start:end],
+			topicKey:  topicKey,
+			published: sharedPublished,
+			event:     event,
+			timeout:   timeout,
+			done:      &done,
+		}
+
+		// Each partition fills its own report, since they are filled concurrently
+		if report != nil {
+			job.report = &PublishReport[Event]{}
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	done.Add(len(jobs))
+
+	for _, job := range jobs[1:] {
+		if !b.submitFanoutJob(job) {
+			job.run()
+		}
+	}
+
+	jobs[0].run()
+	done.Wait()
+
+	// Merge the reports in order, so they are the same as if the subscriptions were delivered to sequentially
+	if report != nil {
+		for _, job := range jobs {
+			report.merge(job.report)
+		}
+	}
+}
+
+// submitFanoutJob hands the job to an idle worker, returning false if none are idle, or the pool is closed.
+func (b *EventBus[Event]) submitFanoutJob(job *fanoutJob[Event]) bool {
+	b.fanout.mu.RLock()
+	defer b.fanout.mu.RUnlock()
+
+	if b.fanout.isClosed {
+		return false
+	}
+
+	b.fanout.once.Do(b.startFanoutWorkers)
+
+	select {
+	case b.fanout.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *EventBus[Event]) startFanoutWorkers() {
+	workers := b.fanoutWorkersOrDefault()
+
+	b.fanout.jobs = make(chan *fanoutJob[Event])
+	b.fanout.wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer b.fanout.wg.Done()
+
+			for job := range b.fanout.jobs {
+				job.run()
+			}
+		}()
+	}
+}
+
+func (b *EventBus[Event]) closeFanout() {
+	b.fanout.mu.Lock()
+
+	if b.fanout.isClosed {
+		b.fanout.mu.Unlock()
+		return
+	}
+
+	b.fanout.isClosed = true
+
+	if b.fanout.jobs != nil {
+		close(b.fanout.jobs)
+	}
+
+	b.fanout.mu.Unlock()
+
+	b.fanout.wg.Wait()
+}
+
+func (b *EventBus[Event]) fanoutWorkersOrDefault() int {
+	if b.rawFanoutWorkers < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return b.rawFanoutWorkers
+}
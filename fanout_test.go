@@ -0,0 +1,112 @@
+package eventbus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestParallelFanout(t *testing.T) {
+	ensure := ensure.New(t)
+
+	const (
+		numSubs   = 500
+		numEvents = 20
+	)
+
+	newBus := func() *eventbus.EventBus[int] {
+		return eventbus.NewWithConfig[int](&eventbus.Config{
+			BufferSize:              numEvents,
+			ParallelFanoutThreshold: 10,
+			FanoutWorkers:           4,
+		})
+	}
+
+	publishAndVerify := func(ensure ensurepkg.Ensure, bus *eventbus.EventBus[int]) {
+		bufs := []*buffer[int]{}
+		for i := 0; i < numSubs; i++ {
+			bufs = append(bufs, bufferSubscription(bus.Subscribe("key1", "key2"), numEvents))
+		}
+
+		expectedEvents := []int{}
+		for i := 0; i < numEvents; i++ {
+			bus.Publish(i, "key1", "key2")
+			expectedEvents = append(expectedEvents, i)
+		}
+
+		// Each subscription receives the events once, in the order they were published
+		for _, buf := range bufs {
+			ensure(buf.events()).Equals(expectedEvents)
+		}
+	}
+
+	ensure.Run("delivers each event once and in order", func(ensure ensurepkg.Ensure) {
+		bus := newBus()
+		defer bus.Close()
+
+		publishAndVerify(ensure, bus)
+	})
+
+	ensure.Run("delivers the events queued by PublishAsync while closing", func(ensure ensurepkg.Ensure) {
+		bus := newBus()
+
+		bufs := []*buffer[int]{}
+		for i := 0; i < numSubs; i++ {
+			bufs = append(bufs, bufferSubscription(bus.Subscribe("key1", "key2"), numEvents))
+		}
+
+		expectedEvents := []int{}
+		for i := 0; i < numEvents; i++ {
+			bus.PublishAsync(i, "key1", "key2")
+			expectedEvents = append(expectedEvents, i)
+		}
+
+		bus.Close()
+
+		for _, buf := range bufs {
+			ensure(buf.events()).Equals(expectedEvents)
+		}
+	})
+
+	ensure.Run("reports the delivery in subscription order", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[int](&eventbus.Config{
+			BufferSize:              -1, // Sets the buffer to zero, so nothing can be delivered without a receiver
+			ParallelFanoutThreshold: 10,
+			FanoutWorkers:           4,
+		})
+		defer bus.Close()
+
+		subs := []*eventbus.Subscription[int]{}
+		for i := 0; i < 50; i++ {
+			subs = append(subs, bus.Subscribe("key1", "key2"))
+		}
+
+		err := bus.TryPublish(1, "key1", "key2")
+
+		var wouldBlockErr *eventbus.WouldBlockError[int]
+		ensure(errors.As(err, &wouldBlockErr)).IsTrue()
+		ensure(len(wouldBlockErr.Subscriptions)).Equals(len(subs))
+
+		for i, sub := range wouldBlockErr.Subscriptions {
+			ensure(sub == subs[i]).IsTrue()
+		}
+
+		bufs := []*buffer[int]{}
+		for _, sub := range subs {
+			bufs = append(bufs, bufferSubscription(sub, 1))
+		}
+
+		report := bus.PublishWithReport(2, "key1", "key2", "key3")
+		ensure(report.MatchedTopics).Equals([]string{"key1", "key2"})
+		ensure(report.Delivered).Equals(len(subs))
+		ensure(report.Deduplicated).Equals(len(subs))
+		ensure(len(report.Dropped)).Equals(0)
+
+		for _, buf := range bufs {
+			ensure(buf.events()).Equals([]int{2})
+		}
+	})
+}
@@ -0,0 +1,186 @@
+// Package ingest provides an http.Handler, which publishes the events sent in request bodies to an EventBus.
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+// DefaultMaxBodySize of requests, in bytes. Used when the MaxBodySize is not configured.
+const DefaultMaxBodySize = 1 << 20
+
+// Config can be passed to NewHandler to customize the Handler.
+type Config[Event any] struct {
+	// Topics returns the keys of the topics to publish the event to.
+	// If it returns no topic keys, the request fails with a 400 Bad Request.
+	// It is required.
+	Topics func(r *http.Request, event Event) []string
+
+	// Codec decodes the request body into an event. Only its Decode method is used.
+	// If decoding fails, the request fails with a 400 Bad Request.
+	// If not set, request bodies are decoded as JSON.
+	Codec codec.Codec[Event]
+
+	// Validate checks the event before it is published.
+	// If it returns an error, the request fails with a 422 Unprocessable Entity, and the error's message is sent to the client.
+	// If not set, all events are published.
+	Validate func(r *http.Request, event Event) error
+
+	// MaxBodySize is the maximum size of request bodies, in bytes.
+	// Larger requests fail with a 413 Request Entity Too Large.
+	// If not set or zero, it defaults to DefaultMaxBodySize.
+	// If negative, the size is not limited.
+	MaxBodySize int64
+
+	// OnPublish is called after each event is published, with the report describing its delivery.
+	// The report is nil if the publisher isn't an eventbus.ReportingPublisher, such as EventBus.
+	OnPublish func(r *http.Request, event Event, report *eventbus.PublishReport[Event])
+}
+
+// Summary describes the delivery of a published event.
+// It is sent to the client as JSON.
+// Only Topics is set if the publisher isn't an eventbus.ReportingPublisher.
+type Summary struct {
+	// Topics lists the topics the event was published to.
+	Topics []string `json:"topics"`
+
+	// MatchedTopics lists the published topics that had at least one subscription.
+	MatchedTopics []string `json:"matchedTopics"`
+
+	// Delivered is the number of subscriptions the event was delivered to.
+	Delivered int `json:"delivered"`
+
+	// Deduplicated is the number of times the event was not sent to a subscription,
+	// because it was already sent to that subscription through another topic.
+	Deduplicated int `json:"deduplicated"`
+
+	// Dropped is the number of subscriptions the event was not delivered to,
+	// because their channel was full for longer than the bus's PublishTimeout.
+	Dropped int `json:"dropped"`
+}
+
+// Handler publishes the event sent in the body of each POST request, and responds with a Summary of its delivery.
+type Handler[Event any] struct {
+	publisher eventbus.Publisher[Event]
+	config    *Config[Event]
+}
+
+var _ http.Handler = &Handler[any]{}
+
+// NewHandler creates a new Handler publishing events to the publisher, such as an EventBus.
+func NewHandler[Event any](publisher eventbus.Publisher[Event], config *Config[Event]) *Handler[Event] {
+	return &Handler[Event]{
+		publisher: publisher,
+		config:    config,
+	}
+}
+
+// ServeHTTP publishes the event sent in the request body.
+func (h *Handler[Event]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	event, status, message := h.readEvent(r)
+	if status != 0 {
+		http.Error(w, message, status)
+		return
+	}
+
+	if h.config.Validate != nil {
+		if err := h.config.Validate(r, event); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	topicKeys := h.config.Topics(r, event)
+	if len(topicKeys) == 0 {
+		http.Error(w, "No topics to publish to", http.StatusBadRequest)
+		return
+	}
+
+	report := h.publish(event, topicKeys)
+	if h.config.OnPublish != nil {
+		h.config.OnPublish(r, event, report)
+	}
+
+	summary := &Summary{Topics: topicKeys, MatchedTopics: []string{}}
+	if report != nil {
+		summary.MatchedTopics = nonNil(report.MatchedTopics)
+		summary.Delivered = report.Delivered
+		summary.Deduplicated = report.Deduplicated
+		summary.Dropped = len(report.Dropped)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary) //nolint:errcheck // The client disconnected
+}
+
+// publish publishes the event, returning its report if the publisher reports how events are delivered.
+func (h *Handler[Event]) publish(event Event, topicKeys []string) *eventbus.PublishReport[Event] {
+	if reporter, ok := h.publisher.(eventbus.ReportingPublisher[Event]); ok {
+		return reporter.PublishWithReport(event, topicKeys...)
+	}
+
+	h.publisher.Publish(event, topicKeys...)
+	return nil
+}
+
+// readEvent reads and decodes the request body, returning the status and message to respond with if it fails.
+func (h *Handler[Event]) readEvent(r *http.Request) (event Event, status int, message string) {
+	body := io.Reader(r.Body)
+
+	maxBodySize := h.maxBodySizeOrDefault()
+	if maxBodySize > 0 {
+		body = io.LimitReader(body, maxBodySize+1) // Reading one extra byte detects bodies that are too large
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return event, http.StatusBadRequest, fmt.Sprintf("Unable to read request body: %v", err)
+	}
+
+	if maxBodySize > 0 && int64(len(data)) > maxBodySize {
+		return event, http.StatusRequestEntityTooLarge, "Request body too large"
+	}
+
+	event, err = h.decode(data)
+	if err != nil {
+		return event, http.StatusBadRequest, fmt.Sprintf("Invalid event: %v", err)
+	}
+
+	return event, 0, ""
+}
+
+func (h *Handler[Event]) decode(data []byte) (Event, error) {
+	if h.config.Codec != nil {
+		return h.config.Codec.Decode(data)
+	}
+
+	return codec.JSON[Event]{}.Decode(data)
+}
+
+func (h *Handler[Event]) maxBodySizeOrDefault() int64 {
+	if h.config.MaxBodySize == 0 {
+		return DefaultMaxBodySize
+	}
+
+	return h.config.MaxBodySize
+}
+
+// nonNil makes sure empty lists are encoded as an empty JSON array instead of null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+
+	return list
+}
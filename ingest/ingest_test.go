@@ -0,0 +1,248 @@
+package ingest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+	"github.com/JosiahWitt/eventbus/eventbustest"
+	"github.com/JosiahWitt/eventbus/ingest"
+)
+
+type Message struct {
+	ID       string   `json:"id"`
+	Body     string   `json:"body"`
+	Hashtags []string `json:"hashtags"`
+}
+
+func TestHandler(t *testing.T) {
+	ensure := ensure.New(t)
+
+	hashtags := func(r *http.Request, msg *Message) []string { return msg.Hashtags }
+
+	ensure.Run("publishes the decoded event and responds with a summary", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		sub1 := bus.Subscribe("go", "eventbus")
+		sub2 := bus.Subscribe("eventbus")
+
+		handler := ingest.NewHandler[*Message](bus, &ingest.Config[*Message]{Topics: hashtags})
+		resp := post(handler, `{"id":"1","body":"hello","hashtags":["go","eventbus","other"]}`)
+
+		ensure(resp.Code).Equals(http.StatusOK)
+		ensure(resp.Header().Get("Content-Type")).Equals("application/json")
+		ensure(decodeSummary(ensure, resp)).Equals(&ingest.Summary{
+			Topics:        []string{"go", "eventbus", "other"},
+			MatchedTopics: []string{"go", "eventbus"},
+			Delivered:     2,
+			Deduplicated:  1,
+		})
+
+		expected := &Message{ID: "1", Body: "hello", Hashtags: []string{"go", "eventbus", "other"}}
+		ensure(<-sub1.Channel()).Equals(expected)
+		ensure(<-sub2.Channel()).Equals(expected)
+	})
+
+	ensure.Run("summarizes an event without any subscriptions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		handler := ingest.NewHandler[*Message](bus, &ingest.Config[*Message]{Topics: hashtags})
+		resp := post(handler, `{"id":"1","hashtags":["go"]}`)
+
+		ensure(resp.Code).Equals(http.StatusOK)
+		ensure(resp.Body.String()).Equals(`{"topics":["go"],"matchedTopics":[],"delivered":0,"deduplicated":0,"dropped":0}` + "\n")
+	})
+
+	ensure.Run("only summarizes the topics for publishers that don't report deliveries", func(ensure ensurepkg.Ensure) {
+		publisher := eventbustest.NewFakeBus[*Message]()
+
+		var report *eventbus.PublishReport[*Message]
+		handler := ingest.NewHandler[*Message](publisher, &ingest.Config[*Message]{
+			Topics: hashtags,
+			OnPublish: func(r *http.Request, msg *Message, publishReport *eventbus.PublishReport[*Message]) {
+				report = publishReport
+			},
+		})
+		resp := post(handler, `{"id":"1","hashtags":["go"]}`)
+
+		ensure(resp.Code).Equals(http.StatusOK)
+		ensure(resp.Body.String()).Equals(`{"topics":["go"],"matchedTopics":[],"delivered":0,"deduplicated":0,"dropped":0}` + "\n")
+		ensure(report == nil).IsTrue()
+		publisher.AssertPublished(ensure.T(), &Message{ID: "1", Hashtags: []string{"go"}}, "go")
+	})
+
+	ensure.Run("decodes events with the provided codec", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		defer bus.Close()
+
+		sub := bus.Subscribe("key1")
+
+		handler := ingest.NewHandler[string](bus, &ingest.Config[string]{
+			Topics: func(r *http.Request, event string) []string { return []string{r.URL.Query().Get("topic")} },
+			Codec: codec.Funcs[string]{
+				DecodeFunc: func(data []byte) (string, error) { return strings.ToUpper(string(data)), nil },
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/?topic=key1", strings.NewReader("hello"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		ensure(resp.Code).Equals(http.StatusOK)
+		ensure(<-sub.Channel()).Equals("HELLO")
+	})
+
+	ensure.Run("calls OnPublish with the report", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		sub := bus.Subscribe("go")
+
+		var published *Message
+		var report *eventbus.PublishReport[*Message]
+
+		handler := ingest.NewHandler[*Message](bus, &ingest.Config[*Message]{
+			Topics: hashtags,
+			OnPublish: func(r *http.Request, msg *Message, publishReport *eventbus.PublishReport[*Message]) {
+				published = msg
+				report = publishReport
+			},
+		})
+
+		resp := post(handler, `{"id":"1","hashtags":["go"]}`)
+		ensure(resp.Code).Equals(http.StatusOK)
+		ensure(<-sub.Channel()).Equals(published)
+		ensure(published.ID).Equals("1")
+		ensure(report.MatchedTopics).Equals([]string{"go"})
+		ensure(report.Delivered).Equals(1)
+	})
+
+	ensure.Run("rejects invalid requests", func(ensure ensurepkg.Ensure) {
+		table := []struct {
+			Name   string
+			Method string
+			Body   string
+			Config *ingest.Config[*Message]
+
+			ExpectedStatus int
+			ExpectedBody   string
+		}{
+			{
+				Name:   "when the method is not POST",
+				Method: http.MethodGet,
+				Config: &ingest.Config[*Message]{Topics: hashtags},
+
+				ExpectedStatus: http.StatusMethodNotAllowed,
+				ExpectedBody:   "Method not allowed\n",
+			},
+			{
+				Name:   "when the body is not valid JSON",
+				Method: http.MethodPost,
+				Body:   `{"id":`,
+				Config: &ingest.Config[*Message]{Topics: hashtags},
+
+				ExpectedStatus: http.StatusBadRequest,
+				ExpectedBody:   "Invalid event: unexpected end of JSON input\n",
+			},
+			{
+				Name:   "when the event has no topics",
+				Method: http.MethodPost,
+				Body:   `{"id":"1"}`,
+				Config: &ingest.Config[*Message]{Topics: hashtags},
+
+				ExpectedStatus: http.StatusBadRequest,
+				ExpectedBody:   "No topics to publish to\n",
+			},
+			{
+				Name:   "when the event is invalid",
+				Method: http.MethodPost,
+				Body:   `{"id":"1","hashtags":["go"]}`,
+				Config: &ingest.Config[*Message]{
+					Topics: hashtags,
+					Validate: func(r *http.Request, msg *Message) error {
+						if msg.Body == "" {
+							return errors.New("body is required")
+						}
+
+						return nil
+					},
+				},
+
+				ExpectedStatus: http.StatusUnprocessableEntity,
+				ExpectedBody:   "body is required\n",
+			},
+			{
+				Name:   "when the body is too large",
+				Method: http.MethodPost,
+				Body:   `{"id":"1","hashtags":["go"]}`,
+				Config: &ingest.Config[*Message]{
+					Topics:      hashtags,
+					MaxBodySize: 10,
+				},
+
+				ExpectedStatus: http.StatusRequestEntityTooLarge,
+				ExpectedBody:   "Request body too large\n",
+			},
+		}
+
+		ensure.RunTableByIndex(table, func(ensure ensurepkg.Ensure, i int) {
+			entry := table[i]
+
+			bus := eventbus.New[*Message]()
+			defer bus.Close()
+
+			sub := bus.Subscribe("go")
+			handler := ingest.NewHandler[*Message](bus, entry.Config)
+
+			req := httptest.NewRequest(entry.Method, "/", strings.NewReader(entry.Body))
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			ensure(resp.Code).Equals(entry.ExpectedStatus)
+			ensure(resp.Body.String()).Equals(entry.ExpectedBody)
+
+			sub.Unsubscribe()
+			_, ok := <-sub.Channel()
+			ensure(ok).IsFalse() // Nothing was published
+		})
+	})
+
+	ensure.Run("accepts bodies up to the max size", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		body := `{"id":"1","hashtags":["go"]}`
+		handler := ingest.NewHandler[*Message](bus, &ingest.Config[*Message]{
+			Topics:      hashtags,
+			MaxBodySize: int64(len(body)),
+		})
+
+		ensure(post(handler, body).Code).Equals(http.StatusOK)
+	})
+}
+
+func post(handler http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	return resp
+}
+
+func decodeSummary(ensure ensurepkg.Ensure, resp *httptest.ResponseRecorder) *ingest.Summary {
+	ensure.T().Helper()
+
+	summary := &ingest.Summary{}
+	ensure(json.NewDecoder(resp.Body).Decode(summary)).IsNotError()
+
+	return summary
+}
@@ -0,0 +1,83 @@
+package eventbus
+
+import "context"
+
+// Publisher publishes events.
+// It is implemented by EventBus and Broker, so code that only publishes can depend on it,
+// and decorators can wrap any implementation.
+type Publisher[Event any] interface {
+	// Publish sends the provided event to all of the listed topics.
+	Publish(event Event, topicKeys ...string)
+}
+
+// Subscriber creates subscriptions.
+// It is implemented by Broker, including the one returned by EventBus.Broker,
+// so code that only subscribes can depend on it, and be tested with any implementation.
+type Subscriber[Event any] interface {
+	// Subscribe creates a new subscription to the listed topics.
+	// The subscription receives all events published after Subscribe returns to any of those topics.
+	Subscribe(topicKeys ...string) Receiver[Event]
+}
+
+// ConfigurableSubscriber creates customized subscriptions.
+// It is implemented by the Broker returned by EventBus.Broker.
+type ConfigurableSubscriber[Event any] interface {
+	Subscriber[Event]
+
+	// SubscribeWithConfig creates a new customized subscription to the listed topics.
+	SubscribeWithConfig(config *SubscriptionConfig[Event], topicKeys ...string) Receiver[Event]
+}
+
+// ReportingPublisher publishes events, reporting how they were delivered.
+// It is implemented by EventBus and the Broker returned by EventBus.Broker.
+type ReportingPublisher[Event any] interface {
+	// PublishWithReport sends the provided event to all of the listed topics, and reports how it was delivered.
+	PublishWithReport(event Event, topicKeys ...string) *PublishReport[Event]
+}
+
+// SubscribeConfigured creates a new subscription to the listed topics using the subscriber.
+// The config customizes the subscription if the subscriber is a ConfigurableSubscriber, and is otherwise ignored.
+// If the config is nil, the subscriber's default configuration is used.
+func SubscribeConfigured[Event any](subscriber Subscriber[Event], config *SubscriptionConfig[Event], topicKeys ...string) Receiver[Event] {
+	if configurable, ok := subscriber.(ConfigurableSubscriber[Event]); ok && config != nil {
+		return configurable.SubscribeWithConfig(config, topicKeys...)
+	}
+
+	return subscriber.Subscribe(topicKeys...)
+}
+
+// Receiver receives the events of a subscription.
+// It is returned by Subscriber, and implemented by Subscription, so code that consumes events can depend on it,
+// and be tested with any source of events.
+type Receiver[Event any] interface {
+	// Channel returns the channel the events are sent to, which is closed once the subscription is closed.
+	Channel() <-chan Event
+
+	// Receive waits for the next event, or returns an error once no more events can be received.
+	Receive(ctx context.Context) (Event, error)
+
+	// Err returns why the subscription was closed, or nil while it is open.
+	Err() error
+
+	// Unsubscribe closes the subscription, and closes the channel.
+	Unsubscribe()
+}
+
+// PublisherFunc adapts a function into a Publisher, which is useful for decorators.
+type PublisherFunc[Event any] func(event Event, topicKeys ...string)
+
+// Publish calls the function.
+func (f PublisherFunc[Event]) Publish(event Event, topicKeys ...string) {
+	f(event, topicKeys...)
+}
+
+var (
+	_ Publisher[any]  = &EventBus[any]{}
+	_ Receiver[any]   = &Subscription[any]{}
+	_ Publisher[any]  = PublisherFunc[any](nil)
+	_ Subscriber[any] = memoryBroker[any]{}
+
+	_ ConfigurableSubscriber[any] = memoryBroker[any]{}
+	_ ReportingPublisher[any]     = &EventBus[any]{}
+	_ ReportingPublisher[any]     = memoryBroker[any]{}
+)
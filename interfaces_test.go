@@ -0,0 +1,111 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+// prefixTopics is an example decorator, which wraps any Publisher.
+func prefixTopics[Event any](prefix string, next eventbus.Publisher[Event]) eventbus.Publisher[Event] {
+	return eventbus.PublisherFunc[Event](func(event Event, topicKeys ...string) {
+		prefixed := make([]string, 0, len(topicKeys))
+		for _, topicKey := range topicKeys {
+			prefixed = append(prefixed, prefix+topicKey)
+		}
+
+		next.Publish(event, prefixed...)
+	})
+}
+
+// receiveOne is an example consumer, which depends on a Receiver.
+func receiveOne[Event any](ctx context.Context, receiver eventbus.Receiver[Event]) (Event, error) {
+	defer receiver.Unsubscribe()
+	return receiver.Receive(ctx)
+}
+
+// fakeReceiver is an example Receiver, which isn't backed by an EventBus.
+type fakeReceiver[Event any] struct {
+	ch chan Event
+}
+
+func (r *fakeReceiver[Event]) Channel() <-chan Event { return r.ch }
+func (r *fakeReceiver[Event]) Err() error            { return nil }
+func (r *fakeReceiver[Event]) Unsubscribe()          {}
+
+func (r *fakeReceiver[Event]) Receive(ctx context.Context) (Event, error) {
+	select {
+	case event := <-r.ch:
+		return event, nil
+	case <-ctx.Done():
+		var event Event
+		return event, ctx.Err()
+	}
+}
+
+// fakeSubscriber is an example Subscriber, which isn't backed by an EventBus.
+type fakeSubscriber[Event any] struct {
+	receiver *fakeReceiver[Event]
+	topics   []string
+}
+
+func (s *fakeSubscriber[Event]) Subscribe(topicKeys ...string) eventbus.Receiver[Event] {
+	s.topics = append(s.topics, topicKeys...)
+	return s.receiver
+}
+
+func TestInterfaces(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("decorates publishers", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		var subscriber eventbus.Subscriber[string] = bus.Broker()
+		sub := subscriber.Subscribe("tenant1.key1")
+
+		publisher := prefixTopics[string]("tenant1.", bus)
+		publisher.Publish("1", "key1")
+
+		event, err := receiveOne[string](context.Background(), sub)
+		ensure(err).IsNotError()
+		ensure(event).Equals("1")
+		ensure(sub.Err()).IsError(eventbus.ErrSubscriptionClosed)
+	})
+
+	ensure.Run("subscribers can be implemented without a bus", func(ensure ensurepkg.Ensure) {
+		subscriber := &fakeSubscriber[string]{receiver: &fakeReceiver[string]{ch: make(chan string, 1)}}
+		subscriber.receiver.ch <- "1"
+
+		var s eventbus.Subscriber[string] = subscriber
+		event, err := receiveOne[string](context.Background(), s.Subscribe("key1"))
+		ensure(err).IsNotError()
+		ensure(event).Equals("1")
+		ensure(subscriber.topics).Equals([]string{"key1"})
+	})
+
+	ensure.Run("brokers are publishers", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{}, "key1")
+
+		var publisher eventbus.Publisher[string] = bus.Broker()
+		publisher.Publish("1", "key1")
+
+		ensure(<-sub.Channel()).Equals("1")
+	})
+
+	ensure.Run("subscribes with the config when the subscriber supports it", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		config := &eventbus.SubscriptionConfig[string]{Name: "configured"}
+
+		sub := eventbus.SubscribeConfigured[string](bus.Broker(), config, "key1")
+		defer sub.Unsubscribe()
+		ensure(sub.(*eventbus.Subscription[string]).Name()).Equals("configured")
+
+		subscriber := &fakeSubscriber[string]{receiver: &fakeReceiver[string]{ch: make(chan string, 1)}}
+		eventbus.SubscribeConfigured[string](subscriber, config, "key2")
+		ensure(subscriber.topics).Equals([]string{"key2"})
+	})
+}
@@ -0,0 +1,127 @@
+// Package shardedmap provides a concurrent map partitioned into shards by the hash of the key.
+package shardedmap
+
+import "sync"
+
+// Map is a concurrent map partitioned into shards, each guarded by its own lock.
+// Changes only lock the shard containing the key, so changes to keys in different shards don't contend.
+type Map[V any] struct {
+	shards []shard[V]
+}
+
+type shard[V any] struct {
+	mu     sync.RWMutex
+	values map[string]V
+}
+
+// New creates a new Map with the number of shards.
+// If the number of shards is less than one, the map has a single shard.
+func New[V any](numShards int) *Map[V] {
+	if numShards < 1 {
+		numShards = 1
+	}
+
+	m := &Map[V]{
+		shards: make([]shard[V], numShards),
+	}
+
+	for i := range m.shards {
+		m.shards[i].values = make(map[string]V)
+	}
+
+	return m
+}
+
+// Load returns the value stored in the map for the key, and whether it was present.
+func (m *Map[V]) Load(key string) (value V, ok bool) {
+	s := m.shardFor(key)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok = s.values[key]
+	return value, ok
+}
+
+// Update calls fn with the value currently stored for the key, and whether it was present.
+// If fn returns keep as true, the returned value is stored for the key, otherwise the key is deleted.
+//
+// The key's shard is locked while fn is called, so fn must not use the map.
+func (m *Map[V]) Update(key string, fn func(value V, loaded bool) (newValue V, keep bool)) {
+	s := m.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, loaded := s.values[key]
+
+	newValue, keep := fn(value, loaded)
+	if !keep {
+		delete(s.values, key)
+		return
+	}
+
+	s.values[key] = newValue
+}
+
+// Len returns the number of keys in the map.
+// All shards are locked while counting, so the count is consistent.
+func (m *Map[V]) Len() int {
+	m.rLockAll()
+	defer m.rUnlockAll()
+
+	count := 0
+	for i := range m.shards {
+		count += len(m.shards[i].values)
+	}
+
+	return count
+}
+
+// Range calls f for each key and value in the map, stopping if f returns false.
+// All shards are locked while ranging, so f sees a consistent snapshot of the map, and must not use the map.
+func (m *Map[V]) Range(f func(key string, value V) bool) {
+	m.rLockAll()
+	defer m.rUnlockAll()
+
+	for i := range m.shards {
+		for key, value := range m.shards[i].values {
+			if !f(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// rLockAll locks all shards for reading, always in the same order to avoid deadlocks.
+func (m *Map[V]) rLockAll() {
+	for i := range m.shards {
+		m.shards[i].mu.RLock()
+	}
+}
+
+func (m *Map[V]) rUnlockAll() {
+	for i := range m.shards {
+		m.shards[i].mu.RUnlock()
+	}
+}
+
+func (m *Map[V]) shardFor(key string) *shard[V] {
+	return &m.shards[hash(key)%uint64(len(m.shards))]
+}
+
+// hash is the 64 bit FNV-1a hash of the key, which is inlined to avoid allocating.
+func hash(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+
+	return h
+}
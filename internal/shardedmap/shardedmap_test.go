@@ -0,0 +1,203 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus/internal/shardedmap"
+)
+
+func TestLoad(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("when value is present", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		store(m, "hello", 123)
+		store(m, "world", 456)
+
+		v, ok := m.Load("hello")
+		ensure(v).Equals(123)
+		ensure(ok).IsTrue()
+	})
+
+	ensure.Run("when value is not present", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		store(m, "hello", 123)
+
+		v, ok := m.Load("another")
+		ensure(v).Equals(0)
+		ensure(ok).IsFalse()
+	})
+
+	ensure.Run("when there are less than one shards", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](0)
+
+		store(m, "hello", 123)
+		store(m, "world", 456)
+
+		v, ok := m.Load("world")
+		ensure(v).Equals(456)
+		ensure(ok).IsTrue()
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("when value is not present", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		m.Update("hello", func(value int, loaded bool) (int, bool) {
+			ensure(value).Equals(0)
+			ensure(loaded).IsFalse()
+
+			return 123, true
+		})
+
+		v, ok := m.Load("hello")
+		ensure(v).Equals(123)
+		ensure(ok).IsTrue()
+	})
+
+	ensure.Run("when value is present", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		store(m, "hello", 123)
+
+		m.Update("hello", func(value int, loaded bool) (int, bool) {
+			ensure(value).Equals(123)
+			ensure(loaded).IsTrue()
+
+			return value + 1, true
+		})
+
+		v, ok := m.Load("hello")
+		ensure(v).Equals(124)
+		ensure(ok).IsTrue()
+	})
+
+	ensure.Run("deletes the value when not kept", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		store(m, "hello", 123)
+		store(m, "world", 456)
+
+		m.Update("hello", func(value int, loaded bool) (int, bool) {
+			return 0, false
+		})
+
+		v1, ok := m.Load("hello")
+		ensure(v1).Equals(0)
+		ensure(ok).IsFalse()
+
+		v2, ok := m.Load("world")
+		ensure(v2).Equals(456)
+		ensure(ok).IsTrue()
+	})
+
+	ensure.Run("concurrent updates to the same key are serialized", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		const numParallel = 100
+
+		var wg sync.WaitGroup
+		wg.Add(numParallel)
+
+		for i := 0; i < numParallel; i++ {
+			go func() {
+				defer wg.Done()
+
+				m.Update("counter", func(value int, loaded bool) (int, bool) {
+					return value + 1, true
+				})
+			}()
+		}
+
+		wg.Wait()
+
+		v, _ := m.Load("counter")
+		ensure(v).Equals(numParallel)
+	})
+}
+
+func TestLen(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("when empty", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+		ensure(m.Len()).Equals(0)
+	})
+
+	ensure.Run("when non-empty", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		for i := 0; i < 100; i++ {
+			store(m, fmt.Sprint(i), i)
+		}
+
+		ensure(m.Len()).Equals(100)
+	})
+}
+
+func TestRange(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("when empty", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		visited := map[string]int{}
+
+		m.Range(func(key string, value int) bool {
+			visited[key] = value
+			return true
+		})
+
+		ensure(visited).IsEmpty()
+	})
+
+	ensure.Run("when non-empty", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		store(m, "hello", 123)
+		store(m, "world", 456)
+
+		visited := map[string]int{}
+
+		m.Range(func(key string, value int) bool {
+			visited[key] = value
+			return true
+		})
+
+		ensure(visited).Equals(map[string]int{
+			"hello": 123,
+			"world": 456,
+		})
+	})
+
+	ensure.Run("when false is returned from the function", func(ensure ensurepkg.Ensure) {
+		m := shardedmap.New[int](4)
+
+		store(m, "hello", 123)
+		store(m, "world", 456)
+
+		visited := map[string]int{}
+
+		m.Range(func(key string, value int) bool {
+			visited[key] = value
+			return false
+		})
+
+		ensure(len(visited)).Equals(1)
+	})
+}
+
+func store[V any](m *shardedmap.Map[V], key string, value V) {
+	m.Update(key, func(V, bool) (V, bool) {
+		return value, true
+	})
+}
@@ -0,0 +1,155 @@
+// Package wire frames the messages sent between processes by the remote and cluster packages.
+package wire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+// ErrMessageTooLarge is returned when reading a message larger than the maximum size.
+var ErrMessageTooLarge = errors.New("wire: message too large")
+
+// Message is sent over a connection.
+//
+// Each message is framed like codec.WriteFrame, by a 4 byte big endian length, followed by the kind,
+// the ID and sequence as uvarints, the number of topic keys as a uvarint,
+// each topic key and then the data, prefixed by their lengths as uvarints.
+// The meaning of each field depends on the kind, which is defined by the package using the messages.
+type Message struct {
+	Kind      byte
+	ID        uint64
+	Sequence  uint64
+	TopicKeys []string
+	Data      []byte
+}
+
+// Write writes the message to the writer, using a single call to Write.
+func Write(w io.Writer, msg *Message) error {
+	body := make([]byte, 4, 4+1+binary.MaxVarintLen64*4+len(msg.Data))
+	body = append(body, msg.Kind)
+	body = appendUvarint(body, msg.ID)
+	body = appendUvarint(body, msg.Sequence)
+	body = appendUvarint(body, uint64(len(msg.TopicKeys)))
+
+	for _, topicKey := range msg.TopicKeys {
+		body = appendUvarint(body, uint64(len(topicKey)))
+		body = append(body, topicKey...)
+	}
+
+	body = appendUvarint(body, uint64(len(msg.Data)))
+	body = append(body, msg.Data...)
+
+	binary.BigEndian.PutUint32(body, uint32(len(body)-4))
+
+	_, err := w.Write(body)
+	return err
+}
+
+// Read reads the next message from the reader.
+// If maxSize is positive, messages larger than it return ErrMessageTooLarge.
+func Read(r *bufio.Reader, maxSize int) (*Message, error) {
+	body, err := codec.ReadFrame(r, maxSize)
+	if err != nil {
+		if errors.Is(err, codec.ErrFrameTooLarge) {
+			return nil, ErrMessageTooLarge
+		}
+
+		return nil, err
+	}
+
+	return decode(body)
+}
+
+func decode(body []byte) (*Message, error) {
+	d := &decoder{body: body}
+
+	msg := &Message{
+		Kind:     d.byte(),
+		ID:       d.uvarint(),
+		Sequence: d.uvarint(),
+	}
+
+	numTopicKeys := d.uvarint()
+	if numTopicKeys > uint64(len(d.body)) { // Each topic key takes at least one byte
+		return nil, errors.New("wire: malformed message: too many topic keys")
+	}
+
+	for i := uint64(0); i < numTopicKeys; i++ {
+		msg.TopicKeys = append(msg.TopicKeys, string(d.bytes()))
+	}
+
+	msg.Data = d.bytes()
+
+	if d.err != nil {
+		return nil, fmt.Errorf("wire: malformed message: %w", d.err)
+	}
+
+	return msg, nil
+}
+
+// decoder reads the fields of a message, remembering the first error.
+type decoder struct {
+	body []byte
+	err  error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	if len(d.body) == 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+
+	b := d.body[0]
+	d.body = d.body[1:]
+
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	value, n := binary.Uvarint(d.body)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+
+	d.body = d.body[n:]
+
+	return value
+}
+
+func (d *decoder) bytes() []byte {
+	length := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+
+	if length > uint64(len(d.body)) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	b := d.body[:length:length]
+	d.body = d.body[length:]
+
+	return b
+}
+
+func appendUvarint(b []byte, value uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], value)
+
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,61 @@
+package wire_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus/internal/wire"
+)
+
+func TestWire(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("round trips messages", func(ensure ensurepkg.Ensure) {
+		messages := []*wire.Message{
+			{Kind: 1},
+			{Kind: 2, ID: 300, Sequence: 1 << 40, TopicKeys: []string{"key1", "", "key3"}, Data: []byte("data")},
+			{Kind: 3, Data: bytes.Repeat([]byte{'a'}, 100000)},
+		}
+
+		buf := &bytes.Buffer{}
+		for _, msg := range messages {
+			ensure(wire.Write(buf, msg)).IsNotError()
+		}
+
+		reader := bufio.NewReader(buf)
+		for _, expected := range messages {
+			msg, err := wire.Read(reader, 0)
+			ensure(err).IsNotError()
+
+			// Empty data is read as an empty slice
+			if expected.Data == nil {
+				expected.Data = []byte{}
+			}
+
+			ensure(msg).Equals(expected)
+		}
+
+		_, err := wire.Read(reader, 0)
+		ensure(err).IsError(io.EOF)
+	})
+
+	ensure.Run("rejects messages larger than the maximum size", func(ensure ensurepkg.Ensure) {
+		buf := &bytes.Buffer{}
+		ensure(wire.Write(buf, &wire.Message{Kind: 1, Data: []byte("0123456789")})).IsNotError()
+
+		_, err := wire.Read(bufio.NewReader(buf), 10)
+		ensure(err).IsError(wire.ErrMessageTooLarge)
+	})
+
+	ensure.Run("rejects malformed messages", func(ensure ensurepkg.Ensure) {
+		// The length says there are 3 bytes: the kind, the ID, and a truncated sequence
+		buf := bytes.NewReader([]byte{0, 0, 0, 3, 1, 1, 0x80})
+
+		_, err := wire.Read(bufio.NewReader(buf), 0)
+		ensure(err.Error()).Equals("wire: malformed message: unexpected EOF")
+	})
+}
@@ -0,0 +1,542 @@
+// Package wsproto implements the parts of the WebSocket protocol (RFC 6455) needed to exchange messages.
+// Extensions and subprotocols are not supported.
+package wsproto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // Required by the protocol
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Opcodes of the message types.
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// Status codes sent when closing the connection.
+const (
+	CloseNormal          = 1000
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	CloseMessageTooLarge = 1009
+)
+
+// acceptGUID is appended to the client's key to compute the accept header.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// payloadChunkSize is the size of payloads that are allocated up front.
+// Larger payloads are read as they arrive, so a frame's header can't allocate more memory than the data that was sent.
+const payloadChunkSize = 64 << 10
+
+var (
+	// ErrClosed is returned when reading from a connection that was closed by the other side.
+	ErrClosed = errors.New("wsproto: connection closed")
+
+	// ErrMessageTooLarge is returned when reading a message larger than the read limit.
+	ErrMessageTooLarge = errors.New("wsproto: message too large")
+
+	errProtocol       = errors.New("wsproto: protocol error")
+	errInvalidPayload = errors.New("wsproto: invalid UTF-8")
+)
+
+// Conn is a WebSocket connection.
+// Reading must be done from a single goroutine, but writing is safe from multiple goroutines.
+type Conn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	isClient bool
+
+	readLimit    int64
+	writeTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+}
+
+// Upgrade upgrades the HTTP request to a WebSocket connection.
+// If the request is not a valid WebSocket handshake, it responds with a 400 Bad Request and returns an error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if err := checkHandshake(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("wsproto: response does not support hijacking")
+	}
+
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("wsproto: unable to hijack connection: %w", err)
+	}
+
+	// The server's read and write timeouts are meant for HTTP requests, and would otherwise end the connection.
+	// Newer versions of net/http clear them when hijacking, but older versions and other Hijackers don't.
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("wsproto: unable to clear deadlines: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"
+
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("wsproto: unable to complete handshake: %w", err)
+	}
+
+	return &Conn{conn: netConn, reader: rw.Reader}, nil
+}
+
+func checkHandshake(r *http.Request) error {
+	switch {
+	case r.Method != http.MethodGet:
+		return errors.New("WebSocket handshake must use GET")
+	case !headerContainsToken(r.Header, "Connection", "upgrade"):
+		return errors.New("WebSocket handshake is missing Connection: Upgrade")
+	case !headerContainsToken(r.Header, "Upgrade", "websocket"):
+		return errors.New("WebSocket handshake is missing Upgrade: websocket")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		return errors.New("WebSocket version must be 13")
+	case r.Header.Get("Sec-WebSocket-Key") == "":
+		return errors.New("WebSocket handshake is missing Sec-WebSocket-Key")
+	}
+
+	return nil
+}
+
+// Dial opens a WebSocket connection to the ws:// URL.
+func Dial(rawURL string) (*Conn, error) {
+	return DialWithHeader(rawURL, nil)
+}
+
+// DialWithHeader opens a WebSocket connection to the ws:// URL, sending the header with the handshake.
+func DialWithHeader(rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("wsproto: invalid URL: %w", err)
+	}
+
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("wsproto: unsupported scheme %q", u.Scheme)
+	}
+
+	netConn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("wsproto: unable to dial: %w", err)
+	}
+
+	conn, err := clientHandshake(netConn, u, header)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func clientHandshake(netConn net.Conn, u *url.URL, header http.Header) (*Conn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("wsproto: unable to generate key: %w", err)
+	}
+
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+
+	if err := req.Write(netConn); err != nil {
+		return nil, fmt.Errorf("wsproto: unable to send handshake: %w", err)
+	}
+
+	reader := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("wsproto: unable to read handshake: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("wsproto: handshake failed with status %d", resp.StatusCode)
+	}
+
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("wsproto: handshake failed with an invalid accept key")
+	}
+
+	return &Conn{conn: netConn, reader: reader, isClient: true}, nil
+}
+
+// SetReadLimit limits the size of the messages that can be read, in bytes.
+// If zero or negative, the size is not limited, but memory is only allocated as the message's data is received.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetWriteTimeout limits how long each write can take.
+// If zero or negative, writes can take any amount of time.
+func (c *Conn) SetWriteTimeout(timeout time.Duration) {
+	c.writeTimeout = timeout
+}
+
+// ReadMessage reads the next text or binary message, returning its opcode and data.
+// Pings are answered while reading, including between the frames of a fragmented message,
+// and ErrClosed is returned once the other side closes the connection, after replying with its status code.
+// Text messages must be valid UTF-8.
+func (c *Conn) ReadMessage() (opcode int, data []byte, err error) {
+	for {
+		fin, frameOpcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.failRead(err)
+		}
+
+		switch frameOpcode {
+		// Control frames can arrive between the frames of a fragmented message, so they don't end the message
+		case OpPing:
+			if err := c.WriteMessage(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+
+		case OpPong:
+			continue // Pongs only keep the connection alive
+
+		case OpClose:
+			reply, err := closeReply(payload)
+			if err != nil {
+				return 0, nil, c.failRead(err)
+			}
+
+			c.writeClose(reply) //nolint:errcheck // The connection is closed either way
+			return 0, nil, ErrClosed
+
+		case OpText, OpBinary:
+			if opcode != 0 {
+				return 0, nil, c.failRead(fmt.Errorf("%w: expected a continuation frame", errProtocol))
+			}
+
+			opcode = frameOpcode
+			data = payload
+
+		case OpContinuation:
+			if opcode == 0 {
+				return 0, nil, c.failRead(fmt.Errorf("%w: unexpected continuation frame", errProtocol))
+			}
+
+			data = append(data, payload...)
+
+		default:
+			return 0, nil, c.failRead(fmt.Errorf("%w: unknown opcode %d", errProtocol, frameOpcode))
+		}
+
+		if c.readLimit > 0 && int64(len(data)) > c.readLimit {
+			return 0, nil, c.failRead(ErrMessageTooLarge)
+		}
+
+		if opcode != 0 && fin {
+			if opcode == OpText && !utf8.Valid(data) {
+				return 0, nil, c.failRead(errInvalidPayload)
+			}
+
+			return opcode, data, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0F)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: unexpected reserved bits", errProtocol)
+	}
+
+	// Clients must mask their frames, and servers must not
+	if masked == c.isClient {
+		return false, 0, nil, fmt.Errorf("%w: unexpected masking", errProtocol)
+	}
+
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		length = binary.BigEndian.Uint64(extended[:])
+
+		// The most significant bit must be zero
+		if length > math.MaxInt64 {
+			return false, 0, nil, fmt.Errorf("%w: invalid payload length", errProtocol)
+		}
+	}
+
+	if opcode >= OpClose && (length > 125 || !fin) {
+		return false, 0, nil, fmt.Errorf("%w: invalid control frame", errProtocol)
+	}
+
+	if c.readLimit > 0 && length > uint64(c.readLimit) {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload, err = readPayload(c.reader, int64(length))
+	if err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		maskBytes(mask, payload)
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// readPayload reads the payload with the length.
+// Payloads larger than payloadChunkSize are read as they arrive, instead of allocating the whole length up front.
+func readPayload(r io.Reader, length int64) ([]byte, error) {
+	if length <= payloadChunkSize {
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+
+		return payload, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, payloadChunkSize))
+	if _, err := io.CopyN(buf, r, length); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// failRead closes the connection with a status describing why reading failed.
+func (c *Conn) failRead(err error) error {
+	switch {
+	case errors.Is(err, ErrMessageTooLarge):
+		c.writeClose(closePayload(CloseMessageTooLarge)) //nolint:errcheck // Reading already failed
+	case errors.Is(err, errProtocol):
+		c.writeClose(closePayload(CloseProtocolError)) //nolint:errcheck // Reading already failed
+	case errors.Is(err, errInvalidPayload):
+		c.writeClose(closePayload(CloseInvalidPayload)) //nolint:errcheck // Reading already failed
+	}
+
+	return err
+}
+
+// WriteMessage writes a message with the opcode and data.
+func (c *Conn) WriteMessage(opcode int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	return c.writeFrame(opcode, data)
+}
+
+// Close sends a close message if one wasn't already sent, and closes the connection.
+func (c *Conn) Close() error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		c.writeClose(closePayload(CloseNormal)) //nolint:errcheck // The connection may already be broken
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+func (c *Conn) writeClose(payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return nil
+	}
+
+	c.closeSent = true
+	return c.writeFrame(OpClose, payload)
+}
+
+// writeFrame writes the data as a single frame. The caller must hold writeMu.
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	frame := make([]byte, 0, len(data)+14)
+	frame = append(frame, 0x80|byte(opcode))
+
+	var maskBit byte
+	if c.isClient {
+		maskBit = 0x80
+	}
+
+	switch length := len(data); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[len(frame)-2:], uint16(length))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(length))
+	}
+
+	if c.isClient {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("wsproto: unable to generate mask: %w", err)
+		}
+
+		frame = append(frame, mask[:]...)
+		payloadStart := len(frame)
+		frame = append(frame, data...)
+		maskBytes(mask, frame[payloadStart:])
+	} else {
+		frame = append(frame, data...)
+	}
+
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)) //nolint:errcheck // Writing reports any issues
+		defer c.conn.SetWriteDeadline(time.Time{})              //nolint:errcheck // Writing reports any issues
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// closeReply validates the payload of a close frame, returning the payload of the reply, which echoes its status code.
+func closeReply(payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	if len(payload) == 1 {
+		return nil, fmt.Errorf("%w: truncated close status code", errProtocol)
+	}
+
+	code := int(binary.BigEndian.Uint16(payload))
+	if !isValidCloseCode(code) {
+		return nil, fmt.Errorf("%w: invalid close status code %d", errProtocol, code)
+	}
+
+	if !utf8.Valid(payload[2:]) {
+		return nil, errInvalidPayload
+	}
+
+	return closePayload(code), nil
+}
+
+// isValidCloseCode reports whether the status code can be sent in a close frame.
+// Codes 1005, 1006, and 1015 are reserved for reporting locally, and are never sent.
+func isValidCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003:
+		return true
+	case code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+
+	return false
+}
+
+func closePayload(code int) []byte {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return payload
+}
+
+func acceptKey(key string) string {
+	h := sha1.New() //nolint:gosec // Required by the protocol
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,516 @@
+package wsproto_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus/internal/wsproto"
+)
+
+func TestConn(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("exchanges messages of every length encoding", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		for _, size := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+			data := bytes.Repeat([]byte{'a'}, size)
+			ensure(client.WriteMessage(wsproto.OpBinary, data)).IsNotError()
+
+			opcode, echoed, err := client.ReadMessage()
+			ensure(err).IsNotError()
+			ensure(opcode).Equals(wsproto.OpBinary)
+			ensure(echoed).Equals(data)
+		}
+
+		ensure(client.WriteMessage(wsproto.OpText, []byte("hello"))).IsNotError()
+
+		opcode, echoed, err := client.ReadMessage()
+		ensure(err).IsNotError()
+		ensure(opcode).Equals(wsproto.OpText)
+		ensure(string(echoed)).Equals("hello")
+	})
+
+	ensure.Run("answers pings while reading", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		// The ping is answered by the server while it waits for the next message, so the message is still echoed
+		ensure(client.WriteMessage(wsproto.OpPing, []byte("ping"))).IsNotError()
+		ensure(client.WriteMessage(wsproto.OpText, []byte("hello"))).IsNotError()
+
+		_, echoed, err := client.ReadMessage()
+		ensure(err).IsNotError()
+		ensure(string(echoed)).Equals("hello")
+	})
+
+	ensure.Run("reports when the other side closes the connection", func(ensure ensurepkg.Ensure) {
+		closed := make(chan error, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := wsproto.Upgrade(w, r)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			_, _, err = conn.ReadMessage()
+			closed <- err
+		}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		ensure(client.Close()).IsNotError()
+
+		ensure(<-closed).IsError(wsproto.ErrClosed)
+		ensure(client.WriteMessage(wsproto.OpText, []byte("hello"))).IsError(wsproto.ErrClosed)
+	})
+
+	ensure.Run("closes the connection when a message is larger than the read limit", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(5)
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		ensure(client.WriteMessage(wsproto.OpText, []byte("hello"))).IsNotError()
+		_, _, err := client.ReadMessage()
+		ensure(err).IsNotError()
+
+		ensure(client.WriteMessage(wsproto.OpText, []byte("hello!"))).IsNotError()
+		_, _, err = client.ReadMessage()
+		ensure(err).IsError(wsproto.ErrClosed)
+	})
+
+	ensure.Run("allocates large payloads as they arrive when the size is not limited", func(ensure ensurepkg.Ensure) {
+		errs := make(chan error, 1)
+		server := newEchoServerWithErrors(0, errs)
+		defer server.Close()
+
+		client := dialRaw(ensure, server)
+
+		// The header claims a terabyte, but only a few bytes are sent before disconnecting
+		header := []byte{0x80 | wsproto.OpBinary, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(header[2:10], 1<<40)
+		client.writeRaw(ensure, append(header, bytes.Repeat([]byte{'a'}, 100<<10)...))
+		client.conn.Close()
+
+		ensure(<-errs).IsError(io.ErrUnexpectedEOF)
+	})
+
+	ensure.Run("rejects payload lengths with the most significant bit set", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		client := dialRaw(ensure, server)
+		defer client.conn.Close()
+
+		header := []byte{0x80 | wsproto.OpBinary, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(header[2:10], 1<<63)
+		client.writeRaw(ensure, header)
+
+		ensure(client.readClose(ensure)).Equals(wsproto.CloseProtocolError)
+	})
+
+	ensure.Run("rejects requests that are not handshakes", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		ensure(err).IsNotError()
+		defer resp.Body.Close()
+
+		ensure(resp.StatusCode).Equals(http.StatusBadRequest)
+
+		_, err = wsproto.Dial(server.URL)
+		ensure(err.Error()).Equals(`wsproto: unsupported scheme "http"`)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	ensure := ensure.New(t)
+
+	const fin = 0x80
+
+	ensure.Run("reassembles fragmented messages", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		client := dialRaw(ensure, server)
+		defer client.conn.Close()
+
+		client.writeFrame(ensure, wsproto.OpText, []byte("hel"))
+		client.writeFrame(ensure, wsproto.OpContinuation, []byte("lo"))
+		client.writeFrame(ensure, fin|wsproto.OpContinuation, []byte(" world"))
+
+		first, payload := client.readFrame(ensure)
+		ensure(first).Equals(byte(fin | wsproto.OpText))
+		ensure(string(payload)).Equals("hello world")
+	})
+
+	ensure.Run("answers pings between the frames of fragmented messages", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		client := dialRaw(ensure, server)
+		defer client.conn.Close()
+
+		client.writeFrame(ensure, wsproto.OpBinary, []byte("a"))
+		client.writeFrame(ensure, fin|wsproto.OpPing, []byte("ping"))
+		client.writeFrame(ensure, fin|wsproto.OpPong, []byte("unsolicited"))
+		client.writeFrame(ensure, fin|wsproto.OpContinuation, []byte("b"))
+
+		first, payload := client.readFrame(ensure)
+		ensure(first).Equals(byte(fin | wsproto.OpPong))
+		ensure(string(payload)).Equals("ping")
+
+		first, payload = client.readFrame(ensure)
+		ensure(first).Equals(byte(fin | wsproto.OpBinary))
+		ensure(string(payload)).Equals("ab")
+	})
+
+	ensure.Run("validates UTF-8 once text messages are reassembled", func(ensure ensurepkg.Ensure) {
+		server := newEchoServer(0)
+		defer server.Close()
+
+		client := dialRaw(ensure, server)
+		defer client.conn.Close()
+
+		// The two bytes of é are split across the frames
+		client.writeFrame(ensure, wsproto.OpText, []byte{0xC3})
+		client.writeFrame(ensure, fin|wsproto.OpContinuation, []byte{0xA9})
+
+		_, payload := client.readFrame(ensure)
+		ensure(string(payload)).Equals("é")
+	})
+
+	ensure.Run("echoes the status code when the other side closes", func(ensure ensurepkg.Ensure) {
+		for _, code := range []int{wsproto.CloseNormal, 1001, 1011, 3000, 4999} {
+			server := newEchoServer(0)
+			client := dialRaw(ensure, server)
+
+			client.writeFrame(ensure, fin|wsproto.OpClose, append(closePayload(code), "goodbye"...))
+			ensure(client.readClose(ensure)).Equals(code)
+
+			client.conn.Close()
+			server.Close()
+		}
+	})
+
+	ensure.Run("replies without a status code to closes without one", func(ensure ensurepkg.Ensure) {
+		errs := make(chan error, 1)
+		server := newEchoServerWithErrors(0, errs)
+		defer server.Close()
+
+		client := dialRaw(ensure, server)
+		defer client.conn.Close()
+
+		client.writeFrame(ensure, fin|wsproto.OpClose, nil)
+
+		first, payload := client.readFrame(ensure)
+		ensure(first).Equals(byte(fin | wsproto.OpClose))
+		ensure(payload).IsEmpty()
+		ensure(<-errs).IsError(wsproto.ErrClosed)
+	})
+
+	invalidFrames := []struct {
+		Name         string
+		Frames       func(ensure ensurepkg.Ensure, client *rawClient)
+		ExpectedCode int
+	}{
+		{
+			Name: "fragmented control frames",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, wsproto.OpPing, []byte("ping"))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "control frames longer than 125 bytes",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpPing, bytes.Repeat([]byte{'a'}, 126))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "continuation frames without a message",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpContinuation, []byte("a"))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "new messages within fragmented messages",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, wsproto.OpText, []byte("a"))
+				client.writeFrame(ensure, fin|wsproto.OpText, []byte("b"))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "reserved bits",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|0x40|wsproto.OpText, []byte("a"))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "unknown opcodes",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|0x3, []byte("a"))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "unmasked frames",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeRaw(ensure, []byte{fin | wsproto.OpText, 1, 'a'})
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "text messages that are not UTF-8",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpText, []byte{'a', 0xFF})
+			},
+			ExpectedCode: wsproto.CloseInvalidPayload,
+		},
+		{
+			Name: "close frames with a truncated status code",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpClose, []byte{0x03})
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "close frames with reserved or unassigned status codes",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpClose, closePayload(1005))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "close frames with status codes out of range",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpClose, closePayload(999))
+			},
+			ExpectedCode: wsproto.CloseProtocolError,
+		},
+		{
+			Name: "close frames with reasons that are not UTF-8",
+			Frames: func(ensure ensurepkg.Ensure, client *rawClient) {
+				client.writeFrame(ensure, fin|wsproto.OpClose, append(closePayload(wsproto.CloseNormal), 0xFF))
+			},
+			ExpectedCode: wsproto.CloseInvalidPayload,
+		},
+	}
+
+	for _, entry := range invalidFrames {
+		entry := entry
+
+		ensure.Run("rejects "+entry.Name, func(ensure ensurepkg.Ensure) {
+			errs := make(chan error, 1)
+			server := newEchoServerWithErrors(0, errs)
+			defer server.Close()
+
+			client := dialRaw(ensure, server)
+			defer client.conn.Close()
+
+			entry.Frames(ensure, client)
+
+			ensure(client.readClose(ensure)).Equals(entry.ExpectedCode)
+			ensure(<-errs).IsNotNil()
+		})
+	}
+
+	ensure.Run("clears the server's deadlines after upgrading", func(ensure ensurepkg.Ensure) {
+		server := httptest.NewUnstartedServer(echoHandler(0, nil))
+		server.Config.ReadTimeout = 10 * time.Millisecond
+		server.Config.WriteTimeout = 10 * time.Millisecond
+		server.Start()
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		time.Sleep(50 * time.Millisecond)
+
+		ensure(client.WriteMessage(wsproto.OpText, []byte("hello"))).IsNotError()
+		_, echoed, err := client.ReadMessage()
+		ensure(err).IsNotError()
+		ensure(string(echoed)).Equals("hello")
+	})
+}
+
+// newEchoServer starts a server that echoes each message it reads.
+func newEchoServer(readLimit int64) *httptest.Server {
+	return newEchoServerWithErrors(readLimit, nil)
+}
+
+// newEchoServerWithErrors starts a server that echoes each message it reads, sending the error that stops reading.
+func newEchoServerWithErrors(readLimit int64, errs chan<- error) *httptest.Server {
+	return httptest.NewServer(echoHandler(readLimit, errs))
+}
+
+func echoHandler(readLimit int64, errs chan<- error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsproto.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetReadLimit(readLimit)
+
+		for {
+			opcode, data, err := conn.ReadMessage()
+			if err != nil {
+				if errs != nil {
+					errs <- err
+				}
+
+				return
+			}
+
+			if err := conn.WriteMessage(opcode, data); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func dial(ensure ensurepkg.Ensure, server *httptest.Server) *wsproto.Conn {
+	ensure.T().Helper()
+
+	conn, err := wsproto.Dial("ws" + strings.TrimPrefix(server.URL, "http"))
+	ensure(err).IsNotError()
+
+	return conn
+}
+
+// rawClient speaks the protocol by hand, so it can send frames that Conn never sends.
+type rawClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialRaw(ensure ensurepkg.Ensure, server *httptest.Server) *rawClient {
+	ensure.T().Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	ensure(err).IsNotError()
+
+	handshake := "GET / HTTP/1.1\r\n" +
+		"Host: " + server.Listener.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+
+	_, err = conn.Write([]byte(handshake))
+	ensure(err).IsNotError()
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	ensure(err).IsNotError()
+	ensure(resp.StatusCode).Equals(http.StatusSwitchingProtocols)
+	ensure(resp.Header.Get("Sec-WebSocket-Accept")).Equals("s3pPLMBiTxaQ9kYGzzhZRbK+xOo=") // From RFC 6455
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck // Reading and writing report any issues
+
+	return &rawClient{conn: conn, reader: reader}
+}
+
+// writeFrame writes a masked frame with the first byte of the header, which holds the FIN bit and opcode.
+func (c *rawClient) writeFrame(ensure ensurepkg.Ensure, first byte, payload []byte) {
+	ensure.T().Helper()
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{first}
+
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(length))
+	}
+
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	c.writeRaw(ensure, frame)
+}
+
+func (c *rawClient) writeRaw(ensure ensurepkg.Ensure, data []byte) {
+	ensure.T().Helper()
+
+	_, err := c.conn.Write(data)
+	ensure(err).IsNotError()
+}
+
+// readFrame reads an unmasked frame sent by the server, returning its first header byte and payload.
+func (c *rawClient) readFrame(ensure ensurepkg.Ensure) (first byte, payload []byte) {
+	ensure.T().Helper()
+
+	var header [2]byte
+	_, err := io.ReadFull(c.reader, header[:])
+	ensure(err).IsNotError()
+	ensure(header[1] & 0x80).Equals(byte(0)) // Servers must not mask their frames
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		_, err = io.ReadFull(c.reader, extended[:])
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		_, err = io.ReadFull(c.reader, extended[:])
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	ensure(err).IsNotError()
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	ensure(err).IsNotError()
+
+	return header[0], payload
+}
+
+// readClose reads a close frame sent by the server, returning its status code.
+func (c *rawClient) readClose(ensure ensurepkg.Ensure) int {
+	ensure.T().Helper()
+
+	first, payload := c.readFrame(ensure)
+	ensure(first).Equals(byte(0x80 | wsproto.OpClose))
+	ensure(len(payload) >= 2).IsTrue()
+
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func closePayload(code int) []byte {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return payload
+}
@@ -0,0 +1,27 @@
+//go:build go1.23
+
+package eventbus
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over the subscription's events, which can be used with a range loop:
+//
+//	for event := range sub.All(ctx) {
+//		...
+//	}
+//
+// The iteration stops once Receive returns an error, such as when the context is done or the subscription is closed.
+// Afterwards, the context's Err and the subscription's Err report why.
+func (s *Subscription[Event]) All(ctx context.Context) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		for {
+			event, err := s.Receive(ctx)
+			if err != nil || !yield(event) {
+				return
+			}
+		}
+	}
+}
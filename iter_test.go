@@ -0,0 +1,106 @@
+//go:build go1.23
+
+package eventbus_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestSubscriptionAll(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("iterates over the events until the subscription is unsubscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		go func() {
+			bus.Publish("1", "key1")
+			bus.Publish("2", "key1")
+			sub.Unsubscribe()
+		}()
+
+		events := []string{}
+		for event := range sub.All(context.Background()) {
+			events = append(events, event)
+		}
+
+		ensure(events).Equals([]string{"1", "2"})
+		ensure(sub.Err()).IsError(eventbus.ErrSubscriptionClosed)
+	})
+
+	ensure.Run("stops when the loop breaks", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key1")
+
+		for event := range sub.All(context.Background()) {
+			ensure(event).Equals("1")
+			break
+		}
+
+		ensure(<-sub.Channel()).Equals("2")
+	})
+
+	ensure.Run("stops when the context is canceled", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		bus.Publish("1", "key1")
+
+		for range sub.All(ctx) {
+			cancel()
+		}
+
+		ensure(ctx.Err()).IsError(context.Canceled)
+		ensure(sub.Err()).IsNotError()
+	})
+	ensure.Run("iterates over the events queued by PublishAsync when the bus is closed", func(ensure ensurepkg.Ensure) {
+		const numEvents = 5000
+
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: 1})
+		sub := bus.Subscribe("key1")
+
+		received := make(chan []string)
+		go func() {
+			events := []string{}
+			for event := range sub.All(context.Background()) {
+				events = append(events, event)
+			}
+
+			received <- events
+		}()
+
+		for i := 0; i < numEvents; i++ {
+			bus.PublishAsync(strconv.Itoa(i), "key1")
+		}
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			bus.Close()
+		}()
+
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			ensure.Failf("Timed out waiting for the bus to close")
+		}
+
+		events := <-received
+		ensure(len(events)).Equals(numEvents)
+		ensure(events[numEvents-1]).Equals(strconv.Itoa(numEvents - 1))
+		ensure(sub.Err()).IsNotError()
+	})
+}
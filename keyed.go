@@ -0,0 +1,316 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// maxInlineKeyedTopics is the number of keys that can be published to without allocating the list of their topics.
+const maxInlineKeyedTopics = 8
+
+// KeyedBus is an EventBus whose topic keys are any comparable type, such as a RoomID,
+// or a struct{ Tenant, Room string }, instead of strings.
+// Typed keys don't need to be formatted or parsed, and mistakes in the keys are caught at compile time.
+//
+// It is backed by an EventBus, so it delivers events in the same way.
+// Each key with subscriptions is mapped onto a topic of the EventBus.
+// The bus's logs show the keys, formatted with fmt.Sprint, instead of the topics they are mapped onto.
+//
+// It can be used by initializing a copy of the struct, or by calling the NewKeyed or NewKeyedWithConfig functions.
+type KeyedBus[K comparable, Event any] struct {
+	bus EventBus[Event]
+
+	mu        sync.RWMutex
+	keys      map[K]*internedKey
+	names     map[string]K
+	lastKeyID uint64
+}
+
+// internedKey is the name of the topic a key is mapped onto, which exists while it has subscriptions.
+type internedKey struct {
+	name string
+	refs int
+}
+
+// KeyedSubscription maintains subscriptions to multiple topics of a KeyedBus.
+// Events are sent to the Channel().
+type KeyedSubscription[K comparable, Event any] struct {
+	bus *KeyedBus[K, Event]
+	sub *Subscription[Event]
+
+	mu             sync.Mutex
+	topicKeys      []K
+	isUnsubscribed bool
+}
+
+// NewKeyed creates a new KeyedBus.
+func NewKeyed[K comparable, Event any]() *KeyedBus[K, Event] {
+	return NewKeyedWithConfig[K, Event](&Config{})
+}
+
+// NewKeyedWithConfig creates a new customized KeyedBus.
+func NewKeyedWithConfig[K comparable, Event any](config *Config) *KeyedBus[K, Event] {
+	b := &KeyedBus[K, Event]{}
+	b.bus.configure(config)
+	b.bus.formatTopic = b.formatTopic
+
+	return b
+}
+
+// Publish sends the provided event to all of the listed topics.
+// All subscriptions to those topics will be notified of the event.
+func (b *KeyedBus[K, Event]) Publish(event Event, topicKeys ...K) {
+	var inline [maxInlineKeyedTopics]string
+	if names := b.appendNames(inline[:0], topicKeys); len(names) > 0 {
+		b.bus.Publish(event, names...)
+	}
+}
+
+// TryPublish sends the provided event to all of the listed topics, without blocking.
+// All subscriptions to those topics will be notified of the event, unless their channel's buffer is full.
+//
+// If any subscriptions could not be notified, an error matching ErrWouldBlock when using errors.Is is returned.
+func (b *KeyedBus[K, Event]) TryPublish(event Event, topicKeys ...K) error {
+	var inline [maxInlineKeyedTopics]string
+	if names := b.appendNames(inline[:0], topicKeys); len(names) > 0 {
+		return b.bus.TryPublish(event, names...)
+	}
+
+	return nil
+}
+
+// Close stops the bus's background goroutines, like EventBus.Close.
+func (b *KeyedBus[K, Event]) Close() {
+	b.bus.Close()
+}
+
+// Subscribe creates a new subscription to the listed topics.
+// All events published to any of those topics will be sent to the subscription's channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func (b *KeyedBus[K, Event]) Subscribe(topicKeys ...K) *KeyedSubscription[K, Event] {
+	return b.SubscribeWithConfig(&SubscriptionConfig[Event]{}, topicKeys...)
+}
+
+// SubscribeWithConfig creates a new customized subscription to the listed topics.
+// All events published to any of those topics will be sent to the subscription's channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func (b *KeyedBus[K, Event]) SubscribeWithConfig(config *SubscriptionConfig[Event], topicKeys ...K) *KeyedSubscription[K, Event] {
+	topicKeys = uniqueKeys(nil, topicKeys)
+
+	return &KeyedSubscription[K, Event]{
+		bus:       b,
+		sub:       b.bus.SubscribeWithConfig(config, b.acquire(topicKeys)...),
+		topicKeys: topicKeys,
+	}
+}
+
+// Topics lists the keys of the topics that currently have subscriptions, in no particular order.
+func (b *KeyedBus[K, Event]) Topics() []K {
+	names := b.bus.Topics()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	topicKeys := make([]K, 0, len(names))
+	for _, name := range names {
+		if topicKey, ok := b.names[name]; ok {
+			topicKeys = append(topicKeys, topicKey)
+		}
+	}
+
+	return topicKeys
+}
+
+// NumTopics returns the number of topics that currently have subscriptions.
+func (b *KeyedBus[K, Event]) NumTopics() int {
+	return b.bus.NumTopics()
+}
+
+// Unsubscribe closes the subscription to the topics.
+// It also closes the subscription's channel.
+// Calling Unsubscribe more than once has no effect.
+func (s *KeyedSubscription[K, Event]) Unsubscribe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isUnsubscribed {
+		return
+	}
+
+	s.sub.Unsubscribe()
+	s.bus.release(s.topicKeys)
+
+	s.topicKeys = nil
+	s.isUnsubscribed = true
+}
+
+// AddTopics subscribes the subscription to the listed topics, in addition to the topics it is already subscribed to.
+// Topics the subscription is already subscribed to are ignored.
+// It has no effect once the subscription is unsubscribed.
+//
+// Like Subscription.AddTopics, events published while the topics are changing may not be delivered to the subscription,
+// but they are never delivered more than once.
+func (s *KeyedSubscription[K, Event]) AddTopics(topicKeys ...K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isUnsubscribed {
+		return
+	}
+
+	addedTopicKeys := uniqueKeys(s.topicKeys, topicKeys)
+	s.sub.AddTopics(s.bus.acquire(addedTopicKeys)...)
+	s.topicKeys = append(s.topicKeys, addedTopicKeys...)
+}
+
+// RemoveTopics unsubscribes the subscription from the listed topics.
+// Topics the subscription isn't subscribed to are ignored.
+// The subscription's channel stays open, even if the subscription is no longer subscribed to any topics.
+func (s *KeyedSubscription[K, Event]) RemoveTopics(topicKeys ...K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isUnsubscribed {
+		return
+	}
+
+	var removedTopicKeys, remainingTopicKeys []K
+	for _, topicKey := range s.topicKeys {
+		if containsKey(topicKeys, topicKey) {
+			removedTopicKeys = append(removedTopicKeys, topicKey)
+		} else {
+			remainingTopicKeys = append(remainingTopicKeys, topicKey)
+		}
+	}
+
+	s.sub.RemoveTopics(s.bus.appendNames(nil, removedTopicKeys)...)
+	s.bus.release(removedTopicKeys)
+	s.topicKeys = remainingTopicKeys
+}
+
+// Topics lists the keys of the topics the subscription is subscribed to.
+func (s *KeyedSubscription[K, Event]) Topics() []K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]K{}, s.topicKeys...)
+}
+
+// Channel exposes a read only view of the subscription's channel.
+// All events published to the subscribed topics will be published to this channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func (s *KeyedSubscription[K, Event]) Channel() <-chan Event {
+	return s.sub.Channel()
+}
+
+// Receive waits for the subscription's next event, like Subscription.Receive.
+func (s *KeyedSubscription[K, Event]) Receive(ctx context.Context) (Event, error) {
+	return s.sub.Receive(ctx)
+}
+
+// Err returns why the subscription was closed, like Subscription.Err.
+// It returns nil while the subscription is open.
+func (s *KeyedSubscription[K, Event]) Err() error {
+	return s.sub.Err()
+}
+
+var _ Receiver[any] = &KeyedSubscription[int, any]{}
+
+// appendNames appends the names of the topics with subscriptions to names.
+// Keys without subscriptions are skipped, since no events need to be delivered to them.
+func (b *KeyedBus[K, Event]) appendNames(names []string, topicKeys []K) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, topicKey := range topicKeys {
+		if key, ok := b.keys[topicKey]; ok {
+			names = append(names, key.name)
+		}
+	}
+
+	return names
+}
+
+// formatTopic returns the key mapped onto the topic, so diagnostics show it instead of the topic's name.
+func (b *KeyedBus[K, Event]) formatTopic(name string) string {
+	b.mu.RLock()
+	topicKey, ok := b.names[name]
+	b.mu.RUnlock()
+
+	if !ok {
+		return name
+	}
+
+	return fmt.Sprint(topicKey)
+}
+
+// acquire returns the names of the topics, mapping the keys onto new topics if they don't have subscriptions.
+// Each call must be paired with a call to release once the subscription is done with the keys.
+func (b *KeyedBus[K, Event]) acquire(topicKeys []K) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.keys == nil {
+		b.keys = make(map[K]*internedKey)
+		b.names = make(map[string]K)
+	}
+
+	names := make([]string, 0, len(topicKeys))
+	for _, topicKey := range topicKeys {
+		key, ok := b.keys[topicKey]
+		if !ok {
+			b.lastKeyID++
+			key = &internedKey{name: strconv.FormatUint(b.lastKeyID, 36)}
+			b.keys[topicKey] = key
+			b.names[key.name] = topicKey
+		}
+
+		key.refs++
+		names = append(names, key.name)
+	}
+
+	return names
+}
+
+// release forgets the topics of keys that no longer have subscriptions.
+func (b *KeyedBus[K, Event]) release(topicKeys []K) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, topicKey := range topicKeys {
+		key := b.keys[topicKey]
+
+		key.refs--
+		if key.refs == 0 {
+			delete(b.keys, topicKey)
+			delete(b.names, key.name)
+		}
+	}
+}
+
+// uniqueKeys returns the keys that aren't duplicates or existing keys.
+func uniqueKeys[K comparable](existing, topicKeys []K) []K {
+	unique := make([]K, 0, len(topicKeys))
+	for _, topicKey := range topicKeys {
+		if !containsKey(existing, topicKey) && !containsKey(unique, topicKey) {
+			unique = append(unique, topicKey)
+		}
+	}
+
+	return unique
+}
+
+func containsKey[K comparable](topicKeys []K, topicKey K) bool {
+	for _, k := range topicKeys {
+		if k == topicKey {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+type RoomKey struct {
+	Tenant string
+	Room   string
+}
+
+func TestKeyedBus(t *testing.T) {
+	ensure := ensure.New(t)
+
+	room1 := RoomKey{Tenant: "acme", Room: "1"}
+	room2 := RoomKey{Tenant: "acme", Room: "2"}
+	otherRoom1 := RoomKey{Tenant: "other", Room: "1"}
+
+	ensure.Run("delivers events by typed keys", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyed[RoomKey, string]()
+
+		sub1 := bus.Subscribe(room1)
+		sub2 := bus.Subscribe(room1, room2)
+		sub3 := bus.Subscribe(otherRoom1)
+
+		bus.Publish("1", room1, room2) // Only delivered once to sub2
+		bus.Publish("2", room2)
+		bus.Publish("3", RoomKey{Tenant: "acme", Room: "3"})
+
+		ensure(receiveFrom(sub1.Channel(), 1, 10*time.Millisecond)).Equals([]string{"1"})
+		ensure(receiveFrom(sub2.Channel(), 2, 10*time.Millisecond)).Equals([]string{"1", "2"})
+		ensure(receiveFrom(sub3.Channel(), 0, 10*time.Millisecond)).Equals([]string{})
+	})
+
+	ensure.Run("works when created by initializing the struct", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.KeyedBus[int, string]{}
+
+		sub := bus.Subscribe(42)
+		bus.Publish("1", 42)
+		bus.Publish("2", 43)
+
+		ensure(receiveFrom(sub.Channel(), 1, 10*time.Millisecond)).Equals([]string{"1"})
+	})
+
+	ensure.Run("uses the config", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyedWithConfig[int, string](&eventbus.Config{BufferSize: -1})
+		bus.Subscribe(1)
+
+		err := bus.TryPublish("1", 1)
+		ensure(errors.Is(err, eventbus.ErrWouldBlock)).IsTrue()
+		ensure(bus.TryPublish("1", 2)).IsNotError()
+	})
+
+	ensure.Run("lists the topics with subscriptions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyed[RoomKey, string]()
+		ensure(bus.Topics()).Equals([]RoomKey{})
+
+		sub1 := bus.Subscribe(room1, room2)
+		sub2 := bus.Subscribe(room2, room2)
+
+		topics := bus.Topics()
+		sort.Slice(topics, func(i, j int) bool { return topics[i].Room < topics[j].Room })
+		ensure(topics).Equals([]RoomKey{room1, room2})
+		ensure(bus.NumTopics()).Equals(2)
+		ensure(sub2.Topics()).Equals([]RoomKey{room2})
+
+		sub1.Unsubscribe()
+		ensure(bus.Topics()).Equals([]RoomKey{room2})
+
+		sub2.Unsubscribe()
+		sub2.Unsubscribe() // Has no effect
+		ensure(bus.Topics()).Equals([]RoomKey{})
+	})
+
+	ensure.Run("changes the topics of a subscription", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyed[RoomKey, string]()
+
+		sub := bus.Subscribe(room1)
+		sub.AddTopics(room1, room2)
+		ensure(sub.Topics()).Equals([]RoomKey{room1, room2})
+
+		bus.Publish("1", room2)
+		ensure(<-sub.Channel()).Equals("1")
+
+		sub.RemoveTopics(room1, otherRoom1)
+		ensure(sub.Topics()).Equals([]RoomKey{room2})
+		ensure(bus.Topics()).Equals([]RoomKey{room2})
+
+		bus.Publish("2", room1)
+		bus.Publish("3", room2)
+		ensure(receiveFrom(sub.Channel(), 1, 10*time.Millisecond)).Equals([]string{"3"})
+
+		sub.Unsubscribe()
+		sub.AddTopics(room1) // Has no effect
+		ensure(sub.Topics()).Equals([]RoomKey{})
+		ensure(bus.NumTopics()).Equals(0)
+
+		_, isOpen := <-sub.Channel()
+		ensure(isOpen).IsFalse()
+	})
+
+	ensure.Run("receives events until unsubscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyed[RoomKey, string]()
+
+		sub := bus.Subscribe(room1)
+		bus.Publish("1", room1)
+
+		event, err := sub.Receive(context.Background())
+		ensure(err).IsNotError()
+		ensure(event).Equals("1")
+		ensure(sub.Err()).IsNotError()
+
+		sub.Unsubscribe()
+
+		_, err = sub.Receive(context.Background())
+		ensure(err).IsError(eventbus.ErrSubscriptionClosed)
+		ensure(sub.Err()).IsError(eventbus.ErrSubscriptionClosed)
+	})
+
+	ensure.Run("keeps delivering to keys that are resubscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewKeyed[int, string]()
+
+		bus.Subscribe(1).Unsubscribe()
+		sub := bus.Subscribe(1)
+
+		bus.Publish("1", 1)
+		ensure(<-sub.Channel()).Equals("1")
+	})
+
+	ensure.Run("logs the keys instead of the topics they are mapped onto", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewKeyedWithConfig[RoomKey, string](&eventbus.Config{Logger: logger, BufferSize: 1})
+
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{Name: "room"}, room1, room2)
+		ensure(bus.TryPublish("1", room1)).IsNotError()
+		ensure(bus.TryPublish("2", room1)).IsError(eventbus.ErrWouldBlock)
+		sub.RemoveTopics(room2)
+
+		ensure(logger.lines()).Equals([]string{
+			"DEBUG eventbus: topic created [topic {acme 1}]",
+			"DEBUG eventbus: topic created [topic {acme 2}]",
+			"DEBUG eventbus: subscribed [subscription room subscription_id 1 topics [{acme 1} {acme 2}]]",
+			"WARN eventbus: event dropped [subscription room subscription_id 1 topic {acme 1} queue_depth 1]",
+			"DEBUG eventbus: topic deleted [topic {acme 2}]",
+		})
+	})
+}
+
+func TestKeyedPublishAllocations(t *testing.T) {
+	ensure := ensure.New(t)
+
+	const runs = 100
+
+	bus := eventbus.NewKeyedWithConfig[RoomKey, int](&eventbus.Config{
+		BufferSize: runs + 1, // AllocsPerRun does a warm up run
+	})
+
+	room1 := RoomKey{Tenant: "acme", Room: "1"}
+	room2 := RoomKey{Tenant: "acme", Room: "2"}
+	bus.Subscribe(room1, room2)
+	bus.Subscribe(room2)
+
+	allocs := testing.AllocsPerRun(runs, func() {
+		bus.Publish(1, room1, room2, RoomKey{Tenant: "acme", Room: "3"})
+	})
+	ensure(allocs).Equals(float64(0))
+}
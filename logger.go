@@ -0,0 +1,79 @@
+package eventbus
+
+import "sync/atomic"
+
+// Logger receives the bus's diagnostics, such as topics being created and deleted, subscriptions,
+// blocked deliveries, drops, and shutdown.
+// Attributes are passed as alternating keys and values, such as "topic" and the topic's key.
+//
+// It is implemented by *slog.Logger, so one can be used directly.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+func (b *EventBus[Event]) topicCreated(topicKey string) {
+	b.notifyTopicWatchers(topicKey)
+
+	if b.logger != nil {
+		b.logger.Debug("eventbus: topic created", "topic", b.topicName(topicKey))
+	}
+}
+
+func (b *EventBus[Event]) topicDeleted(topicKey string) {
+	b.notifyTopicWatchers(topicKey)
+
+	if b.logger != nil {
+		b.logger.Debug("eventbus: topic deleted", "topic", b.topicName(topicKey))
+	}
+}
+
+// topicName returns how the topic is identified in diagnostics.
+func (b *EventBus[Event]) topicName(topicKey string) string {
+	if b.formatTopic == nil {
+		return topicKey
+	}
+
+	return b.formatTopic(topicKey)
+}
+
+// topicNames returns how the topics are identified in diagnostics.
+func (b *EventBus[Event]) topicNames(topicKeys []string) []string {
+	if b.formatTopic == nil {
+		return topicKeys
+	}
+
+	names := make([]string, len(topicKeys))
+	for i, topicKey := range topicKeys {
+		names[i] = b.formatTopic(topicKey)
+	}
+
+	return names
+}
+
+// logAttrs returns the attributes identifying the subscription, followed by the provided attributes.
+func (s *Subscription[Event]) logAttrs(args ...any) []any {
+	return append([]any{"subscription", s.name, "subscription_id", s.id}, args...)
+}
+
+// logBlocked logs that delivering to the subscription is waiting for room in its channel.
+// It only logs once until a delivery succeeds without waiting, so continuously full subscriptions don't flood the log.
+func (s *Subscription[Event]) logBlocked(topicKey string) {
+	if s.bus.logger != nil && atomic.CompareAndSwapInt32(&s.isBlocked, 0, 1) {
+		s.bus.logger.Warn("eventbus: delivery blocked, subscription is full", s.logAttrs("topic", s.bus.topicName(topicKey), "queue_depth", len(s.ch))...)
+	}
+}
+
+// logUnblocked resets logBlocked once a delivery succeeds without waiting.
+func (s *Subscription[Event]) logUnblocked() {
+	if s.bus.logger != nil && atomic.LoadInt32(&s.isBlocked) == 1 {
+		atomic.StoreInt32(&s.isBlocked, 0)
+	}
+}
+
+func (s *Subscription[Event]) logDropped(topicKey string) {
+	if s.bus.logger != nil {
+		s.bus.logger.Warn("eventbus: event dropped", s.logAttrs("topic", s.bus.topicName(topicKey), "queue_depth", len(s.ch))...)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build go1.21
+
+package eventbus_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestSlogLogger(t *testing.T) {
+	ensure := ensure.New(t)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	bus := eventbus.NewWithConfig[string](&eventbus.Config{Logger: logger})
+	bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{Name: "audit"}, "key1")
+
+	ensure(strings.Contains(buf.String(), `msg="eventbus: subscribed" subscription=audit subscription_id=1 topics=[key1]`)).IsTrue()
+}
@@ -0,0 +1,128 @@
+package eventbus_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestLogger(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("logs topics and subscriptions", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{Logger: logger})
+
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{Name: "audit"}, "key1")
+		ensure(sub.Name()).Equals("audit")
+		sub.Unsubscribe()
+
+		ensure(logger.lines()).Equals([]string{
+			"DEBUG eventbus: topic created [topic key1]",
+			"DEBUG eventbus: subscribed [subscription audit subscription_id 1 topics [key1]]",
+			"DEBUG eventbus: unsubscribed [subscription audit subscription_id 1 reason eventbus: subscription is closed]",
+			"DEBUG eventbus: topic deleted [topic key1]",
+		})
+	})
+
+	ensure.Run("logs topics of the sharded registry", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{Logger: logger, TopicShards: 2})
+
+		bus.Subscribe("key1").Unsubscribe()
+
+		ensure(logger.lines()[0]).Equals("DEBUG eventbus: topic created [topic key1]")
+		ensure(logger.lines()[3]).Equals("DEBUG eventbus: topic deleted [topic key1]")
+	})
+
+	ensure.Run("logs blocked deliveries once and drops", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			Logger:         logger,
+			BufferSize:     1,
+			PublishTimeout: time.Millisecond,
+		})
+
+		bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{Name: "slow"}, "key1")
+		logger.reset()
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key1")
+		bus.Publish("3", "key1")
+		ensure(bus.TryPublish("4", "key1")).IsError(eventbus.ErrWouldBlock)
+
+		ensure(logger.lines()).Equals([]string{
+			"WARN eventbus: delivery blocked, subscription is full [subscription slow subscription_id 1 topic key1 queue_depth 1]",
+			"WARN eventbus: event dropped [subscription slow subscription_id 1 topic key1 queue_depth 1]",
+			"WARN eventbus: event dropped [subscription slow subscription_id 1 topic key1 queue_depth 1]",
+			"WARN eventbus: event dropped [subscription slow subscription_id 1 topic key1 queue_depth 1]",
+		})
+	})
+
+	ensure.Run("logs disconnecting subscriptions that overflow", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{Logger: logger, BufferSize: 1})
+
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{DisconnectOnOverflow: true}, "key1")
+		logger.reset()
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key1")
+		for range sub.Channel() { //nolint:revive // Waits for the subscription to be disconnected
+		}
+
+		ensure(logger.lines()).Equals([]string{
+			"WARN eventbus: disconnecting subscription that overflowed [subscription  subscription_id 1 topic key1 queue_depth 1]",
+			"DEBUG eventbus: unsubscribed [subscription  subscription_id 1 reason eventbus: subscription overflowed]",
+			"DEBUG eventbus: topic deleted [topic key1]",
+		})
+	})
+
+	ensure.Run("logs shutdown", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{Logger: logger})
+
+		bus.Close()
+		bus.Close() // Only logged once
+
+		ensure(logger.lines()).Equals([]string{
+			"INFO eventbus: closing [queue_depth 0 topics 0]",
+			"INFO eventbus: closed []",
+		})
+	})
+}
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.log("DEBUG", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.log("INFO", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.log("WARN", msg, args) }
+
+func (l *recordingLogger) log(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.messages = append(l.messages, fmt.Sprintf("%s %s %v", level, msg, args))
+}
+
+func (l *recordingLogger) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string{}, l.messages...)
+}
+
+func (l *recordingLogger) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.messages = nil
+}
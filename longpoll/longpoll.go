@@ -0,0 +1,386 @@
+// Package longpoll provides an http.Handler, which delivers events from an EventBus to clients using long polling.
+// It is useful for clients behind proxies that buffer streaming responses.
+//
+// The first poll from a client creates a session, which subscribes to the request's topics,
+// and holds the events published to them until the client polls for them.
+// Each response includes a cursor, which the client sends as the cursor query parameter of its next poll
+// to resume where it left off, without missing any events.
+// If a response is lost, polling again with the same cursor returns the same events.
+package longpoll
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+)
+
+const (
+	// DefaultPollTimeout for each poll. Used when the PollTimeout is not configured.
+	DefaultPollTimeout = 30 * time.Second
+
+	// DefaultSessionTimeout for idle sessions. Used when the SessionTimeout is not configured.
+	DefaultSessionTimeout = 2 * time.Minute
+
+	// DefaultMaxBufferedEvents for each session. Used when the MaxBufferedEvents is not configured.
+	DefaultMaxBufferedEvents = 1000
+
+	// DefaultMaxSessions held at once. Used when the MaxSessions is not configured.
+	DefaultMaxSessions = 10000
+)
+
+// Config can be passed to NewHandler to customize the Handler.
+type Config[Event any] struct {
+	// Topics returns the keys of the topics to subscribe to, when a request creates a new session.
+	// Requests resuming a session always receive the events for the topics the session was created with.
+	// If it returns no topic keys, the request fails with a 400 Bad Request.
+	// It is required.
+	Topics func(r *http.Request) []string
+
+	// PollTimeout is how long a poll waits for events before responding without any.
+	// If not set or zero, it defaults to DefaultPollTimeout.
+	PollTimeout time.Duration
+
+	// SessionTimeout is how long a session waits for the next poll before it is unsubscribed.
+	// Polls with a cursor for an expired session fail with a 410 Gone, so the client can start a new session.
+	// If not set or zero, it defaults to DefaultSessionTimeout.
+	SessionTimeout time.Duration
+
+	// MaxBufferedEvents is the number of events each session holds until the client polls for them.
+	// Once it is exceeded, the oldest events are discarded, and polls with a cursor before them fail with a 410 Gone.
+	// If not set or zero, it defaults to DefaultMaxBufferedEvents.
+	MaxBufferedEvents int
+
+	// MaxSessions is the number of sessions that can be held at once.
+	// Each session holds a subscription and its buffered events until it expires,
+	// so once the limit is reached, polls starting a new session fail with a 503 Service Unavailable.
+	// If not set or zero, it defaults to DefaultMaxSessions.
+	// If negative, the number of sessions is not limited.
+	MaxSessions int
+
+	// SubscriptionConfig customizes the subscription created for each session,
+	// if the subscriber is an eventbus.ConfigurableSubscriber, such as the Broker returned by EventBus.Broker.
+	// If not set, the subscriber's default subscription configuration is used.
+	SubscriptionConfig *eventbus.SubscriptionConfig[Event]
+}
+
+// Response is sent to the client as JSON.
+type Response[Event any] struct {
+	// Cursor to send with the next poll.
+	Cursor string `json:"cursor"`
+
+	// Events published since the poll's cursor, in order.
+	// It is empty if the poll timed out.
+	Events []Event `json:"events"`
+}
+
+// Handler parks each poll until events arrive for the session's topics, or the poll times out.
+type Handler[Event any] struct {
+	subscriber eventbus.Subscriber[Event]
+	config     *Config[Event]
+
+	mu       sync.Mutex
+	sessions map[string]*session[Event]
+	isClosed bool
+}
+
+type session[Event any] struct {
+	id  string
+	sub eventbus.Receiver[Event]
+
+	// Guarded by the handler's mutex, so sessions aren't expired while they are being polled
+	polls  int
+	expiry *time.Timer
+
+	mu       sync.Mutex
+	events   []sequencedEvent[Event]
+	lastSeq  uint64
+	ready    chan struct{} // Closed and replaced when events arrive, and closed once the session is unsubscribed
+	isClosed bool
+}
+
+type sequencedEvent[Event any] struct {
+	seq   uint64
+	event Event
+}
+
+var _ http.Handler = &Handler[any]{}
+
+// NewHandler creates a new Handler delivering events from the subscriber, such as the Broker returned by EventBus.Broker.
+func NewHandler[Event any](subscriber eventbus.Subscriber[Event], config *Config[Event]) *Handler[Event] {
+	return &Handler[Event]{
+		subscriber: subscriber,
+		config:     config,
+
+		sessions: make(map[string]*session[Event]),
+	}
+}
+
+// ServeHTTP responds with the events after the request's cursor, waiting for them to arrive if necessary.
+// Requests without a cursor start a new session.
+func (h *Handler[Event]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s, afterSeq, status, message := h.startPoll(r)
+	if status != 0 {
+		http.Error(w, message, status)
+		return
+	}
+	defer h.endPoll(s)
+
+	events, ok := s.waitForEvents(r, afterSeq, h.pollTimeoutOrDefault())
+	if !ok {
+		http.Error(w, "Events after the cursor were discarded", http.StatusGone)
+		return
+	}
+
+	lastSeq := afterSeq
+	resp := &Response[Event]{Events: make([]Event, 0, len(events))}
+	for _, event := range events {
+		resp.Events = append(resp.Events, event.event)
+		lastSeq = event.seq
+	}
+
+	resp.Cursor = formatCursor(s.id, lastSeq)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck // The client disconnected
+}
+
+// Close unsubscribes all sessions. Parked polls respond with the events they already have, if any,
+// and later polls fail with a 503 Service Unavailable.
+func (h *Handler[Event]) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.isClosed = true
+
+	for id, s := range h.sessions {
+		s.expiry.Stop()
+		s.sub.Unsubscribe()
+		delete(h.sessions, id)
+	}
+}
+
+// startPoll finds or creates the session for the request, returning the status and message to respond with if it fails.
+func (h *Handler[Event]) startPoll(r *http.Request) (s *session[Event], afterSeq uint64, status int, message string) {
+	cursor := r.URL.Query().Get("cursor")
+
+	var topicKeys []string
+	if cursor == "" {
+		topicKeys = h.config.Topics(r)
+		if len(topicKeys) == 0 {
+			return nil, 0, http.StatusBadRequest, "No topics requested"
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.isClosed {
+		return nil, 0, http.StatusServiceUnavailable, "Shutting down"
+	}
+
+	if cursor == "" {
+		if maxSessions := h.maxSessionsOrDefault(); maxSessions > 0 && len(h.sessions) >= maxSessions {
+			return nil, 0, http.StatusServiceUnavailable, "Too many sessions"
+		}
+
+		s = h.newSession(topicKeys)
+	} else {
+		id, seq, ok := parseCursor(cursor)
+		if !ok {
+			return nil, 0, http.StatusBadRequest, "Invalid cursor"
+		}
+
+		if s, ok = h.sessions[id]; !ok {
+			return nil, 0, http.StatusGone, "Session expired"
+		}
+
+		afterSeq = seq
+	}
+
+	s.polls++
+	s.expiry.Stop()
+
+	return s, afterSeq, 0, ""
+}
+
+func (h *Handler[Event]) endPoll(s *session[Event]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s.polls--
+	if s.polls == 0 && !h.isClosed {
+		s.expiry.Reset(h.sessionTimeoutOrDefault())
+	}
+}
+
+// newSession creates a session subscribed to the topics. The caller must hold mu.
+func (h *Handler[Event]) newSession(topicKeys []string) *session[Event] {
+	s := &session[Event]{
+		id:    newSessionID(),
+		ready: make(chan struct{}),
+	}
+
+	s.sub = eventbus.SubscribeConfigured(h.subscriber, h.config.SubscriptionConfig, topicKeys...)
+
+	s.expiry = time.AfterFunc(h.sessionTimeoutOrDefault(), func() { h.expire(s) })
+	h.sessions[s.id] = s
+
+	go s.collect(h.maxBufferedEventsOrDefault())
+
+	return s
+}
+
+func (h *Handler[Event]) expire(s *session[Event]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The session may have been polled or closed after the timer fired, but before we acquired the lock
+	if s.polls > 0 || h.sessions[s.id] != s {
+		return
+	}
+
+	delete(h.sessions, s.id)
+	s.sub.Unsubscribe()
+}
+
+// collect holds the subscription's events until they are polled, until the subscription is unsubscribed.
+func (s *session[Event]) collect(maxBufferedEvents int) {
+	for event := range s.sub.Channel() {
+		s.mu.Lock()
+
+		s.lastSeq++
+		s.events = append(s.events, sequencedEvent[Event]{seq: s.lastSeq, event: event})
+
+		if len(s.events) > maxBufferedEvents {
+			s.events[0] = sequencedEvent[Event]{} // Allow the event to be garbage collected
+			s.events = s.events[1:]
+		}
+
+		close(s.ready)
+		s.ready = make(chan struct{})
+
+		s.mu.Unlock()
+	}
+
+	// Wake the parked polls, so they don't wait for the timeout
+	s.mu.Lock()
+	s.isClosed = true
+	close(s.ready)
+	s.mu.Unlock()
+}
+
+// waitForEvents returns the events after the sequence number, waiting up to the timeout for them to arrive.
+// Earlier events are discarded, since the client received them.
+// It returns false if some of the events after the sequence number were already discarded.
+func (s *session[Event]) waitForEvents(r *http.Request, afterSeq uint64, timeout time.Duration) ([]sequencedEvent[Event], bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+
+		s.acknowledge(afterSeq)
+
+		if len(s.events) > 0 && s.events[0].seq > afterSeq+1 {
+			s.mu.Unlock()
+			return nil, false
+		}
+
+		if len(s.events) > 0 {
+			events := append([]sequencedEvent[Event](nil), s.events...)
+			s.mu.Unlock()
+			return events, true
+		}
+
+		if s.isClosed {
+			s.mu.Unlock()
+			return nil, true
+		}
+
+		ready := s.ready
+		s.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-timer.C:
+			return nil, true
+		case <-r.Context().Done():
+			return nil, true
+		}
+	}
+}
+
+// acknowledge discards the events up to the sequence number. The caller must hold mu.
+func (s *session[Event]) acknowledge(seq uint64) {
+	i := 0
+	for i < len(s.events) && s.events[i].seq <= seq {
+		s.events[i] = sequencedEvent[Event]{} // Allow the event to be garbage collected
+		i++
+	}
+
+	s.events = s.events[i:]
+}
+
+func newSessionID() string {
+	id := make([]byte, 16)
+	rand.Read(id) //nolint:errcheck // Never returns an error
+	return hex.EncodeToString(id)
+}
+
+func formatCursor(sessionID string, seq uint64) string {
+	return sessionID + "." + strconv.FormatUint(seq, 10)
+}
+
+func parseCursor(cursor string) (sessionID string, seq uint64, ok bool) {
+	i := strings.LastIndex(cursor, ".")
+	if i < 0 {
+		return "", 0, false
+	}
+
+	seq, err := strconv.ParseUint(cursor[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return cursor[:i], seq, true
+}
+
+func (h *Handler[Event]) pollTimeoutOrDefault() time.Duration {
+	if h.config.PollTimeout <= 0 {
+		return DefaultPollTimeout
+	}
+
+	return h.config.PollTimeout
+}
+
+func (h *Handler[Event]) sessionTimeoutOrDefault() time.Duration {
+	if h.config.SessionTimeout <= 0 {
+		return DefaultSessionTimeout
+	}
+
+	return h.config.SessionTimeout
+}
+
+func (h *Handler[Event]) maxSessionsOrDefault() int {
+	if h.config.MaxSessions == 0 {
+		return DefaultMaxSessions
+	}
+
+	return h.config.MaxSessions
+}
+
+func (h *Handler[Event]) maxBufferedEventsOrDefault() int {
+	if h.config.MaxBufferedEvents <= 0 {
+		return DefaultMaxBufferedEvents
+	}
+
+	return h.config.MaxBufferedEvents
+}
@@ -0,0 +1,213 @@
+package longpoll_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/longpoll"
+)
+
+func TestHandler(t *testing.T) {
+	ensure := ensure.New(t)
+
+	topicsFromQuery := func(r *http.Request) []string {
+		if topics := r.URL.Query().Get("topics"); topics != "" {
+			return strings.Split(topics, ",")
+		}
+
+		return nil
+	}
+
+	ensure.Run("resumes from the cursor without missing events", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:      topicsFromQuery,
+			PollTimeout: 10 * time.Millisecond,
+		})
+		defer handler.Close()
+
+		first := poll(ensure, handler, "?topics=key1,key2", http.StatusOK)
+		ensure(first.Events).Equals([]string{})
+
+		// Published between polls, so they are held by the session
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key3")
+		bus.Publish("3", "key2", "key1")
+
+		second := poll(ensure, handler, "?cursor="+first.Cursor, http.StatusOK)
+		ensure(second.Events).Equals([]string{"1", "3"})
+
+		// Polling again with the same cursor returns the same events, in case the response was lost
+		ensure(poll(ensure, handler, "?cursor="+first.Cursor, http.StatusOK)).Equals(second)
+
+		bus.Publish("4", "key2")
+
+		third := poll(ensure, handler, "?cursor="+second.Cursor, http.StatusOK)
+		ensure(third.Events).Equals([]string{"4"})
+	})
+
+	ensure.Run("parks the poll until events arrive", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:      topicsFromQuery,
+			PollTimeout: time.Minute,
+		})
+		defer handler.Close()
+
+		responses := make(chan *longpoll.Response[string])
+		go func() { responses <- poll(ensure, handler, "?topics=key1", http.StatusOK) }()
+
+		waitFor(func() bool { return bus.NumTopics() == 1 })
+		bus.Publish("1", "key1")
+
+		ensure((<-responses).Events).Equals([]string{"1"})
+	})
+
+	ensure.Run("responds without events when the poll times out", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:      topicsFromQuery,
+			PollTimeout: 10 * time.Millisecond,
+		})
+		defer handler.Close()
+
+		first := poll(ensure, handler, "?topics=key1", http.StatusOK)
+		second := poll(ensure, handler, "?cursor="+first.Cursor, http.StatusOK)
+
+		ensure(second.Events).Equals([]string{})
+		ensure(second.Cursor).Equals(first.Cursor)
+	})
+
+	ensure.Run("expires idle sessions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:         topicsFromQuery,
+			PollTimeout:    10 * time.Millisecond,
+			SessionTimeout: 10 * time.Millisecond,
+		})
+		defer handler.Close()
+
+		first := poll(ensure, handler, "?topics=key1", http.StatusOK)
+		ensure(bus.NumTopics()).Equals(1)
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.NumTopics()).Equals(0)
+
+		poll(ensure, handler, "?cursor="+first.Cursor, http.StatusGone)
+	})
+
+	ensure.Run("fails when events after the cursor were discarded", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:            topicsFromQuery,
+			PollTimeout:       10 * time.Millisecond,
+			MaxBufferedEvents: 2,
+		})
+		defer handler.Close()
+
+		first := poll(ensure, handler, "?topics=key1", http.StatusOK)
+
+		bus.Publish("1", "key1")
+		bus.Publish("2", "key1")
+		bus.Publish("3", "key1")
+
+		// The events are held by the session asynchronously
+		waitFor(func() bool { return poll(ensure, handler, "?cursor="+first.Cursor, 0) == nil })
+
+		poll(ensure, handler, "?cursor="+first.Cursor, http.StatusGone)
+	})
+
+	ensure.Run("limits the number of sessions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:         topicsFromQuery,
+			PollTimeout:    10 * time.Millisecond,
+			SessionTimeout: 50 * time.Millisecond,
+			MaxSessions:    2,
+		})
+		defer handler.Close()
+
+		first := poll(ensure, handler, "?topics=key1", http.StatusOK)
+		poll(ensure, handler, "?topics=key2", http.StatusOK)
+		poll(ensure, handler, "?topics=key3", http.StatusServiceUnavailable)
+		ensure(bus.NumTopics()).Equals(2)
+
+		// Existing sessions can still be polled, and new sessions can be started once sessions expire
+		poll(ensure, handler, "?cursor="+first.Cursor, http.StatusOK)
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		poll(ensure, handler, "?topics=key3", http.StatusOK)
+	})
+
+	ensure.Run("wakes parked polls when closed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{
+			Topics:      topicsFromQuery,
+			PollTimeout: time.Minute,
+		})
+
+		responses := make(chan *longpoll.Response[string])
+		go func() { responses <- poll(ensure, handler, "?topics=key1", http.StatusOK) }()
+
+		waitFor(func() bool { return bus.NumTopics() == 1 })
+		handler.Close()
+
+		select {
+		case resp := <-responses:
+			ensure(resp.Events).Equals([]string{})
+		case <-time.After(5 * time.Second):
+			ensure.Failf("Timed out waiting for the parked poll to respond")
+		}
+	})
+
+	ensure.Run("rejects invalid polls", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		handler := longpoll.NewHandler[string](bus.Broker(), &longpoll.Config[string]{Topics: topicsFromQuery})
+		defer handler.Close()
+
+		poll(ensure, handler, "", http.StatusBadRequest)
+		poll(ensure, handler, "?cursor=invalid", http.StatusBadRequest)
+		poll(ensure, handler, "?cursor=unknown.1", http.StatusGone)
+
+		handler.Close()
+		poll(ensure, handler, "?topics=key1", http.StatusServiceUnavailable)
+		ensure(bus.NumTopics()).Equals(0)
+	})
+}
+
+// poll sends a poll with the query, and ensures it responds with the expected status, unless it is zero.
+// It returns the response, or nil if the poll failed.
+func poll(ensure ensurepkg.Ensure, handler http.Handler, query string, expectedStatus int) *longpoll.Response[string] {
+	ensure.T().Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if expectedStatus != 0 {
+		ensure(resp.Code).Equals(expectedStatus)
+	}
+
+	if resp.Code != http.StatusOK {
+		return nil
+	}
+
+	body := &longpoll.Response[string]{}
+	ensure(json.NewDecoder(resp.Body).Decode(body)).IsNotError()
+
+	return body
+}
+
+func waitFor(condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package eventbus
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Registry hosts an EventBus for each event type, so a single value can be passed around
+// instead of a bus for every type.
+// Use the Publish, Subscribe, SubscribeWithConfig and Bus functions to use the bus for a type.
+//
+// It can be used by initializing a copy of the struct, or by calling the NewRegistry or NewRegistryWithConfig functions.
+type Registry struct {
+	config Config
+
+	mu    sync.RWMutex
+	buses map[reflect.Type]any // Holds an *EventBus[T] for each type T
+
+	anyBus EventBus[any]
+}
+
+// NewRegistry creates a new Registry.
+func NewRegistry() *Registry {
+	return NewRegistryWithConfig(&Config{})
+}
+
+// NewRegistryWithConfig creates a new Registry, whose buses are customized by the config.
+func NewRegistryWithConfig(config *Config) *Registry {
+	r := &Registry{config: *config}
+	r.anyBus.configure(config)
+
+	return r
+}
+
+// Bus returns the registry's bus for events of type T, creating it on first use.
+func Bus[T any](r *Registry) *EventBus[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem() // Also works for interface types
+
+	r.mu.RLock()
+	bus, ok := r.buses[typ]
+	r.mu.RUnlock()
+
+	if ok {
+		return bus.(*EventBus[T]) //nolint:forcetypeassert // Buses are stored by their type
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Another goroutine may have created the bus in the meantime
+	if bus, ok := r.buses[typ]; ok {
+		return bus.(*EventBus[T]) //nolint:forcetypeassert // Buses are stored by their type
+	}
+
+	if r.buses == nil {
+		r.buses = make(map[reflect.Type]any)
+	}
+
+	newBus := NewWithConfig[T](&r.config)
+	r.buses[typ] = newBus
+
+	return newBus
+}
+
+// Publish sends the provided event to all of the listed topics of the registry's bus for events of type T.
+// All subscriptions to those topics will be notified of the event,
+// including the subscriptions created by SubscribeAny.
+func Publish[T any](r *Registry, event T, topicKeys ...string) {
+	Bus[T](r).Publish(event, topicKeys...)
+
+	// Only box the event when it will be delivered
+	for _, topicKey := range topicKeys {
+		if r.anyBus.HasTopic(topicKey) {
+			r.anyBus.Publish(event, topicKeys...)
+			return
+		}
+	}
+}
+
+// Subscribe creates a new subscription to the listed topics of the registry's bus for events of type T.
+// All events of type T published to any of those topics will be sent to the subscription's channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func Subscribe[T any](r *Registry, topicKeys ...string) *Subscription[T] {
+	return Bus[T](r).Subscribe(topicKeys...)
+}
+
+// SubscribeWithConfig creates a new customized subscription to the listed topics of the registry's bus for events of type T.
+// All events of type T published to any of those topics will be sent to the subscription's channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func SubscribeWithConfig[T any](r *Registry, config *SubscriptionConfig[T], topicKeys ...string) *Subscription[T] {
+	return Bus[T](r).SubscribeWithConfig(config, topicKeys...)
+}
+
+// SubscribeAny creates a new subscription to the listed topics, which receives the events of every type
+// published with the Publish function, such as for auditing.
+// Events published directly to a bus returned by the Bus function are not received.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func (r *Registry) SubscribeAny(topicKeys ...string) *Subscription[any] {
+	return r.anyBus.Subscribe(topicKeys...)
+}
+
+// Close closes the buses of every type, like EventBus.Close.
+func (r *Registry) Close() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, bus := range r.buses {
+		bus.(interface{ Close() }).Close() //nolint:forcetypeassert // Every bus has a Close method
+	}
+
+	r.anyBus.Close()
+}
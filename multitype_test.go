@@ -0,0 +1,77 @@
+package eventbus_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+type UserCreated struct {
+	Name string
+}
+
+type OrderPlaced struct {
+	ID int
+}
+
+func TestRegistry(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("delivers events by type", func(ensure ensurepkg.Ensure) {
+		reg := eventbus.NewRegistry()
+		defer reg.Close()
+
+		users := eventbus.Subscribe[*UserCreated](reg, "key1")
+		orders := eventbus.Subscribe[*OrderPlaced](reg, "key1", "key2")
+
+		eventbus.Publish(reg, &UserCreated{Name: "Ada"}, "key1")
+		eventbus.Publish(reg, &OrderPlaced{ID: 1}, "key1", "key2")
+		eventbus.Publish(reg, &OrderPlaced{ID: 2}, "key2")
+
+		ensure(receiveAll(users, 1, 10*time.Millisecond)).Equals([]*UserCreated{{Name: "Ada"}})
+		ensure(receiveAll(orders, 2, 10*time.Millisecond)).Equals([]*OrderPlaced{{ID: 1}, {ID: 2}})
+	})
+
+	ensure.Run("works when created by initializing the struct", func(ensure ensurepkg.Ensure) {
+		reg := &eventbus.Registry{}
+
+		sub := eventbus.Subscribe[string](reg, "key1")
+		eventbus.Publish(reg, "hello", "key1")
+
+		ensure(<-sub.Channel()).Equals("hello")
+	})
+
+	ensure.Run("subscribes to every type with SubscribeAny", func(ensure ensurepkg.Ensure) {
+		reg := eventbus.NewRegistry()
+		audit := reg.SubscribeAny("key1", "key2")
+
+		eventbus.Publish(reg, &UserCreated{Name: "Ada"}, "key1", "key2") // Only delivered once
+		eventbus.Publish(reg, &OrderPlaced{ID: 1}, "key2")
+		eventbus.Publish(reg, "ignored", "key3")
+
+		ensure(receiveAll(audit, 2, 10*time.Millisecond)).Equals([]any{&UserCreated{Name: "Ada"}, &OrderPlaced{ID: 1}})
+	})
+
+	ensure.Run("keeps a single bus for each type", func(ensure ensurepkg.Ensure) {
+		reg := eventbus.NewRegistry()
+
+		ensure(eventbus.Bus[*UserCreated](reg) == eventbus.Bus[*UserCreated](reg)).IsTrue()
+		ensure(eventbus.Bus[error](reg) == eventbus.Bus[error](reg)).IsTrue()
+
+		sub := eventbus.Subscribe[error](reg, "key1")
+		eventbus.Publish[error](reg, errors.New("failed"), "key1")
+		ensure((<-sub.Channel()).Error()).Equals("failed")
+	})
+
+	ensure.Run("customizes the buses with the config", func(ensure ensurepkg.Ensure) {
+		reg := eventbus.NewRegistryWithConfig(&eventbus.Config{BufferSize: -1})
+		eventbus.SubscribeWithConfig(reg, &eventbus.SubscriptionConfig[string]{}, "key1")
+
+		err := eventbus.Bus[string](reg).TryPublish("1", "key1")
+		ensure(errors.Is(err, eventbus.ErrWouldBlock)).IsTrue()
+	})
+}
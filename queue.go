@@ -0,0 +1,118 @@
+package eventbus
+
+import "sync"
+
+// queue holds the events waiting to be received by a subscription, and sends them to the subscription's channel.
+// Pushing to the queue never blocks.
+//
+// If a key function is provided, the queue holds at most one event per key.
+type queue[Event any] struct {
+	ch  chan<- Event
+	key func(event Event) string
+
+	mu      sync.Mutex
+	entries []*queueEntry[Event]
+	byKey   map[string]*queueEntry[Event]
+
+	ready chan struct{}
+	done  chan struct{}
+}
+
+type queueEntry[Event any] struct {
+	key   string
+	event Event
+}
+
+func newQueue[Event any](ch chan<- Event, key func(event Event) string) *queue[Event] {
+	q := &queue[Event]{
+		ch:  ch,
+		key: key,
+
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	if key != nil {
+		q.byKey = make(map[string]*queueEntry[Event])
+	}
+
+	go q.run()
+
+	return q
+}
+
+func (q *queue[Event]) push(event Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.key == nil {
+		q.entries = append(q.entries, &queueEntry[Event]{event: event})
+		q.signal()
+		return
+	}
+
+	key := q.key(event)
+
+	// Replace the pending event in place, so it keeps the position of the first arrival
+	if entry, ok := q.byKey[key]; ok {
+		entry.event = event
+		return
+	}
+
+	entry := &queueEntry[Event]{key: key, event: event}
+	q.entries = append(q.entries, entry)
+	q.byKey[key] = entry
+	q.signal()
+}
+
+func (q *queue[Event]) close() {
+	close(q.done)
+}
+
+func (q *queue[Event]) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default: // Already signaled
+	}
+}
+
+func (q *queue[Event]) pop() (event Event, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.entries) == 0 {
+		return event, false
+	}
+
+	entry := q.entries[0]
+	q.entries[0] = nil // Allow the entry to be garbage collected
+	q.entries = q.entries[1:]
+
+	if q.byKey != nil {
+		delete(q.byKey, entry.key)
+	}
+
+	return entry.event, true
+}
+
+func (q *queue[Event]) run() {
+	defer close(q.ch)
+
+	for {
+		event, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.ready:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case q.ch <- event:
+		case <-q.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package eventbus
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottleMode configures which events are delivered by a throttled subscription.
+type ThrottleMode int
+
+const (
+	// ThrottleLeading delivers the first event of each interval, and drops the rest.
+	// This is the default.
+	ThrottleLeading ThrottleMode = iota
+
+	// ThrottleTrailing delivers the latest event of each interval once the interval elapses.
+	ThrottleTrailing
+
+	// ThrottleLeadingAndTrailing delivers the first event of each interval,
+	// and the latest event of the interval once it elapses, if any other events were received.
+	ThrottleLeadingAndTrailing
+)
+
+// rateLimiter is a stage that delays or drops events before they are pushed to a subscription's queue.
+type rateLimiter[Event any] interface {
+	push(key string, event Event)
+	stop()
+}
+
+// debouncer delivers an event once no other event with the same key has been received for the wait duration.
+type debouncer[Event any] struct {
+	wait time.Duration
+	emit func(key string, event Event)
+
+	mu      sync.Mutex
+	pending map[string]*debounceEntry[Event]
+	stopped bool
+}
+
+type debounceEntry[Event any] struct {
+	event Event
+	timer *time.Timer
+}
+
+func newDebouncer[Event any](wait time.Duration, emit func(key string, event Event)) *debouncer[Event] {
+	return &debouncer[Event]{
+		wait: wait,
+		emit: emit,
+
+		pending: make(map[string]*debounceEntry[Event]),
+	}
+}
+
+func (d *debouncer[Event]) push(key string, event Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	if entry, ok := d.pending[key]; ok {
+		entry.timer.Stop()
+	}
+
+	entry := &debounceEntry[Event]{event: event}
+	entry.timer = time.AfterFunc(d.wait, func() { d.fire(key, entry) })
+	d.pending[key] = entry
+}
+
+func (d *debouncer[Event]) fire(key string, entry *debounceEntry[Event]) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The entry may have been replaced by a newer event after the timer fired, but before we acquired the lock
+	if d.stopped || d.pending[key] != entry {
+		return
+	}
+
+	delete(d.pending, key)
+	d.emit(key, entry.event)
+}
+
+func (d *debouncer[Event]) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+
+	for key, entry := range d.pending {
+		entry.timer.Stop()
+		delete(d.pending, key)
+	}
+}
+
+// throttler delivers at most one event with the same key per interval.
+type throttler[Event any] struct {
+	interval time.Duration
+	mode     ThrottleMode
+	emit     func(key string, event Event)
+
+	mu        sync.Mutex
+	intervals map[string]*throttleInterval[Event]
+	stopped   bool
+}
+
+type throttleInterval[Event any] struct {
+	timer *time.Timer
+
+	trailing    Event
+	hasTrailing bool
+}
+
+func newThrottler[Event any](interval time.Duration, mode ThrottleMode, emit func(key string, event Event)) *throttler[Event] {
+	return &throttler[Event]{
+		interval: interval,
+		mode:     mode,
+		emit:     emit,
+
+		intervals: make(map[string]*throttleInterval[Event]),
+	}
+}
+
+func (t *throttler[Event]) push(key string, event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		return
+	}
+
+	// Within an interval, only the latest event is kept for the trailing edge
+	if interval, ok := t.intervals[key]; ok {
+		if t.mode != ThrottleLeading {
+			interval.trailing = event
+			interval.hasTrailing = true
+		}
+
+		return
+	}
+
+	interval := &throttleInterval[Event]{}
+	if t.mode == ThrottleTrailing {
+		interval.trailing = event
+		interval.hasTrailing = true
+	} else {
+		t.emit(key, event)
+	}
+
+	t.startInterval(key, interval)
+}
+
+func (t *throttler[Event]) startInterval(key string, interval *throttleInterval[Event]) {
+	interval.timer = time.AfterFunc(t.interval, func() { t.endInterval(key, interval) })
+	t.intervals[key] = interval
+}
+
+func (t *throttler[Event]) endInterval(key string, interval *throttleInterval[Event]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped || t.intervals[key] != interval {
+		return
+	}
+
+	delete(t.intervals, key)
+
+	if !interval.hasTrailing {
+		return
+	}
+
+	// Delivering the trailing event starts a new interval, so the next event isn't delivered immediately after it
+	t.emit(key, interval.trailing)
+	t.startInterval(key, &throttleInterval[Event]{})
+}
+
+func (t *throttler[Event]) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+
+	for key, interval := range t.intervals {
+		interval.timer.Stop()
+		delete(t.intervals, key)
+	}
+}
+
+// rateLimiters chains multiple rate limiters, pushing events through them in order.
+type rateLimiters[Event any] []rateLimiter[Event]
+
+// newRateLimiters creates the rate limiters for the config, which push the events they deliver to the provided function.
+// It returns nil if the config doesn't enable any rate limiting.
+func newRateLimiters[Event any](config *SubscriptionConfig[Event], push func(event Event)) rateLimiters[Event] {
+	var limiters rateLimiters[Event]
+	emit := func(_ string, event Event) { push(event) }
+
+	// Build the chain from the end, so debounced events are then throttled
+	if config.Throttle > 0 {
+		t := newThrottler(config.Throttle, config.ThrottleMode, emit)
+		limiters = append(rateLimiters[Event]{t}, limiters...)
+		emit = t.push
+	}
+
+	if config.Debounce > 0 {
+		d := newDebouncer(config.Debounce, emit)
+		limiters = append(rateLimiters[Event]{d}, limiters...)
+	}
+
+	return limiters
+}
+
+func (r rateLimiters[Event]) push(key string, event Event) {
+	r[0].push(key, event)
+}
+
+func (r rateLimiters[Event]) stop() {
+	for _, limiter := range r {
+		limiter.stop()
+	}
+}
@@ -0,0 +1,77 @@
+package eventbus
+
+import "context"
+
+// Receive waits for the subscription's next event.
+//
+// It returns an error once no more events can be received:
+// the context's error if it is done first, ErrSubscriptionClosed once the subscription is unsubscribed,
+// ErrSubscriptionOverflowed once it is disconnected because its buffer overflowed,
+// a *StalledError once it is disconnected by a watchdog,
+// or ErrBusClosed once the bus is closed, the events queued by PublishAsync have been delivered,
+// and the subscription's buffered events have been received.
+func (s *Subscription[Event]) Receive(ctx context.Context) (Event, error) {
+	select {
+	case event, ok := <-s.ch:
+		return s.received(event, ok)
+
+	case <-ctx.Done():
+		var event Event
+		return event, ctx.Err()
+
+	case <-s.bus.drainedChan():
+		// Buffered events are still received once the bus is closed.
+		// Waiting for the events queued by PublishAsync to be delivered keeps the dispatchers from blocking on
+		// subscriptions that stopped receiving.
+		select {
+		case event, ok := <-s.ch:
+			return s.received(event, ok)
+		default:
+			var event Event
+			return event, ErrBusClosed
+		}
+	}
+}
+
+// Err returns why the subscription was closed:
+// ErrSubscriptionClosed once it is unsubscribed, ErrSubscriptionOverflowed once it is disconnected
+// because its buffer overflowed, or a *StalledError once it is disconnected by a watchdog.
+// It returns nil while the subscription is open.
+func (s *Subscription[Event]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+func (s *Subscription[Event]) received(event Event, ok bool) (Event, error) {
+	if !ok {
+		return event, s.Err()
+	}
+
+	return event, nil
+}
+
+// closedChan returns a channel that is closed once the bus is closed.
+func (b *EventBus[Event]) closedChan() chan struct{} {
+	b.closedMu.Lock()
+	defer b.closedMu.Unlock()
+
+	if b.closed == nil {
+		b.closed = make(chan struct{})
+	}
+
+	return b.closed
+}
+
+// drainedChan returns a channel that is closed once the bus is closed and the events queued by PublishAsync are delivered.
+func (b *EventBus[Event]) drainedChan() chan struct{} {
+	b.closedMu.Lock()
+	defer b.closedMu.Unlock()
+
+	if b.drained == nil {
+		b.drained = make(chan struct{})
+	}
+
+	return b.drained
+}
@@ -0,0 +1,99 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestReceive(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("receives events until the subscription is unsubscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		bus.Publish("1", "key1")
+
+		event, err := sub.Receive(context.Background())
+		ensure(err).IsNotError()
+		ensure(event).Equals("1")
+		ensure(sub.Err()).IsNotError()
+
+		sub.Unsubscribe()
+		sub.Unsubscribe() // Has no effect
+
+		_, err = sub.Receive(context.Background())
+		ensure(err).IsError(eventbus.ErrSubscriptionClosed)
+		ensure(sub.Err()).IsError(eventbus.ErrSubscriptionClosed)
+	})
+
+	ensure.Run("returns the context's error once it is done", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := sub.Receive(ctx)
+		ensure(err).IsError(context.DeadlineExceeded)
+		ensure(sub.Err()).IsNotError()
+	})
+
+	ensure.Run("returns ErrBusClosed once the buffered events are received after closing the bus", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		bus.Publish("1", "key1")
+		bus.Close()
+
+		event, err := sub.Receive(context.Background())
+		ensure(err).IsNotError()
+		ensure(event).Equals("1")
+
+		_, err = sub.Receive(context.Background())
+		ensure(err).IsError(eventbus.ErrBusClosed)
+	})
+
+	ensure.Run("unblocks when the bus is closed while waiting", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		received := make(chan error)
+		go func() {
+			_, err := sub.Receive(context.Background())
+			received <- err
+		}()
+
+		bus.Close()
+		ensure(<-received).IsError(eventbus.ErrBusClosed)
+	})
+
+	ensure.Run("disconnects subscriptions that overflow", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: 1})
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{DisconnectOnOverflow: true}, "key1")
+		other := bufferSubscription(bus.Subscribe("key1"), 2)
+
+		bus.Publish("1", "key1")
+		report := bus.PublishWithReport("2", "key1") // Doesn't block, since the subscription is disconnected
+		ensure(report.Delivered).Equals(1)
+		ensure(len(report.Dropped)).Equals(1)
+
+		event, err := sub.Receive(context.Background())
+		ensure(err).IsNotError()
+		ensure(event).Equals("1")
+
+		_, err = sub.Receive(context.Background())
+		ensure(err).IsError(eventbus.ErrSubscriptionOverflowed)
+		ensure(sub.Err()).IsError(eventbus.ErrSubscriptionOverflowed)
+
+		sub.Unsubscribe() // Keeps the original reason
+		ensure(sub.Err()).IsError(eventbus.ErrSubscriptionOverflowed)
+
+		ensure(other.events()).Equals([]string{"1", "2"})
+	})
+}
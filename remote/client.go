@@ -0,0 +1,730 @@
+package remote
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+	"github.com/JosiahWitt/eventbus/internal/wire"
+)
+
+const (
+	// DefaultMinReconnectDelay before the first reconnect attempt. Used when the MinReconnectDelay is not configured.
+	DefaultMinReconnectDelay = 100 * time.Millisecond
+
+	// DefaultMaxReconnectDelay between reconnect attempts. Used when the MaxReconnectDelay is not configured.
+	DefaultMaxReconnectDelay = 10 * time.Second
+
+	// DefaultMaxPendingEvents waiting for room in each subscription's channel. Used when the MaxPendingEvents is not configured.
+	DefaultMaxPendingEvents = 10000
+)
+
+var (
+	// ErrClientClosed is returned by Client.Err once the client is closed.
+	ErrClientClosed = errors.New("remote: client closed")
+
+	// ErrDisconnected is passed to OnError when publishing while the client is reconnecting.
+	ErrDisconnected = errors.New("remote: disconnected")
+
+	// ErrSubscriptionOverflowed is matched by the error passed to OnError when a subscription is unsubscribed,
+	// because more than MaxPendingEvents events were waiting for room in its channel.
+	ErrSubscriptionOverflowed = errors.New("remote: subscription overflowed")
+)
+
+// ClientConfig can be passed to Dial or NewClient to customize the Client.
+type ClientConfig[Event any] struct {
+	// Codec encodes and decodes the events sent over the connection.
+	// It must match the server's codec.
+	// If not set, events are encoded as JSON.
+	Codec codec.Codec[Event]
+
+	// BufferSize for the subscription channels.
+	// If not set or zero, it defaults to eventbus.DefaultBufferSize.
+	// If negative, it creates the channels with no buffer.
+	BufferSize int
+
+	// MaxPendingEvents is the maximum number of events that can wait in memory for room in a subscription's channel.
+	// Events wait instead of blocking the connection, so a slow subscription doesn't delay the client's other subscriptions.
+	// Once a subscription has too many waiting events, it is unsubscribed, which closes its channel,
+	// and an error matching ErrSubscriptionOverflowed is passed to OnError, like eventbus.SubscriptionConfig.DisconnectOnOverflow.
+	// If not set or zero, it defaults to DefaultMaxPendingEvents.
+	// If negative, the number of waiting events is not limited.
+	MaxPendingEvents int
+
+	// MaxMessageSize is the maximum size of messages sent by the server, in bytes.
+	// If the server sends a larger message, the connection is closed.
+	// If not set or zero, it defaults to DefaultMaxMessageSize.
+	// If negative, the size is not limited.
+	MaxMessageSize int
+
+	// Redial opens a new connection to the server, after the connection fails.
+	// Clients created by Dial redial the same address if it is not set.
+	// Clients created by NewClient don't reconnect if it is not set.
+	Redial func() (net.Conn, error)
+
+	// DisableReconnect stops the client once the connection fails, which closes all subscriptions.
+	DisableReconnect bool
+
+	// MinReconnectDelay is the delay before the first reconnect attempt.
+	// The delay doubles after each failed attempt, up to the MaxReconnectDelay, with some jitter added.
+	// If not set or zero, it defaults to DefaultMinReconnectDelay.
+	MinReconnectDelay time.Duration
+
+	// MaxReconnectDelay is the maximum delay between reconnect attempts.
+	// If not set or zero, it defaults to DefaultMaxReconnectDelay.
+	MaxReconnectDelay time.Duration
+
+	// OnStatus is called when the connection fails, when reconnecting fails, and once the client has reconnected.
+	// If not set, the changes are not reported.
+	OnStatus func(status Status)
+
+	// OnError is called when an event can't be published or received,
+	// and when the server reports that it couldn't handle a message.
+	// If not set, errors are ignored.
+	OnError func(err error)
+}
+
+// Status describes a change to the client's connection to the server.
+type Status struct {
+	// Connected reports whether the client is connected to the server.
+	Connected bool
+
+	// Err is why the client is disconnected. It is nil once connected.
+	Err error
+
+	// Attempt is the number of reconnect attempts that failed since the connection failed.
+	Attempt int
+
+	// MissedEvents is the number of subscriptions that may have missed events while the client was disconnected,
+	// because the server no longer held all of their events. It is only set once reconnected.
+	MissedEvents int
+}
+
+// Client publishes and subscribes to the events of an EventBus exposed by a Server.
+// It offers the same Publish and Subscribe API as the EventBus.
+//
+// If the connection fails, the client reconnects with exponential backoff, and resumes its subscriptions
+// from the last events they received, so they don't notice the failure. Resuming without missing events
+// requires the server to enable ServerConfig.ResumeTimeout. Changes to the connection are reported to OnStatus.
+type Client[Event any] struct {
+	config *ClientConfig[Event]
+	redial func() (net.Conn, error)
+	id     string
+
+	writeMu sync.Mutex
+
+	mu                 sync.Mutex
+	conn               *clientConn // Nil while reconnecting
+	subs               map[uint64]*Subscription[Event]
+	lastSubscriptionID uint64
+	err                error
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+var _ eventbus.Publisher[any] = &Client[any]{}
+
+// clientConn is one of the client's connections to the server.
+type clientConn struct {
+	conn net.Conn
+	acks map[uint64]chan bool // Guarded by the client's mutex
+	done chan struct{}        // Closed once the connection fails
+}
+
+// Subscription maintains a subscription to multiple topics of the server's EventBus.
+// Events are sent to the Channel().
+type Subscription[Event any] struct {
+	id        uint64
+	client    *Client[Event]
+	ch        chan Event
+	topicKeys []string
+
+	// Only used by the goroutine reading the connection
+	lastSeq      uint64
+	acknowledged bool
+
+	// Received events wait in pending until the subscription's goroutine sends them to the channel,
+	// so a subscription that isn't keeping up doesn't stop the connection from being read.
+	pendingMu sync.Mutex
+	pending   []Event
+	wake      chan struct{} // Signaled once events are pending
+
+	done      chan struct{} // Closed to stop the subscription's goroutine, which then closes the channel
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to the server listening on the address of the network, such as "tcp" or "unix".
+func Dial[Event any](network, address string, config *ClientConfig[Event]) (*Client[Event], error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("remote: unable to dial: %w", err)
+	}
+
+	redial := config.Redial
+	if redial == nil {
+		redial = func() (net.Conn, error) { return net.Dial(network, address) }
+	}
+
+	return newClient(conn, config, redial), nil
+}
+
+// NewClient creates a new Client using the connection to the server.
+// It is useful when the connection needs to be customized, such as to use TLS.
+func NewClient[Event any](conn net.Conn, config *ClientConfig[Event]) *Client[Event] {
+	return newClient(conn, config, config.Redial)
+}
+
+func newClient[Event any](conn net.Conn, config *ClientConfig[Event], redial func() (net.Conn, error)) *Client[Event] {
+	c := &Client[Event]{
+		config: config,
+		redial: redial,
+		id:     newClientID(),
+
+		subs:    make(map[uint64]*Subscription[Event]),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if config.DisableReconnect {
+		c.redial = nil
+	}
+
+	cc := c.connect(conn)
+	go c.run(cc)
+
+	return c
+}
+
+// Publish sends the provided event to all of the listed topics of the server's EventBus.
+// All subscriptions to those topics will be notified of the event.
+//
+// Events published by the same client are published in order.
+// If the event can't be sent, such as while reconnecting, the error is passed to OnError.
+func (c *Client[Event]) Publish(event Event, topicKeys ...string) {
+	data, err := c.codec().Encode(event)
+	if err != nil {
+		c.onError(fmt.Errorf("remote: unable to encode event: %w", err))
+		return
+	}
+
+	c.mu.Lock()
+	cc := c.conn
+	c.mu.Unlock()
+
+	if cc == nil {
+		c.onError(fmt.Errorf("remote: unable to publish event: %w", ErrDisconnected))
+		return
+	}
+
+	if err := c.write(cc, &message{Kind: messagePublish, TopicKeys: topicKeys, Data: data}); err != nil {
+		c.onError(fmt.Errorf("remote: unable to publish event: %w", err))
+	}
+}
+
+// Subscribe creates a new subscription to the listed topics of the server's EventBus.
+// All events published to any of those topics will be sent to the subscription's channel.
+// Events wait in memory while the channel is full, up to the MaxPendingEvents,
+// so a slow subscription doesn't delay the client's other subscriptions.
+// It returns once the server has subscribed, or the connection fails.
+// Subscriptions created while reconnecting are subscribed once the client reconnects.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+// If the client is closed, the returned subscription's channel is closed.
+func (c *Client[Event]) Subscribe(topicKeys ...string) *Subscription[Event] {
+	sub := &Subscription[Event]{
+		client:    c,
+		ch:        make(chan Event, c.bufferSizeOrDefault()),
+		topicKeys: append([]string(nil), topicKeys...),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	go sub.forwardEvents()
+
+	c.mu.Lock()
+	if c.isStopped() {
+		c.mu.Unlock()
+		sub.close()
+
+		return sub
+	}
+
+	c.lastSubscriptionID++
+	sub.id = c.lastSubscriptionID
+	c.subs[sub.id] = sub
+
+	cc := c.conn
+	var ack chan bool
+	if cc != nil {
+		ack = make(chan bool, 1)
+		cc.acks[sub.id] = ack
+	}
+	c.mu.Unlock()
+
+	if cc == nil {
+		return sub // Subscribed once reconnected
+	}
+
+	if err := c.write(cc, &message{Kind: messageSubscribe, ID: sub.id, TopicKeys: sub.topicKeys}); err != nil {
+		cc.conn.Close() // Reconnecting subscribes again
+		return sub
+	}
+
+	select {
+	case <-ack:
+	case <-cc.done:
+	}
+
+	return sub
+}
+
+// Close disconnects from the server, and stops reconnecting, which closes all subscriptions.
+func (c *Client[Event]) Close() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClientClosed
+	}
+
+	cc := c.conn
+	c.mu.Unlock()
+
+	var err error
+	if cc != nil {
+		err = cc.conn.Close()
+	}
+
+	<-c.done
+
+	return err
+}
+
+// Done returns a channel that is closed once the client stops, which is when it is closed,
+// or when the connection fails and the client doesn't reconnect.
+func (c *Client[Event]) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client stopped, or nil if it hasn't stopped.
+// It returns ErrClientClosed once the client is closed.
+func (c *Client[Event]) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.isStopped() {
+		return nil
+	}
+
+	return c.err
+}
+
+// Unsubscribe closes the subscription to the topics.
+// It also closes the subscription's channel.
+func (s *Subscription[Event]) Unsubscribe() {
+	c := s.client
+
+	c.mu.Lock()
+	_, ok := c.subs[s.id]
+	delete(c.subs, s.id)
+
+	cc := c.conn
+	c.mu.Unlock()
+
+	if ok && cc != nil {
+		// The server may have already disconnected, which unsubscribes or expires all of the client's subscriptions
+		c.write(cc, &message{Kind: messageUnsubscribe, ID: s.id}) //nolint:errcheck
+	}
+
+	s.close()
+}
+
+// Channel exposes a read only view of the subscription's channel.
+// All events published to the subscribed topics will be published to this channel.
+//
+// If the same event is sent to multiple of the listed topics, the event will only be delivered once.
+func (s *Subscription[Event]) Channel() <-chan Event {
+	return s.ch
+}
+
+// run reads the messages from each connection, reconnecting when it fails, until the client stops.
+func (c *Client[Event]) run(cc *clientConn) {
+	for {
+		err := c.readMessages(cc)
+
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+
+		close(cc.done)
+		cc.conn.Close()
+
+		if c.redial == nil || c.isClosing() {
+			c.stop(err)
+			return
+		}
+
+		c.onStatus(Status{Err: err})
+
+		conn := c.reconnect()
+		if conn == nil {
+			c.stop(ErrClientClosed)
+			return
+		}
+
+		cc = c.connect(conn)
+		if c.isClosing() {
+			cc.conn.Close() // Close may have been called before the connection was set
+		}
+
+		go c.reportResumed(cc)
+	}
+}
+
+// connect starts using the connection, introducing the client, and subscribing its subscriptions.
+func (c *Client[Event]) connect(conn net.Conn) *clientConn {
+	cc := &clientConn{
+		conn: conn,
+		acks: make(map[uint64]chan bool),
+		done: make(chan struct{}),
+	}
+
+	// The hello is written before Publish and Subscribe can use the connection,
+	// so the server knows which client their messages belong to.
+	// Failures are noticed when reading the connection.
+	helloErr := c.write(cc, &message{Kind: messageHello, Data: []byte(c.id)})
+
+	c.mu.Lock()
+	if helloErr == nil {
+		c.conn = cc
+	}
+
+	subs := make([]*Subscription[Event], 0, len(c.subs))
+	for _, sub := range c.subs {
+		subs = append(subs, sub)
+		cc.acks[sub.id] = make(chan bool, 1)
+	}
+	c.mu.Unlock()
+
+	if helloErr != nil {
+		conn.Close()
+		return cc
+	}
+
+	for _, sub := range subs {
+		msg := &message{Kind: messageSubscribe, ID: sub.id, TopicKeys: sub.topicKeys}
+		if sub.acknowledged {
+			msg.Sequence = sub.lastSeq
+			msg.Data = resumeFlag
+		}
+
+		if err := c.write(cc, msg); err != nil {
+			conn.Close()
+			return cc
+		}
+	}
+
+	return cc
+}
+
+// reportResumed reports that the client reconnected once the server acknowledges all of the resumed subscriptions.
+func (c *Client[Event]) reportResumed(cc *clientConn) {
+	c.mu.Lock()
+	acks := make([]chan bool, 0, len(cc.acks))
+	for _, ack := range cc.acks {
+		acks = append(acks, ack)
+	}
+	c.mu.Unlock()
+
+	status := Status{Connected: true}
+	for _, ack := range acks {
+		select {
+		case complete := <-ack:
+			if !complete {
+				status.MissedEvents++
+			}
+		case <-cc.done:
+			return // The connection failed again, which is reported instead
+		}
+	}
+
+	c.onStatus(status)
+}
+
+// reconnect dials the server until it succeeds, or the client is closed, in which case it returns nil.
+func (c *Client[Event]) reconnect() net.Conn {
+	delay := c.minReconnectDelayOrDefault()
+	maxDelay := c.maxReconnectDelayOrDefault()
+
+	for attempt := 1; ; attempt++ {
+		// Up to 20% jitter keeps clients from reconnecting in lockstep after a server restarts
+		timer := time.NewTimer(delay + time.Duration(mathrand.Int63n(int64(delay)/5+1))) //nolint:gosec
+
+		select {
+		case <-timer.C:
+		case <-c.closing:
+			timer.Stop()
+			return nil
+		}
+
+		conn, err := c.redial()
+		if err == nil {
+			return conn
+		}
+
+		c.onStatus(Status{Err: err, Attempt: attempt})
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// readMessages handles the server's messages, until the connection fails.
+func (c *Client[Event]) readMessages(cc *clientConn) error {
+	reader := bufio.NewReader(cc.conn)
+	maxMessageSize := maxMessageSizeOrDefault(c.config.MaxMessageSize)
+
+	for {
+		msg, err := wire.Read(reader, maxMessageSize)
+		if err != nil {
+			return fmt.Errorf("remote: connection failed: %w", err)
+		}
+
+		c.handleMessage(cc, msg)
+	}
+}
+
+func (c *Client[Event]) handleMessage(cc *clientConn, msg *message) {
+	switch msg.Kind {
+	case messageSubscribed:
+		c.mu.Lock()
+		sub, ok := c.subs[msg.ID]
+		ack := cc.acks[msg.ID]
+		delete(cc.acks, msg.ID)
+		c.mu.Unlock()
+
+		if ok {
+			sub.acknowledged = true
+			sub.lastSeq = msg.Sequence
+		}
+
+		if ack != nil {
+			ack <- string(msg.Data) == string(resumeFlag)
+		}
+
+	case messageEvent:
+		sub, ok := c.subscription(msg.ID)
+		if !ok {
+			return // The subscription was unsubscribed after the server sent the event
+		}
+
+		// The event was already received before resuming
+		if msg.Sequence <= sub.lastSeq {
+			return
+		}
+
+		sub.lastSeq = msg.Sequence
+
+		event, err := c.codec().Decode(msg.Data)
+		if err != nil {
+			c.onError(fmt.Errorf("remote: unable to decode event: %w", err))
+			return
+		}
+
+		sub.deliver(event)
+
+	case messageError:
+		c.onError(fmt.Errorf("remote: server error: %s", msg.Data))
+
+	default:
+		c.onError(fmt.Errorf("remote: unsupported message kind %d", msg.Kind))
+	}
+}
+
+func (c *Client[Event]) subscription(id uint64) (*Subscription[Event], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sub, ok := c.subs[id]
+	return sub, ok
+}
+
+// stop stops the client because of the error, closing all of its subscriptions.
+func (c *Client[Event]) stop(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+
+	subs := c.subs
+	c.subs = make(map[uint64]*Subscription[Event])
+	close(c.done)
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.close()
+	}
+}
+
+// isStopped reports whether the client stopped. The caller must hold mu.
+func (c *Client[Event]) isStopped() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client[Event]) isClosing() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client[Event]) write(cc *clientConn, msg *message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return wire.Write(cc.conn, msg)
+}
+
+// deliver queues the event to be sent to the subscription's channel, without waiting for room in the channel.
+// If too many events are already waiting, the subscription is unsubscribed instead.
+func (s *Subscription[Event]) deliver(event Event) {
+	maxPendingEvents := s.client.maxPendingEventsOrDefault()
+
+	s.pendingMu.Lock()
+	if maxPendingEvents > 0 && len(s.pending) >= maxPendingEvents {
+		s.pendingMu.Unlock()
+
+		s.client.onError(fmt.Errorf("%w: subscription %d to %v", ErrSubscriptionOverflowed, s.id, s.topicKeys))
+		s.Unsubscribe()
+
+		return
+	}
+
+	s.pending = append(s.pending, event)
+	s.pendingMu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default: // Already signaled
+	}
+}
+
+// forwardEvents sends the pending events to the subscription's channel, until the subscription is closed.
+func (s *Subscription[Event]) forwardEvents() {
+	defer close(s.stopped)
+	defer close(s.ch)
+
+	for {
+		s.pendingMu.Lock()
+		if len(s.pending) == 0 {
+			s.pendingMu.Unlock()
+
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		// Events are taken one at a time, so the events that are waiting can be counted
+		event := s.pending[0]
+		var zero Event
+		s.pending[0] = zero // Allow the event to be garbage collected
+		s.pending = s.pending[1:]
+		s.pendingMu.Unlock()
+
+		select {
+		case s.ch <- event:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// close stops the subscription, discarding its pending events. The channel is closed once it returns.
+func (s *Subscription[Event]) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	<-s.stopped
+}
+
+func newClientID() string {
+	id := make([]byte, 16)
+	rand.Read(id) //nolint:errcheck // Never returns an error
+	return hex.EncodeToString(id)
+}
+
+func (c *Client[Event]) codec() codec.Codec[Event] {
+	if c.config.Codec != nil {
+		return c.config.Codec
+	}
+
+	return codec.JSON[Event]{}
+}
+
+func (c *Client[Event]) onStatus(status Status) {
+	if c.config.OnStatus != nil {
+		c.config.OnStatus(status)
+	}
+}
+
+func (c *Client[Event]) onError(err error) {
+	if c.config.OnError != nil {
+		c.config.OnError(err)
+	}
+}
+
+func (c *Client[Event]) bufferSizeOrDefault() int {
+	if c.config.BufferSize == 0 {
+		return eventbus.DefaultBufferSize
+	} else if c.config.BufferSize < 0 {
+		return 0
+	}
+
+	return c.config.BufferSize
+}
+
+func (c *Client[Event]) maxPendingEventsOrDefault() int {
+	if c.config.MaxPendingEvents == 0 {
+		return DefaultMaxPendingEvents
+	}
+
+	return c.config.MaxPendingEvents
+}
+
+func (c *Client[Event]) minReconnectDelayOrDefault() time.Duration {
+	if c.config.MinReconnectDelay <= 0 {
+		return DefaultMinReconnectDelay
+	}
+
+	return c.config.MinReconnectDelay
+}
+
+func (c *Client[Event]) maxReconnectDelayOrDefault() time.Duration {
+	if c.config.MaxReconnectDelay <= 0 {
+		return DefaultMaxReconnectDelay
+	}
+
+	return c.config.MaxReconnectDelay
+}
@@ -0,0 +1,321 @@
+package remote_test
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/internal/wire"
+	"github.com/JosiahWitt/eventbus/remote"
+)
+
+func TestClientReconnect(t *testing.T) {
+	ensure := ensure.New(t)
+
+	fastReconnect := func(statuses chan remote.Status) *remote.ClientConfig[*Message] {
+		return &remote.ClientConfig[*Message]{
+			MinReconnectDelay: time.Millisecond,
+			MaxReconnectDelay: 10 * time.Millisecond,
+			OnStatus:          func(status remote.Status) { statuses <- status },
+		}
+	}
+
+	ensure.Run("resumes subscriptions without missing events", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		listener := newDroppingListener(ensure)
+		serveListener(ensure, bus, listener, &remote.ServerConfig[*Message]{ResumeTimeout: time.Minute})
+
+		statuses := make(chan remote.Status, 10)
+		client, err := remote.Dial("tcp", listener.Addr().String(), fastReconnect(statuses))
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		bus.Publish(&Message{ID: "1"}, "key1")
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1"})
+
+		listener.dropConns()
+		disconnected := <-statuses
+		ensure(disconnected.Connected).IsFalse()
+		ensure(disconnected.Err != nil).IsTrue()
+
+		// Published while the client is disconnected, so they are held by the server
+		bus.Publish(&Message{ID: "2"}, "key1")
+		bus.Publish(&Message{ID: "3"}, "key1")
+
+		ensure(<-statuses).Equals(remote.Status{Connected: true})
+		ensure(<-sub.Channel()).Equals(&Message{ID: "2"})
+		ensure(<-sub.Channel()).Equals(&Message{ID: "3"})
+
+		bus.Publish(&Message{ID: "4"}, "key1")
+		ensure(<-sub.Channel()).Equals(&Message{ID: "4"})
+		ensure(bus.NumTopics()).Equals(1)
+	})
+
+	ensure.Run("reports subscriptions that missed events", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		listener := newDroppingListener(ensure)
+		serveListener(ensure, bus, listener, &remote.ServerConfig[*Message]{
+			ResumeTimeout:    time.Minute,
+			ResumeBufferSize: 1,
+		})
+
+		statuses := make(chan remote.Status, 10)
+		client, err := remote.Dial("tcp", listener.Addr().String(), fastReconnect(statuses))
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		client.Subscribe("key2") // Doesn't miss any events
+
+		listener.pause()
+		listener.dropConns()
+		ensure((<-statuses).Connected).IsFalse()
+
+		bus.Publish(&Message{ID: "1"}, "key1")
+		bus.Publish(&Message{ID: "2"}, "key1")
+
+		listener.resume()
+
+		status := <-statuses
+		for !status.Connected {
+			status = <-statuses // Reconnect attempts may fail before the listener resumes
+		}
+
+		ensure(status).Equals(remote.Status{Connected: true, MissedEvents: 1})
+		ensure(<-sub.Channel()).Equals(&Message{ID: "2"})
+	})
+
+	ensure.Run("subscribes again when the server restarts", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		ensure(err).IsNotError()
+
+		address := listener.Addr().String()
+		server := remote.NewServer(bus.Broker(), &remote.ServerConfig[*Message]{})
+		go server.Serve(listener) //nolint:errcheck
+
+		statuses := make(chan remote.Status, 100)
+		client, err := remote.Dial("tcp", address, fastReconnect(statuses))
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		ensure(server.Close()).IsNotError()
+		ensure(bus.NumTopics()).Equals(0)
+
+		listener, err = net.Listen("tcp", address)
+		ensure(err).IsNotError()
+
+		server = remote.NewServer(bus.Broker(), &remote.ServerConfig[*Message]{})
+		go server.Serve(listener) //nolint:errcheck
+		defer server.Close()
+
+		status := <-statuses
+		for !status.Connected {
+			status = <-statuses
+		}
+
+		ensure(status).Equals(remote.Status{Connected: true, MissedEvents: 1})
+
+		bus.Publish(&Message{ID: "1"}, "key1")
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1"})
+	})
+
+	ensure.Run("subscribes while reconnecting once reconnected", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		listener := newDroppingListener(ensure)
+		serveListener(ensure, bus, listener, &remote.ServerConfig[*Message]{})
+
+		statuses := make(chan remote.Status, 100)
+		client, err := remote.Dial("tcp", listener.Addr().String(), fastReconnect(statuses))
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		listener.pause()
+		listener.dropConns()
+		ensure((<-statuses).Connected).IsFalse()
+
+		sub := client.Subscribe("key1")
+		listener.resume()
+
+		status := <-statuses
+		for !status.Connected {
+			status = <-statuses
+		}
+
+		ensure(status).Equals(remote.Status{Connected: true})
+
+		bus.Publish(&Message{ID: "1"}, "key1")
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1"})
+	})
+
+	ensure.Run("says hello before publishing or subscribing when reconnecting", func(ensure ensurepkg.Ensure) {
+		const reconnects = 200
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		serverConns := make(chan net.Conn)
+		newPipe := func() (net.Conn, error) {
+			clientConn, serverConn := net.Pipe()
+			go func() {
+				select {
+				case serverConns <- serverConn:
+				case <-stop:
+					serverConn.Close()
+				}
+			}()
+
+			return clientConn, nil
+		}
+
+		// Reads the first message of each connection, and then closes it so the client reconnects
+		firstKinds := make(chan byte)
+		go func() {
+			for {
+				select {
+				case serverConn := <-serverConns:
+					msg, err := wire.Read(bufio.NewReader(serverConn), remote.DefaultMaxMessageSize)
+					serverConn.Close()
+
+					if err != nil {
+						continue
+					}
+
+					select {
+					case firstKinds <- msg.Kind:
+					case <-stop:
+						return
+					}
+
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		conn, _ := newPipe()
+		client := remote.NewClient(conn, &remote.ClientConfig[*Message]{
+			MinReconnectDelay: time.Microsecond,
+			MaxReconnectDelay: time.Microsecond,
+			Redial:            newPipe,
+		})
+		defer client.Close()
+
+		// Publishes as soon as each connection can be used, racing the hello
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					client.Publish(&Message{ID: "1"}, "key1")
+				}
+			}
+		}()
+
+		for i := 0; i < reconnects; i++ {
+			ensure(receiveWithin(ensure, firstKinds)).Equals(byte(1)) // The hello
+		}
+	})
+
+	ensure.Run("stops reconnecting once closed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		listener := newDroppingListener(ensure)
+		serveListener(ensure, bus, listener, &remote.ServerConfig[*Message]{})
+
+		statuses := make(chan remote.Status, 100)
+		client, err := remote.Dial("tcp", listener.Addr().String(), fastReconnect(statuses))
+		ensure(err).IsNotError()
+
+		sub := client.Subscribe("key1")
+
+		listener.pause()
+		listener.dropConns()
+		ensure((<-statuses).Connected).IsFalse()
+
+		ensure(client.Close()).IsNotError()
+		ensure(client.Err()).IsError(remote.ErrClientClosed)
+
+		_, ok := <-sub.Channel()
+		ensure(ok).IsFalse()
+	})
+}
+
+// droppingListener can drop its connections to simulate network failures, and pause accepting new connections.
+type droppingListener struct {
+	net.Listener
+
+	mu     sync.Mutex
+	conns  []net.Conn
+	paused bool
+}
+
+func newDroppingListener(ensure ensurepkg.Ensure) *droppingListener {
+	ensure.T().Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ensure(err).IsNotError()
+
+	return &droppingListener{Listener: listener}
+}
+
+func (l *droppingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		l.mu.Lock()
+		if l.paused {
+			l.mu.Unlock()
+			conn.Close()
+
+			continue
+		}
+
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+
+		return conn, nil
+	}
+}
+
+func (l *droppingListener) dropConns() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+
+	l.conns = nil
+}
+
+func (l *droppingListener) pause() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.paused = true
+}
+
+func (l *droppingListener) resume() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.paused = false
+}
+
+func serveListener[Event any](ensure ensurepkg.Ensure, bus *eventbus.EventBus[Event], listener net.Listener, config *remote.ServerConfig[Event]) {
+	server := remote.NewServer(bus.Broker(), config)
+	go server.Serve(listener) //nolint:errcheck // Closed by the cleanup
+	ensure.T().Cleanup(func() { server.Close() })
+}
@@ -0,0 +1,24 @@
+package remote
+
+import "github.com/JosiahWitt/eventbus/internal/wire"
+
+// The kinds of messages sent over the connection.
+// The subscription ID is sent as the message's ID.
+const (
+	// Sent by clients:
+	messageHello       byte = iota + 1 // Identifies the client with the ID in the data, so its subscriptions can be resumed
+	messageSubscribe                   // Subscribes the subscription ID to the topics, resuming after the sequence if the data is resumeFlag
+	messageUnsubscribe                 // Unsubscribes the subscription ID
+	messagePublish                     // Publishes the event to the topics
+
+	// Sent by servers:
+	messageSubscribed // Acknowledges that the subscription ID is subscribed, and delivers the events after the sequence
+	messageEvent      // Delivers the event with the sequence to the subscription ID
+	messageError      // Reports that a message from the client could not be handled
+)
+
+// resumeFlag is the data of subscribe messages resuming a subscription,
+// and of subscribed messages acknowledging that the subscription was resumed without missing any events.
+var resumeFlag = []byte{1}
+
+type message = wire.Message
@@ -0,0 +1,13 @@
+// Package remote shares an EventBus with other processes, such as sidecars on the same host.
+//
+// A Server exposes a local EventBus on a listener, such as a TCP or Unix domain socket,
+// and a Client offers the same Publish and Subscribe API against it.
+// Events are encoded with a codec.Codec, which must match on both sides.
+//
+// Messages are sent over the connection as length prefixed frames.
+// Each client subscription is mapped onto a subscription of the server's EventBus,
+// so events are delivered in order and only once, like when using the EventBus directly.
+//
+// When the connection fails, clients reconnect and resume their subscriptions from the last event they received.
+// If the server enables ServerConfig.ResumeTimeout, it holds the events published in the meantime.
+package remote
@@ -0,0 +1,405 @@
+package remote_test
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+	"github.com/JosiahWitt/eventbus/remote"
+)
+
+type Message struct {
+	ID   string
+	Body string
+}
+
+func TestRemote(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("shares events over TCP", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[*Message]{})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[*Message]{})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		remoteSub := client.Subscribe("key1", "key2")
+		defer remoteSub.Unsubscribe()
+
+		localSub := bus.Subscribe("key2")
+		defer localSub.Unsubscribe()
+
+		bus.Publish(&Message{ID: "1"}, "key1", "key2") // Only delivered once to the remote subscription
+		client.Publish(&Message{ID: "2"}, "key2")
+		client.Publish(&Message{ID: "3"}, "key3")
+
+		ensure(<-remoteSub.Channel()).Equals(&Message{ID: "1"})
+		ensure(<-remoteSub.Channel()).Equals(&Message{ID: "2"})
+		ensure(<-localSub.Channel()).Equals(&Message{ID: "1"})
+		ensure(<-localSub.Channel()).Equals(&Message{ID: "2"})
+	})
+
+	ensure.Run("shares events between clients over a Unix domain socket with gob", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		address := serve(ensure, bus, "unix", filepath.Join(ensure.T().TempDir(), "bus.sock"), &remote.ServerConfig[*Message]{
+			Codec: codec.Gob[*Message]{},
+		})
+
+		subscriber, err := remote.Dial("unix", address, &remote.ClientConfig[*Message]{Codec: codec.Gob[*Message]{}})
+		ensure(err).IsNotError()
+		defer subscriber.Close()
+
+		publisher, err := remote.Dial("unix", address, &remote.ClientConfig[*Message]{Codec: codec.Gob[*Message]{}})
+		ensure(err).IsNotError()
+		defer publisher.Close()
+
+		sub := subscriber.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		for _, id := range []string{"1", "2", "3"} {
+			publisher.Publish(&Message{ID: id, Body: "hello"}, "key1")
+		}
+
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1", Body: "hello"})
+		ensure(<-sub.Channel()).Equals(&Message{ID: "2", Body: "hello"})
+		ensure(<-sub.Channel()).Equals(&Message{ID: "3", Body: "hello"})
+	})
+
+	ensure.Run("unsubscribing closes the channel and the server's subscription", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[*Message]{})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[*Message]{})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		ensure(bus.NumTopics()).Equals(1)
+
+		sub.Unsubscribe()
+		_, ok := <-sub.Channel()
+		ensure(ok).IsFalse()
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("closing the client closes its subscriptions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[*Message]{})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[*Message]{})
+		ensure(err).IsNotError()
+
+		sub := client.Subscribe("key1")
+		ensure(client.Err()).IsNotError()
+		ensure(client.Close()).IsNotError()
+
+		_, ok := <-sub.Channel()
+		ensure(ok).IsFalse()
+		ensure(client.Err()).IsError(remote.ErrClientClosed)
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.NumTopics()).Equals(0)
+
+		// Subscribing after closing returns a closed subscription
+		_, ok = <-client.Subscribe("key1").Channel()
+		ensure(ok).IsFalse()
+	})
+
+	ensure.Run("closing the server stops its clients that don't reconnect", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := remote.NewServer(bus.Broker(), &remote.ServerConfig[*Message]{})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		ensure(err).IsNotError()
+
+		served := make(chan error, 1)
+		go func() { served <- server.Serve(listener) }()
+
+		client, err := remote.Dial("tcp", listener.Addr().String(), &remote.ClientConfig[*Message]{DisableReconnect: true})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		ensure(server.Close()).IsNotError()
+		ensure(<-served).IsError(remote.ErrServerClosed)
+
+		_, ok := <-sub.Channel()
+		ensure(ok).IsFalse()
+		<-client.Done()
+		ensure(client.Err() != nil).IsTrue()
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("reports events the server can't decode", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+
+		serverErrs := make(chan error, 1)
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[*Message]{
+			Codec:   codec.Gob[*Message]{},
+			OnError: func(err error) { serverErrs <- err },
+		})
+
+		clientErrs := make(chan error, 1)
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[*Message]{
+			OnError: func(err error) { clientErrs <- err },
+		})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		client.Publish(&Message{ID: "1"}, "key1")
+
+		ensure(errors.Unwrap(<-serverErrs) != nil).IsTrue()
+		ensure((<-clientErrs).Error()).MatchesRegexp("^remote: server error: remote: unable to decode event: ")
+		ensure(client.Err()).IsNotError() // The connection is still usable
+	})
+
+	ensure.Run("disconnects clients sending messages that are too large", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[*Message]{MaxMessageSize: 32})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[*Message]{DisableReconnect: true})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		client.Publish(&Message{ID: "1", Body: "this message is longer than the limit"}, "key1")
+
+		_, ok := <-sub.Channel()
+		ensure(ok).IsFalse()
+		ensure(client.Err() != nil).IsTrue()
+	})
+
+	ensure.Run("delivers many events from concurrent publishers", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[int]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[int]{})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[int]{})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+
+		const numPublishers = 4
+		const numEvents = 250
+
+		var wg sync.WaitGroup
+		for p := 0; p < numPublishers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+
+				for i := 0; i < numEvents; i++ {
+					client.Publish(p*numEvents+i, "key1")
+				}
+			}(p)
+		}
+
+		received := map[int]bool{}
+		for len(received) < numPublishers*numEvents {
+			received[<-sub.Channel()] = true
+		}
+
+		wg.Wait()
+	})
+
+	ensure.Run("disconnects clients that stop reading without blocking publishing", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[*Message](&eventbus.Config{BufferSize: 1})
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[*Message]{
+			WriteTimeout: 200 * time.Millisecond,
+		})
+
+		conn, err := net.Dial("tcp", address)
+		ensure(err).IsNotError()
+
+		stalled := &stallingConn{Conn: conn, closed: make(chan struct{})}
+		client := remote.NewClient(stalled, &remote.ClientConfig[*Message]{})
+		defer client.Close()
+
+		client.Subscribe("t")
+		stalled.stall()
+
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+
+			body := strings.Repeat("x", 64<<10)
+			for i := 0; i < 500; i++ {
+				bus.Publish(&Message{ID: "1", Body: body}, "t")
+			}
+		}()
+
+		select {
+		case <-published:
+		case <-time.After(5 * time.Second):
+			ensure.Failf("Timed out waiting for publishing to the stalled client's topic")
+		}
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("slow subscriptions don't delay the other subscriptions", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[int]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[int]{})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[int]{BufferSize: 1})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		slow := client.Subscribe("key1")
+		defer slow.Unsubscribe()
+
+		fast := client.Subscribe("key1")
+		defer fast.Unsubscribe()
+
+		const numEvents = 100
+		for i := 0; i < numEvents; i++ {
+			bus.Publish(i, "key1")
+		}
+
+		for i := 0; i < numEvents; i++ {
+			ensure(receiveWithin(ensure, fast.Channel())).Equals(i)
+		}
+
+		// The slow subscription still receives all of its events once it catches up
+		for i := 0; i < numEvents; i++ {
+			ensure(receiveWithin(ensure, slow.Channel())).Equals(i)
+		}
+	})
+
+	ensure.Run("unsubscribes subscriptions with too many pending events", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[int]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[int]{})
+
+		errs := make(chan error, 10)
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[int]{
+			BufferSize:       1,
+			MaxPendingEvents: 2,
+			OnError:          func(err error) { errs <- err },
+		})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		for i := 0; i < 10; i++ {
+			bus.Publish(i, "key1")
+		}
+
+		select {
+		case err := <-errs:
+			ensure(err).IsError(remote.ErrSubscriptionOverflowed)
+		case <-time.After(5 * time.Second):
+			ensure.Failf("Timed out waiting for the subscription to overflow")
+		}
+
+		// The events received before overflowing can still be received, followed by the closed channel
+		received := []int{}
+		for event := range sub.Channel() {
+			received = append(received, event)
+		}
+
+		ensure(len(received) <= 4).IsTrue()
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("subscribing while receiving events doesn't deadlock", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[int]()
+		address := serve(ensure, bus, "tcp", "127.0.0.1:0", &remote.ServerConfig[int]{})
+
+		client, err := remote.Dial("tcp", address, &remote.ClientConfig[int]{BufferSize: -1})
+		ensure(err).IsNotError()
+		defer client.Close()
+
+		sub := client.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		bus.Publish(1, "key1")
+		bus.Publish(2, "key1") // Waits in the client while the first event is being handled
+
+		ensure(receiveWithin(ensure, sub.Channel())).Equals(1)
+
+		other := client.Subscribe("key2")
+		defer other.Unsubscribe()
+
+		bus.Publish(3, "key2")
+		ensure(receiveWithin(ensure, other.Channel())).Equals(3)
+		ensure(receiveWithin(ensure, sub.Channel())).Equals(2)
+	})
+}
+
+// serve starts serving the bus on a new listener, which is closed once the test finishes.
+// It returns the listener's address.
+func serve[Event any](ensure ensurepkg.Ensure, bus *eventbus.EventBus[Event], network, address string, config *remote.ServerConfig[Event]) string {
+	ensure.T().Helper()
+
+	listener, err := net.Listen(network, address)
+	ensure(err).IsNotError()
+
+	server := remote.NewServer(bus.Broker(), config)
+	go server.Serve(listener) //nolint:errcheck // Closed by the cleanup
+	ensure.T().Cleanup(func() { server.Close() })
+
+	return listener.Addr().String()
+}
+
+// stallingConn stops reading from the connection once it is stalled, like a client that hangs.
+type stallingConn struct {
+	net.Conn
+
+	isStalled int32
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *stallingConn) stall() {
+	atomic.StoreInt32(&c.isStalled, 1)
+}
+
+func (c *stallingConn) Read(b []byte) (int, error) {
+	if atomic.LoadInt32(&c.isStalled) == 1 {
+		<-c.closed
+		return 0, net.ErrClosed
+	}
+
+	return c.Conn.Read(b)
+}
+
+func (c *stallingConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+func receiveWithin[Event any](ensure ensurepkg.Ensure, ch <-chan Event) Event {
+	ensure.T().Helper()
+
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(5 * time.Second):
+		ensure.Failf("Timed out waiting for an event")
+
+		var event Event
+		return event
+	}
+}
+
+func waitFor(condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
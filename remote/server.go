@@ -0,0 +1,554 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+	"github.com/JosiahWitt/eventbus/internal/wire"
+)
+
+const (
+	// DefaultMaxMessageSize of messages sent over the connection, in bytes. Used when the MaxMessageSize is not configured.
+	DefaultMaxMessageSize = 1 << 20
+
+	// DefaultResumeBufferSize for each resumable subscription. Used when the ResumeBufferSize is not configured.
+	DefaultResumeBufferSize = 1000
+
+	// DefaultWriteTimeout for sending a message to a client. Used when the WriteTimeout is not configured.
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// ErrServerClosed is returned by Serve once the server is closed.
+var ErrServerClosed = errors.New("remote: server closed")
+
+// ServerConfig can be passed to NewServer to customize the Server.
+type ServerConfig[Event any] struct {
+	// Codec encodes and decodes the events sent over the connections.
+	// If not set, events are encoded as JSON.
+	Codec codec.Codec[Event]
+
+	// MaxMessageSize is the maximum size of messages sent by clients, in bytes.
+	// Clients sending larger messages are disconnected.
+	// If not set or zero, it defaults to DefaultMaxMessageSize.
+	// If negative, the size is not limited.
+	MaxMessageSize int
+
+	// ResumeTimeout enables resuming subscriptions, and is how long the subscriptions of a disconnected client
+	// are kept for it to reconnect. In the meantime, their events are held by the server, so the client can resume
+	// the subscriptions without missing any events.
+	// If not set or zero, subscriptions are unsubscribed once their client disconnects.
+	ResumeTimeout time.Duration
+
+	// ResumeBufferSize is the number of recent events held by each subscription for resuming.
+	// Once it is exceeded, the oldest events are discarded, so a client resuming after them misses them.
+	// If not set or zero, it defaults to DefaultResumeBufferSize.
+	ResumeBufferSize int
+
+	// WriteTimeout limits how long sending a message to a client can take.
+	// Clients that are too slow, or stop reading, are disconnected, so their subscriptions don't block the bus's publishers.
+	// If not set or zero, it defaults to DefaultWriteTimeout.
+	WriteTimeout time.Duration
+
+	// OnError is called when a client's connection fails, or when one of its messages can't be handled.
+	// If not set, errors are ignored.
+	OnError func(err error)
+}
+
+// Server exposes a Broker, such as the one returned by EventBus.Broker,
+// to clients connecting over a network, such as TCP or Unix domain sockets.
+type Server[Event any] struct {
+	broker eventbus.Broker[Event]
+	config *ServerConfig[Event]
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	subs      map[subscriptionKey]*serverSubscription[Event]
+	isClosed  bool
+
+	connWG sync.WaitGroup
+	subWG  sync.WaitGroup
+}
+
+// subscriptionKey identifies a subscription across all of a client's connections.
+type subscriptionKey struct {
+	clientID string
+	id       uint64
+}
+
+// serverConn handles a client's connection.
+type serverConn[Event any] struct {
+	server   *Server[Event]
+	conn     net.Conn
+	clientID string
+
+	writeMu sync.Mutex
+
+	subs map[uint64]*serverSubscription[Event] // Only used by the goroutine reading the connection
+}
+
+// serverSubscription forwards the events of a client's subscription to the client's current connection.
+type serverSubscription[Event any] struct {
+	key    subscriptionKey
+	sub    eventbus.Receiver[Event]
+	server *Server[Event]
+
+	mu      sync.Mutex
+	conn    *serverConn[Event] // Nil while the client is disconnected
+	lastSeq uint64
+	history []sequencedEvent // Recent events, kept for resuming
+	expiry  *time.Timer
+}
+
+type sequencedEvent struct {
+	seq  uint64
+	data []byte
+}
+
+// NewServer creates a new Server exposing the broker.
+func NewServer[Event any](broker eventbus.Broker[Event], config *ServerConfig[Event]) *Server[Event] {
+	return &Server[Event]{
+		broker: broker,
+		config: config,
+
+		listeners: make(map[net.Listener]bool),
+		conns:     make(map[net.Conn]bool),
+		subs:      make(map[subscriptionKey]*serverSubscription[Event]),
+	}
+}
+
+// Serve accepts clients on the listener, until the server is closed or accepting fails.
+// It always returns an error, which is ErrServerClosed once the server is closed.
+func (s *Server[Event]) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.isClosed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+
+	s.listeners[listener] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, listener)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if s.isClosed {
+				return ErrServerClosed
+			}
+
+			return err
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting clients, disconnects the connected clients, and unsubscribes all of their subscriptions.
+func (s *Server[Event]) Close() error {
+	s.mu.Lock()
+	s.isClosed = true
+
+	var err error
+	for listener := range s.listeners {
+		if closeErr := listener.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.connWG.Wait()
+
+	// Once the connections are handled, only the subscriptions kept for resuming remain
+	s.mu.Lock()
+	subs := s.subs
+	s.subs = make(map[subscriptionKey]*serverSubscription[Event])
+	s.mu.Unlock()
+
+	for _, ss := range subs {
+		ss.unsubscribe()
+	}
+
+	s.subWG.Wait()
+
+	return err
+}
+
+func (s *Server[Event]) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed {
+		return false
+	}
+
+	s.conns[conn] = true
+	s.connWG.Add(1)
+
+	return true
+}
+
+func (s *Server[Event]) handleConn(conn net.Conn) {
+	defer s.connWG.Done()
+
+	c := &serverConn[Event]{
+		server:   s,
+		conn:     conn,
+		clientID: fmt.Sprintf("%p", conn), // Replaced by the client's ID once it says hello
+		subs:     make(map[uint64]*serverSubscription[Event]),
+	}
+
+	err := c.readMessages()
+	if err != nil && !s.closed() {
+		s.onError(fmt.Errorf("remote: connection from %v failed: %w", conn.RemoteAddr(), err))
+	}
+
+	conn.Close()
+
+	for _, ss := range c.subs {
+		ss.detach(c)
+	}
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
+
+func (s *Server[Event]) closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.isClosed
+}
+
+// readMessages handles the client's messages, until the connection is closed.
+func (c *serverConn[Event]) readMessages() error {
+	reader := bufio.NewReader(c.conn)
+	maxMessageSize := maxMessageSizeOrDefault(c.server.config.MaxMessageSize)
+
+	for {
+		msg, err := wire.Read(reader, maxMessageSize)
+		if err != nil {
+			if isClosedConnError(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if err := c.handleMessage(msg); err != nil {
+			c.server.onError(err)
+
+			if err := c.write(&message{Kind: messageError, ID: msg.ID, Data: []byte(err.Error())}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *serverConn[Event]) handleMessage(msg *message) error {
+	switch msg.Kind {
+	case messageHello:
+		c.clientID = string(msg.Data)
+		return nil
+
+	case messageSubscribe:
+		if _, ok := c.subs[msg.ID]; ok {
+			return fmt.Errorf("remote: subscription %d already exists", msg.ID)
+		}
+
+		ss, existed := c.server.findOrCreateSubscription(subscriptionKey{clientID: c.clientID, id: msg.ID}, msg.TopicKeys)
+		if ss == nil {
+			return ErrServerClosed
+		}
+
+		c.subs[msg.ID] = ss
+
+		return ss.attach(c, msg.Sequence, bytes.Equal(msg.Data, resumeFlag), existed)
+
+	case messageUnsubscribe:
+		if ss, ok := c.subs[msg.ID]; ok {
+			delete(c.subs, msg.ID)
+			c.server.removeSubscription(ss)
+			ss.unsubscribe()
+		}
+
+		return nil
+
+	case messagePublish:
+		event, err := c.server.codec().Decode(msg.Data)
+		if err != nil {
+			return fmt.Errorf("remote: unable to decode event: %w", err)
+		}
+
+		c.server.broker.Publish(event, msg.TopicKeys...)
+		return nil
+
+	default:
+		return fmt.Errorf("remote: unsupported message kind %d", msg.Kind)
+	}
+}
+
+func (c *serverConn[Event]) write(msg *message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.server.writeTimeoutOrDefault())) //nolint:errcheck // Failures surface when writing
+
+	return wire.Write(c.conn, msg)
+}
+
+// findOrCreateSubscription finds the subscription kept for the client to resume,
+// or subscribes to the topics if there isn't one. It returns nil if the server is closed.
+func (s *Server[Event]) findOrCreateSubscription(key subscriptionKey, topicKeys []string) (ss *serverSubscription[Event], existed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed {
+		return nil, false
+	}
+
+	if ss, ok := s.subs[key]; ok {
+		return ss, true
+	}
+
+	ss = &serverSubscription[Event]{
+		key:    key,
+		sub:    s.broker.Subscribe(topicKeys...),
+		server: s,
+	}
+
+	s.subs[key] = ss
+
+	s.subWG.Add(1)
+	go ss.forwardEvents()
+
+	return ss, false
+}
+
+func (s *Server[Event]) removeSubscription(ss *serverSubscription[Event]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subs[ss.key] == ss {
+		delete(s.subs, ss.key)
+	}
+}
+
+// attach sends the subscription's events to the connection.
+// When resuming, the held events after the sequence are sent first.
+//
+// The acknowledgement tells the client the sequence after which events are sent, and whether the client
+// didn't miss any events, which is false if resuming was requested but some of the events are no longer held.
+func (ss *serverSubscription[Event]) attach(c *serverConn[Event], afterSeq uint64, resumeRequested, existed bool) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.expiry != nil {
+		ss.expiry.Stop()
+		ss.expiry = nil
+	}
+
+	missedEvents := false
+
+	switch {
+	case !resumeRequested:
+		afterSeq = ss.lastSeq // Only new events are sent
+
+	case !existed:
+		afterSeq = ss.lastSeq
+		missedEvents = true // The subscription expired, or the server restarted
+
+	case afterSeq >= ss.lastSeq:
+		afterSeq = ss.lastSeq // Nothing was missed
+
+	case len(ss.history) == 0 || ss.history[0].seq > afterSeq+1:
+		missedEvents = true // Some of the events after the sequence were discarded
+
+		afterSeq = ss.lastSeq
+		if len(ss.history) > 0 {
+			afterSeq = ss.history[0].seq - 1
+		}
+	}
+
+	ack := &message{Kind: messageSubscribed, ID: ss.key.id, Sequence: afterSeq}
+	if !missedEvents {
+		ack.Data = resumeFlag
+	}
+
+	if err := c.write(ack); err != nil {
+		return err
+	}
+
+	for _, event := range ss.history {
+		if event.seq <= afterSeq {
+			continue
+		}
+
+		if err := c.write(&message{Kind: messageEvent, ID: ss.key.id, Sequence: event.seq, Data: event.data}); err != nil {
+			return err
+		}
+	}
+
+	ss.conn = c
+	return nil
+}
+
+// detach stops sending the subscription's events to the connection, since it was closed.
+// If resuming is enabled, the subscription is kept for the client to reconnect, and is otherwise unsubscribed.
+func (ss *serverSubscription[Event]) detach(c *serverConn[Event]) {
+	ss.mu.Lock()
+
+	// The client may have already resumed the subscription on a new connection
+	if ss.conn != c {
+		ss.mu.Unlock()
+		return
+	}
+
+	ss.conn = nil
+
+	if ss.server.config.ResumeTimeout > 0 {
+		ss.expiry = time.AfterFunc(ss.server.config.ResumeTimeout, ss.expire)
+		ss.mu.Unlock()
+
+		return
+	}
+
+	ss.mu.Unlock()
+
+	ss.server.removeSubscription(ss)
+	ss.unsubscribe()
+}
+
+func (ss *serverSubscription[Event]) expire() {
+	ss.mu.Lock()
+	isAttached := ss.conn != nil
+	ss.mu.Unlock()
+
+	// The client may have resumed the subscription after the timer fired, but before we acquired the lock
+	if isAttached {
+		return
+	}
+
+	ss.server.removeSubscription(ss)
+	ss.unsubscribe()
+}
+
+func (ss *serverSubscription[Event]) unsubscribe() {
+	ss.mu.Lock()
+	if ss.expiry != nil {
+		ss.expiry.Stop()
+	}
+	ss.mu.Unlock()
+
+	ss.sub.Unsubscribe()
+}
+
+// forwardEvents sends the subscription's events to the client while it is connected, until the subscription is unsubscribed.
+func (ss *serverSubscription[Event]) forwardEvents() {
+	defer ss.server.subWG.Done()
+
+	resumeBufferSize := 0
+	if ss.server.config.ResumeTimeout > 0 {
+		resumeBufferSize = ss.server.resumeBufferSizeOrDefault()
+	}
+
+	for event := range ss.sub.Channel() {
+		data, err := ss.server.codec().Encode(event)
+		if err != nil {
+			ss.server.onError(fmt.Errorf("remote: unable to encode event: %w", err))
+			continue
+		}
+
+		ss.mu.Lock()
+		ss.lastSeq++
+		seq := ss.lastSeq
+
+		if resumeBufferSize > 0 {
+			ss.history = append(ss.history, sequencedEvent{seq: seq, data: data})
+
+			if len(ss.history) > resumeBufferSize {
+				ss.history[0] = sequencedEvent{} // Allow the event to be garbage collected
+				ss.history = ss.history[1:]
+			}
+		}
+
+		c := ss.conn
+		ss.mu.Unlock()
+
+		if c == nil {
+			continue // Held for resuming
+		}
+
+		if err := c.write(&message{Kind: messageEvent, ID: ss.key.id, Sequence: seq, Data: data}); err != nil {
+			c.conn.Close() // Closing the connection stops reading, which detaches the subscription
+		}
+	}
+}
+
+func (s *Server[Event]) codec() codec.Codec[Event] {
+	if s.config.Codec != nil {
+		return s.config.Codec
+	}
+
+	return codec.JSON[Event]{}
+}
+
+func (s *Server[Event]) onError(err error) {
+	if s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+}
+
+func (s *Server[Event]) resumeBufferSizeOrDefault() int {
+	if s.config.ResumeBufferSize <= 0 {
+		return DefaultResumeBufferSize
+	}
+
+	return s.config.ResumeBufferSize
+}
+
+func (s *Server[Event]) writeTimeoutOrDefault() time.Duration {
+	if s.config.WriteTimeout <= 0 {
+		return DefaultWriteTimeout
+	}
+
+	return s.config.WriteTimeout
+}
+
+func maxMessageSizeOrDefault(maxMessageSize int) int {
+	if maxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+
+	return maxMessageSize
+}
+
+func isClosedConnError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
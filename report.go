@@ -0,0 +1,61 @@
+package eventbus
+
+// PublishReport describes the delivery of a published event.
+type PublishReport[Event any] struct {
+	// MatchedTopics lists the published topics that had at least one subscription.
+	MatchedTopics []string
+
+	// Delivered is the number of subscriptions the event was delivered to.
+	Delivered int
+
+	// Deduplicated is the number of times the event was not sent to a subscription,
+	// because it was already sent to that subscription through another topic.
+	Deduplicated int
+
+	// Dropped lists the subscriptions that the event was not delivered to,
+	// because their channel was full for longer than the PublishTimeout.
+	Dropped []*Subscription[Event]
+}
+
+// PublishWithReport sends the provided event to all of the listed topics, and reports how it was delivered.
+// All subscriptions to those topics will be notified of the event.
+// Once the bus is closed, the event is ignored, and the report is empty.
+func (b *EventBus[Event]) PublishWithReport(event Event, topicKeys ...string) *PublishReport[Event] {
+	report := &PublishReport[Event]{}
+	if b.isPublishingClosed() {
+		return report
+	}
+
+	b.publish(event, topicKeys, b.publishTimeout(), report)
+
+	return report
+}
+
+func (r *PublishReport[Event]) addMatchedTopic(topicKey string) {
+	// The same topic can be listed more than once when publishing
+	for _, matchedTopicKey := range r.MatchedTopics {
+		if matchedTopicKey == topicKey {
+			return
+		}
+	}
+
+	r.MatchedTopics = append(r.MatchedTopics, topicKey)
+}
+
+func (r *PublishReport[Event]) addDelivery(sub *Subscription[Event], result deliveryResult) {
+	switch result {
+	case delivered:
+		r.Delivered++
+	case dropped:
+		r.Dropped = append(r.Dropped, sub)
+	case unsubscribed:
+		// The subscription was unsubscribed while publishing, so it wasn't expecting the event
+	}
+}
+
+// merge adds the deliveries from another report, which was filled by part of the same publish.
+func (r *PublishReport[Event]) merge(other *PublishReport[Event]) {
+	r.Delivered += other.Delivered
+	r.Deduplicated += other.Deduplicated
+	r.Dropped = append(r.Dropped, other.Dropped...)
+}
@@ -0,0 +1,97 @@
+package eventbus_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestPublishWithReport(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("when nothing is subscribed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		report := bus.PublishWithReport("1", "key1", "key2")
+		ensure(report).Equals(&eventbus.PublishReport[string]{})
+	})
+
+	ensure.Run("when the bus is closed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		bus.Close()
+		report := bus.PublishWithReport("1", "key1")
+		ensure(report).Equals(&eventbus.PublishReport[string]{})
+		ensure(len(sub.Channel())).Equals(0)
+	})
+
+	ensure.Run("when subscriptions are matched", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		sub1 := bus.Subscribe("key1", "key2")
+		sub2 := bus.Subscribe("key2")
+		sub3 := bus.Subscribe("key3")
+
+		report := bus.PublishWithReport("1", "key1", "key2", "key4", "key1")
+		ensure(report).Equals(&eventbus.PublishReport[string]{
+			MatchedTopics: []string{"key1", "key2"},
+			Delivered:     2,
+			Deduplicated:  2, // sub1 through key2, and then through key1 again
+		})
+
+		ensure(bufferSubscription(sub1, 1).events()).Equals([]string{"1"})
+		ensure(bufferSubscription(sub2, 1).events()).Equals([]string{"1"})
+		ensure(len(sub3.Channel())).Equals(0)
+	})
+
+	ensure.Run("when more topics are matched than are tracked without allocating", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+
+		topicKeys := make([]string, 20)
+		for i := range topicKeys {
+			topicKeys[i] = fmt.Sprintf("key%d", i)
+		}
+
+		sub1 := bus.Subscribe(topicKeys...)
+		sub2 := bus.Subscribe(topicKeys[19])
+
+		report := bus.PublishWithReport("1", topicKeys...)
+		ensure(report.MatchedTopics).Equals(topicKeys)
+		ensure(report.Delivered).Equals(2)
+		ensure(report.Deduplicated).Equals(19)
+
+		ensure(bufferSubscription(sub1, 1).events()).Equals([]string{"1"})
+		ensure(bufferSubscription(sub2, 1).events()).Equals([]string{"1"})
+	})
+
+	ensure.Run("when a subscription times out", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{
+			BufferSize:     1,
+			PublishTimeout: 10 * time.Millisecond,
+		})
+
+		sub1 := bus.Subscribe("key1")
+		sub2 := bus.Subscribe("key1")
+		buf2 := bufferSubscription(sub2, 2)
+
+		ensure(bus.PublishWithReport("1", "key1")).Equals(&eventbus.PublishReport[string]{
+			MatchedTopics: []string{"key1"},
+			Delivered:     2,
+		})
+
+		report := bus.PublishWithReport("2", "key1")
+		ensure(report.MatchedTopics).Equals([]string{"key1"})
+		ensure(report.Delivered).Equals(1)
+		ensure(report.Deduplicated).Equals(0)
+		ensure(len(report.Dropped)).Equals(1)
+		ensure(report.Dropped[0] == sub1).IsTrue()
+
+		ensure(bufferSubscription(sub1, 1).events()).Equals([]string{"1"})
+		ensure(buf2.events()).Equals([]string{"1", "2"})
+	})
+}
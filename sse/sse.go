@@ -0,0 +1,228 @@
+// Package sse provides an http.Handler, which streams events from an EventBus to clients using Server-Sent Events.
+//
+// See: https://html.spec.whatwg.org/multipage/server-sent-events.html
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+)
+
+// DefaultHeartbeatInterval between heartbeats. Used when the HeartbeatInterval is not configured.
+const DefaultHeartbeatInterval = 15 * time.Second
+
+// ErrInvalidEventID is passed to OnError when an event's ID contains a line break or a null character,
+// which would allow the ID to inject other fields into the stream. The event is skipped.
+var ErrInvalidEventID = errors.New("sse: event ID contains a line break or null character")
+
+// Config can be passed to NewHandler to customize the Handler.
+type Config[Event any] struct {
+	// Topics returns the keys of the topics to subscribe to for the request.
+	// If it returns no topic keys, the request fails with a 400 Bad Request.
+	// It is required.
+	Topics func(r *http.Request) []string
+
+	// Codec encodes the events into the data sent to the client. Only its Encode method is used.
+	// If not set, events are encoded as JSON.
+	Codec codec.Codec[Event]
+
+	// EventID returns the ID of the event, which is sent to the client.
+	// Browsers send the last ID they received in the Last-Event-ID header when they reconnect.
+	// Events whose ID contains a line break or a null character are skipped, and ErrInvalidEventID is passed to OnError.
+	// If not set, event IDs are not sent.
+	EventID func(event Event) string
+
+	// Replay returns the events the client missed after the event with the lastEventID, in order.
+	// It is only called when a client reconnects with a Last-Event-ID header.
+	// The replayed events are sent before any new events, and new events with the same IDs are skipped.
+	// If not set, the Last-Event-ID header is ignored.
+	Replay func(r *http.Request, topicKeys []string, lastEventID string) ([]Event, error)
+
+	// HeartbeatInterval is how often a comment is sent to keep idle connections open.
+	// If not set or zero, it defaults to DefaultHeartbeatInterval.
+	// If negative, heartbeats are not sent.
+	HeartbeatInterval time.Duration
+
+	// SubscriptionConfig customizes the subscription created for each request,
+	// if the subscriber is an eventbus.ConfigurableSubscriber, such as the Broker returned by EventBus.Broker.
+	// If not set, the subscriber's default subscription configuration is used.
+	SubscriptionConfig *eventbus.SubscriptionConfig[Event]
+
+	// OnError is called when an event can't be encoded, which skips the event,
+	// or when the missed events can't be replayed, which fails the request with a 500 Internal Server Error.
+	// If not set, errors are ignored.
+	OnError func(r *http.Request, err error)
+}
+
+// Handler streams the events published to the topics of each request using Server-Sent Events.
+// The subscription for the request is unsubscribed once the client disconnects.
+type Handler[Event any] struct {
+	subscriber eventbus.Subscriber[Event]
+	config     *Config[Event]
+}
+
+var _ http.Handler = &Handler[any]{}
+
+// NewHandler creates a new Handler streaming events from the subscriber, such as the Broker returned by EventBus.Broker.
+func NewHandler[Event any](subscriber eventbus.Subscriber[Event], config *Config[Event]) *Handler[Event] {
+	return &Handler[Event]{
+		subscriber: subscriber,
+		config:     config,
+	}
+}
+
+// ServeHTTP streams the events published to the request's topics until the client disconnects.
+func (h *Handler[Event]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	topicKeys := h.config.Topics(r)
+	if len(topicKeys) == 0 {
+		http.Error(w, "No topics requested", http.StatusBadRequest)
+		return
+	}
+
+	// Subscribe before replaying, so no events are missed in between
+	sub := eventbus.SubscribeConfigured(h.subscriber, h.config.SubscriptionConfig, topicKeys...)
+	defer sub.Unsubscribe()
+
+	replayed, err := h.replay(r, topicKeys)
+	if err != nil {
+		h.onError(r, err)
+		http.Error(w, "Unable to replay missed events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	replayedIDs := map[string]bool{}
+	for _, event := range replayed {
+		if id, ok := h.writeEvent(w, r, event); ok {
+			replayedIDs[id] = true
+		}
+	}
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if interval := h.heartbeatIntervalOrDefault(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.Channel():
+			if !ok {
+				return
+			}
+
+			if len(replayedIDs) > 0 && h.config.EventID != nil && replayedIDs[h.config.EventID(event)] {
+				continue
+			}
+
+			h.writeEvent(w, r, event)
+			flusher.Flush()
+
+		case <-heartbeat:
+			if _, err := w.Write([]byte(":\n\n")); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *Handler[Event]) replay(r *http.Request, topicKeys []string) ([]Event, error) {
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" || h.config.Replay == nil {
+		return nil, nil
+	}
+
+	return h.config.Replay(r, topicKeys, lastEventID)
+}
+
+// writeEvent writes the event, returning its ID, and false if it couldn't be encoded or its ID is invalid.
+// Write errors are ignored, since they mean the client disconnected, which is handled by the request's context.
+func (h *Handler[Event]) writeEvent(w http.ResponseWriter, r *http.Request, event Event) (string, bool) {
+	var id string
+	if h.config.EventID != nil {
+		id = h.config.EventID(event)
+
+		if strings.ContainsAny(id, "\r\n\x00") {
+			h.onError(r, fmt.Errorf("%w: %q", ErrInvalidEventID, id))
+			return "", false
+		}
+	}
+
+	data, err := h.encode(event)
+	if err != nil {
+		h.onError(r, fmt.Errorf("unable to encode event: %w", err))
+		return "", false
+	}
+
+	buf := &bytes.Buffer{}
+
+	if h.config.EventID != nil {
+		fmt.Fprintf(buf, "id: %s\n", id)
+	}
+
+	// Each line of the data needs to be sent as a separate data field
+	for _, line := range splitLines(data) {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+
+	buf.WriteString("\n")
+	w.Write(buf.Bytes()) //nolint:errcheck
+
+	return id, true
+}
+
+// splitLines splits the data on each line break recognized by clients, which are "\r\n", "\r", and "\n".
+func splitLines(data []byte) [][]byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+
+	return bytes.Split(data, []byte("\n"))
+}
+
+func (h *Handler[Event]) encode(event Event) ([]byte, error) {
+	if h.config.Codec != nil {
+		return h.config.Codec.Encode(event)
+	}
+
+	return codec.JSON[Event]{}.Encode(event)
+}
+
+func (h *Handler[Event]) onError(r *http.Request, err error) {
+	if h.config.OnError != nil {
+		h.config.OnError(r, err)
+	}
+}
+
+func (h *Handler[Event]) heartbeatIntervalOrDefault() time.Duration {
+	if h.config.HeartbeatInterval == 0 {
+		return DefaultHeartbeatInterval
+	}
+
+	return h.config.HeartbeatInterval
+}
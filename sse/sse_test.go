@@ -0,0 +1,359 @@
+package sse_test
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/codec"
+	"github.com/JosiahWitt/eventbus/sse"
+)
+
+type Message struct {
+	ID   string `json:"id"`
+	Body string `json:"body"`
+}
+
+var bodyCodec = codec.Funcs[*Message]{
+	EncodeFunc: func(msg *Message) ([]byte, error) { return []byte(msg.Body), nil },
+}
+
+func TestHandler(t *testing.T) {
+	ensure := ensure.New(t)
+
+	topicsFromQuery := func(r *http.Request) []string {
+		if topics := r.URL.Query().Get("topics"); topics != "" {
+			return strings.Split(topics, ",")
+		}
+
+		return nil
+	}
+
+	ensure.Run("streams the events published to the requested topics", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics:  topicsFromQuery,
+			EventID: func(msg *Message) string { return msg.ID },
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1,key2", "")
+		defer stream.close()
+
+		ensure(stream.resp.Header.Get("Content-Type")).Equals("text/event-stream")
+		ensure(stream.resp.Header.Get("Cache-Control")).Equals("no-cache")
+
+		bus.Publish(&Message{ID: "1", Body: "hello"}, "key1")
+		bus.Publish(&Message{ID: "2", Body: "ignored"}, "key3")
+		bus.Publish(&Message{ID: "3", Body: "world"}, "key2")
+
+		ensure(stream.next()).Equals("id: 1\ndata: {\"id\":\"1\",\"body\":\"hello\"}\n")
+		ensure(stream.next()).Equals("id: 3\ndata: {\"id\":\"3\",\"body\":\"world\"}\n")
+	})
+
+	ensure.Run("encodes events with the provided encoder", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics: topicsFromQuery,
+			Codec:  bodyCodec,
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "1", Body: "line 1\nline 2"}, "key1")
+		ensure(stream.next()).Equals("data: line 1\ndata: line 2\n")
+	})
+
+	ensure.Run("splits the data on each kind of line break", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics: topicsFromQuery,
+			Codec:  bodyCodec,
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "1", Body: "line 1\r\nline 2\rline 3\nline 4\n\rline 6"}, "key1")
+		ensure(stream.next()).Equals("data: line 1\ndata: line 2\ndata: line 3\ndata: line 4\ndata: \ndata: line 6\n")
+	})
+
+	ensure.Run("skips events that cannot be encoded", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		errs := make(chan error, 1)
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics: topicsFromQuery,
+			Codec: codec.Funcs[*Message]{
+				EncodeFunc: func(msg *Message) ([]byte, error) {
+					if msg.ID == "bad" {
+						return nil, errors.New("bad message")
+					}
+
+					return []byte(msg.Body), nil
+				},
+			},
+			OnError: func(r *http.Request, err error) { errs <- err },
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "bad"}, "key1")
+		bus.Publish(&Message{ID: "good", Body: "hello"}, "key1")
+
+		ensure(stream.next()).Equals("data: hello\n")
+		ensure((<-errs).Error()).Equals("unable to encode event: bad message")
+	})
+
+	ensure.Run("skips events with IDs that contain line breaks", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		errs := make(chan error, 3)
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics:  topicsFromQuery,
+			Codec:   bodyCodec,
+			EventID: func(msg *Message) string { return msg.ID },
+			OnError: func(r *http.Request, err error) { errs <- err },
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "1\ndata: injected", Body: "one"}, "key1")
+		bus.Publish(&Message{ID: "2\revent: injected", Body: "two"}, "key1")
+		bus.Publish(&Message{ID: "3\x00", Body: "three"}, "key1")
+		bus.Publish(&Message{ID: "4", Body: "four"}, "key1")
+
+		ensure(stream.next()).Equals("id: 4\ndata: four\n")
+
+		for i := 0; i < 3; i++ {
+			ensure(<-errs).IsError(sse.ErrInvalidEventID)
+		}
+	})
+
+	ensure.Run("replays missed events before new events, skipping duplicates", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		var replayedTopics []string
+		var replayedAfter string
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics:  topicsFromQuery,
+			Codec:   bodyCodec,
+			EventID: func(msg *Message) string { return msg.ID },
+			Replay: func(r *http.Request, topicKeys []string, lastEventID string) ([]*Message, error) {
+				replayedTopics = topicKeys
+				replayedAfter = lastEventID
+
+				// Simulate an event published while replaying, which the subscription also receives
+				bus.Publish(&Message{ID: "3", Body: "three"}, "key1")
+
+				return []*Message{{ID: "2", Body: "two"}, {ID: "3", Body: "three"}}, nil
+			},
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "1")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "4", Body: "four"}, "key1")
+
+		ensure(stream.next()).Equals("id: 2\ndata: two\n")
+		ensure(stream.next()).Equals("id: 3\ndata: three\n")
+		ensure(stream.next()).Equals("id: 4\ndata: four\n")
+		ensure(replayedTopics).Equals([]string{"key1"})
+		ensure(replayedAfter).Equals("1")
+	})
+
+	ensure.Run("does not replay without a Last-Event-ID", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics: topicsFromQuery,
+			Codec:  bodyCodec,
+			Replay: func(r *http.Request, topicKeys []string, lastEventID string) ([]*Message, error) {
+				return nil, errors.New("should not be called")
+			},
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "1", Body: "one"}, "key1")
+		ensure(stream.next()).Equals("data: one\n")
+	})
+
+	ensure.Run("fails when the missed events cannot be replayed", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		var replayErr error
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics: topicsFromQuery,
+			Replay: func(r *http.Request, topicKeys []string, lastEventID string) ([]*Message, error) {
+				return nil, errors.New("storage unavailable")
+			},
+			OnError: func(r *http.Request, err error) { replayErr = err },
+		}))
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"?topics=key1", nil)
+		ensure(err).IsNotError()
+		req.Header.Set("Last-Event-ID", "1")
+
+		resp, err := http.DefaultClient.Do(req)
+		ensure(err).IsNotError()
+		defer resp.Body.Close()
+
+		ensure(resp.StatusCode).Equals(http.StatusInternalServerError)
+		ensure(replayErr.Error()).Equals("storage unavailable")
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("rejects requests without topics", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{Topics: topicsFromQuery}))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		ensure(err).IsNotError()
+		defer resp.Body.Close()
+
+		ensure(resp.StatusCode).Equals(http.StatusBadRequest)
+	})
+
+	ensure.Run("sends heartbeats", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics:            topicsFromQuery,
+			HeartbeatInterval: 10 * time.Millisecond,
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		ensure(stream.next()).Equals(":\n")
+		ensure(stream.next()).Equals(":\n")
+	})
+
+	ensure.Run("unsubscribes when the client disconnects", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{Topics: topicsFromQuery}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1,key2", "")
+		ensure(bus.NumTopics()).Equals(2)
+
+		stream.close()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for bus.NumTopics() > 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("applies the subscription config", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		defer bus.Close()
+
+		server := httptest.NewServer(sse.NewHandler[*Message](bus.Broker(), &sse.Config[*Message]{
+			Topics: topicsFromQuery,
+			Codec:  bodyCodec,
+			SubscriptionConfig: &eventbus.SubscriptionConfig[*Message]{
+				Throttle: time.Hour,
+			},
+		}))
+		defer server.Close()
+
+		stream := openStream(ensure, server.URL+"?topics=key1", "")
+		defer stream.close()
+
+		bus.Publish(&Message{ID: "1", Body: "one"}, "key1")
+		bus.Publish(&Message{ID: "2", Body: "two"}, "key1")
+		bus.Publish(&Message{ID: "3", Body: "three"}, "key1")
+
+		ensure(stream.next()).Equals("data: one\n")
+	})
+}
+
+type stream struct {
+	resp    *http.Response
+	scanner *bufio.Scanner
+	once    sync.Once
+}
+
+// openStream connects to the handler, returning once the response headers are received,
+// which means the handler has subscribed.
+func openStream(ensure ensurepkg.Ensure, url, lastEventID string) *stream {
+	ensure.T().Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	ensure(err).IsNotError()
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	ensure(err).IsNotError()
+	ensure(resp.StatusCode).Equals(http.StatusOK)
+
+	return &stream{
+		resp:    resp,
+		scanner: bufio.NewScanner(resp.Body),
+	}
+}
+
+// next returns the lines of the next message, up to the blank line ending it.
+func (s *stream) next() string {
+	var message strings.Builder
+
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			return message.String()
+		}
+
+		message.WriteString(line + "\n")
+	}
+
+	return message.String()
+}
+
+func (s *stream) close() {
+	s.once.Do(func() { s.resp.Body.Close() })
+}
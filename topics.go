@@ -0,0 +1,164 @@
+package eventbus
+
+// Topics lists the keys of the topics that currently have subscriptions, in no particular order.
+//
+// When using the sharded topic registry (see Config.TopicShards), the list is a consistent snapshot.
+// Otherwise, it may or may not include topics that are created or deleted while listing.
+func (b *EventBus[Event]) Topics() []string {
+	topicKeys := []string{}
+	collect := func(topicKey string, _ *topic[Event]) bool {
+		topicKeys = append(topicKeys, topicKey)
+		return true
+	}
+
+	if b.shardedTopics != nil {
+		b.shardedTopics.Range(collect)
+	} else {
+		b.topics.Range(collect)
+	}
+
+	return topicKeys
+}
+
+// NumTopics returns the number of topics that currently have subscriptions.
+func (b *EventBus[Event]) NumTopics() int {
+	if b.shardedTopics != nil {
+		return b.shardedTopics.Len()
+	}
+
+	count := 0
+	b.topics.Range(func(string, *topic[Event]) bool {
+		count++
+		return true
+	})
+
+	return count
+}
+
+// HasTopic reports whether the topic currently has subscriptions.
+func (b *EventBus[Event]) HasTopic(topicKey string) bool {
+	_, ok := b.loadTopic(topicKey)
+	return ok
+}
+
+// topicWatcher is registered by WatchTopics.
+type topicWatcher struct {
+	notify func(topicKey string)
+}
+
+// WatchTopics calls notify with the key of each topic that is created or deleted,
+// which is when the topic gets its first subscription, or loses its last subscription.
+// It stops once the returned unwatch function is called.
+//
+// The notify function is called synchronously once the topic has changed, after the registry is unlocked,
+// so it can use the bus, such as by calling HasTopic or Topics. It should not block, since it delays the change.
+// Notifications for the same topic may arrive out of order when the topic changes concurrently,
+// so each notification should be handled by checking the topic's current state with HasTopic.
+func (b *EventBus[Event]) WatchTopics(notify func(topicKey string)) (unwatch func()) {
+	watcher := &topicWatcher{notify: notify}
+
+	b.watchersMu.Lock()
+	defer b.watchersMu.Unlock()
+
+	oldWatchers := b.topicWatchers()
+	newWatchers := make([]*topicWatcher, 0, len(oldWatchers)+1)
+	newWatchers = append(newWatchers, oldWatchers...)
+	newWatchers = append(newWatchers, watcher)
+	b.watchers.Store(newWatchers)
+
+	return func() {
+		b.watchersMu.Lock()
+		defer b.watchersMu.Unlock()
+
+		oldWatchers := b.topicWatchers()
+		newWatchers := make([]*topicWatcher, 0, len(oldWatchers))
+		for _, w := range oldWatchers {
+			if w != watcher {
+				newWatchers = append(newWatchers, w)
+			}
+		}
+
+		b.watchers.Store(newWatchers)
+	}
+}
+
+func (b *EventBus[Event]) topicWatchers() []*topicWatcher {
+	watchers, _ := b.watchers.Load().([]*topicWatcher)
+	return watchers
+}
+
+func (b *EventBus[Event]) notifyTopicWatchers(topicKey string) {
+	for _, watcher := range b.topicWatchers() {
+		watcher.notify(topicKey)
+	}
+}
+
+func (b *EventBus[Event]) loadTopic(topicKey string) (*topic[Event], bool) {
+	if b.shardedTopics != nil {
+		return b.shardedTopics.Load(topicKey)
+	}
+
+	return b.topics.Load(topicKey)
+}
+
+// subscribeToTopic adds the subscription to the topic, creating the topic if it doesn't exist.
+func (b *EventBus[Event]) subscribeToTopic(topicKey string, sub *Subscription[Event]) *topic[Event] {
+	if b.shardedTopics == nil {
+		t := b.findOrCreateTopic(topicKey)
+		t.addSubscription(sub)
+
+		return t
+	}
+
+	// The shard is locked while the subscription is added, so the topic can't be deleted in the meantime
+	var (
+		t       *topic[Event]
+		created bool
+	)
+
+	b.shardedTopics.Update(topicKey, func(existing *topic[Event], loaded bool) (*topic[Event], bool) {
+		t, created = existing, !loaded
+		if created {
+			t = &topic[Event]{key: topicKey, bus: b}
+		}
+
+		t.addSubscription(sub)
+		return t, true
+	})
+
+	// Watchers are notified once the shard is unlocked, so they can use the bus
+	if created {
+		b.topicCreated(topicKey)
+	}
+
+	return t
+}
+
+// unsubscribeFromTopic removes the subscription from the topic, deleting the topic if it has no more subscriptions.
+func (b *EventBus[Event]) unsubscribeFromTopic(t *topic[Event], sub *Subscription[Event]) {
+	if b.shardedTopics == nil {
+		if _, deleted := t.removeSubscription(sub); deleted {
+			b.topicDeleted(t.key)
+		}
+
+		return
+	}
+
+	var deleted bool
+	b.shardedTopics.Update(t.key, func(existing *topic[Event], loaded bool) (*topic[Event], bool) {
+		var isClosed bool
+		isClosed, deleted = t.removeSubscription(sub)
+
+		// The registry may already hold a new topic for the key, if this one was closed earlier
+		if existing != t {
+			return existing, loaded
+		}
+
+		return existing, !isClosed
+	})
+
+	// Watchers are notified once the shard is unlocked, so they can use the bus
+	if deleted {
+		b.topicDeleted(t.key)
+	}
+}
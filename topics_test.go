@@ -0,0 +1,148 @@
+package eventbus_test
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestTopicRegistries(t *testing.T) {
+	ensure := ensure.New(t)
+
+	registries := []struct {
+		Name   string
+		Config *eventbus.Config
+	}{
+		{
+			Name:   "sync.Map registry",
+			Config: &eventbus.Config{},
+		},
+		{
+			Name:   "sharded registry",
+			Config: &eventbus.Config{TopicShards: 8},
+		},
+	}
+
+	for _, registry := range registries {
+		registry := registry
+
+		ensure.Run(registry.Name, func(ensure ensurepkg.Ensure) {
+			ensure.Run("lists the topics with subscriptions", func(ensure ensurepkg.Ensure) {
+				bus := eventbus.NewWithConfig[string](registry.Config)
+
+				ensure(bus.Topics()).Equals([]string{})
+				ensure(bus.NumTopics()).Equals(0)
+
+				sub1 := bus.Subscribe("key1", "key2")
+				sub2 := bus.Subscribe("key2", "key3")
+
+				topics := bus.Topics()
+				sort.Strings(topics)
+				ensure(topics).Equals([]string{"key1", "key2", "key3"})
+				ensure(bus.NumTopics()).Equals(3)
+
+				sub1.Unsubscribe()
+
+				topics = bus.Topics()
+				sort.Strings(topics)
+				ensure(topics).Equals([]string{"key2", "key3"})
+				ensure(bus.NumTopics()).Equals(2)
+
+				sub2.Unsubscribe()
+
+				ensure(bus.Topics()).Equals([]string{})
+				ensure(bus.NumTopics()).Equals(0)
+			})
+
+			ensure.Run("notifies watchers when topics are created and deleted", func(ensure ensurepkg.Ensure) {
+				bus := eventbus.NewWithConfig[string](registry.Config)
+
+				var notified []string
+				unwatch := bus.WatchTopics(func(topicKey string) { notified = append(notified, topicKey) })
+
+				sub1 := bus.Subscribe("key1", "key2")
+				ensure(bus.HasTopic("key1")).IsTrue()
+
+				sub2 := bus.Subscribe("key2") // The topic already exists
+				sub1.Unsubscribe()
+				ensure(bus.HasTopic("key1")).IsFalse()
+				ensure(bus.HasTopic("key2")).IsTrue()
+
+				unwatch()
+				sub2.Unsubscribe()
+
+				ensure(notified).Equals([]string{"key1", "key2", "key1"})
+				ensure(bus.HasTopic("key2")).IsFalse()
+			})
+
+			ensure.Run("allows watchers to use the bus", func(ensure ensurepkg.Ensure) {
+				bus := eventbus.NewWithConfig[string](registry.Config)
+
+				var hasTopic []bool
+				unwatch := bus.WatchTopics(func(topicKey string) {
+					hasTopic = append(hasTopic, bus.HasTopic(topicKey))
+					bus.Topics()
+				})
+				defer unwatch()
+
+				bus.Subscribe("key1").Unsubscribe()
+				ensure(hasTopic).Equals([]bool{true, false})
+			})
+
+			ensure.Run("delivers events to subscriptions", func(ensure ensurepkg.Ensure) {
+				bus := eventbus.NewWithConfig[string](registry.Config)
+
+				sub1 := bus.Subscribe("key1", "key2")
+				buf1 := bufferSubscription(sub1, 2)
+
+				sub2 := bus.Subscribe("key2")
+				buf2 := bufferSubscription(sub2, 1)
+
+				bus.Publish("1", "key1", "key2")
+				sub2.Unsubscribe()
+				bus.Publish("2", "key2")
+
+				sub3 := bus.Subscribe("key2")
+				buf3 := bufferSubscription(sub3, 1)
+				bus.Publish("3", "key2")
+
+				ensure(buf1.events()).Equals([]string{"1", "2"})
+				ensure(buf2.events()).Equals([]string{"1"})
+				ensure(buf3.events()).Equals([]string{"3"})
+			})
+
+			ensure.Run("concurrent subscriptions, publishing, and unsubscriptions", func(ensure ensurepkg.Ensure) {
+				bus := eventbus.NewWithConfig[string](registry.Config)
+
+				const numParallel = 200
+
+				var wg sync.WaitGroup
+				wg.Add(numParallel * 2)
+
+				for i := 0; i < numParallel; i++ {
+					topicKey := fmt.Sprintf("key%d", i%5)
+
+					go func() {
+						defer wg.Done()
+						bus.Subscribe(topicKey).Unsubscribe()
+					}()
+
+					go func() {
+						defer wg.Done()
+						bus.Publish("1", topicKey)
+					}()
+				}
+
+				wg.Wait()
+
+				// Every topic is deleted once its subscriptions are unsubscribed
+				ensure(bus.Topics()).Equals([]string{})
+			})
+		})
+	}
+}
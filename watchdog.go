@@ -0,0 +1,226 @@
+package eventbus
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchdogThreshold for the watchdog started by StartWatchdog. Used when the Threshold is not configured.
+const DefaultWatchdogThreshold = 5 * time.Second
+
+// WatchdogConfig can be passed to StartWatchdog to customize the watchdog.
+type WatchdogConfig[Event any] struct {
+	// Threshold is how long a subscription must be stalled before it is reported.
+	// A subscription is stalled while its channel's buffer is full,
+	// or while events are waiting to be received without any of them being received.
+	// If not set or zero, it defaults to DefaultWatchdogThreshold.
+	Threshold time.Duration
+
+	// Interval is how often the subscriptions are checked, which limits how precisely stalls are measured.
+	// If not set or zero, it defaults to a quarter of the Threshold.
+	Interval time.Duration
+
+	// OnStall is called with each subscription once it has been stalled for the Threshold.
+	// It is only called again for the same subscription after the subscription recovers and stalls again.
+	//
+	// It is called synchronously by the watchdog's goroutine, so it must not call the function returned by StartWatchdog.
+	OnStall func(stall *Stall[Event])
+
+	// Disconnect unsubscribes stalled subscriptions before OnStall is called.
+	// Afterwards, Err and Receive return a *StalledError, which matches ErrSubscriptionStalled.
+	Disconnect bool
+}
+
+// Stall describes a subscription that was detected by a watchdog.
+type Stall[Event any] struct {
+	// Subscription that stalled. Its ID and Name identify it.
+	Subscription *Subscription[Event]
+
+	// Topics the subscription was subscribed to when it was detected.
+	Topics []string
+
+	// QueueDepth is the number of events that were waiting in the subscription's channel.
+	QueueDepth int
+
+	// Duration the subscription has been stalled for.
+	Duration time.Duration
+}
+
+type watchdog[Event any] struct {
+	bus    *EventBus[Event]
+	config *WatchdogConfig[Event]
+
+	// states tracks the subscriptions seen by the last check. Subscriptions are dropped once they are no longer seen.
+	states map[*Subscription[Event]]*watchdogState
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type watchdogState struct {
+	received     uint64
+	stalledSince time.Time // Zero while the subscription isn't stalled
+	isReported   bool
+}
+
+// StartWatchdog starts a goroutine that periodically checks the bus's subscriptions for slow consumers,
+// reporting subscriptions that stall for longer than the configured threshold.
+// Stalls are logged when the bus has a Logger.
+// It stops once the returned stop function is called, which waits for the goroutine to exit, or once the bus is closed.
+//
+// Conflated, debounced, and throttled subscriptions are not checked, since they never block Publish.
+func (b *EventBus[Event]) StartWatchdog(config *WatchdogConfig[Event]) (stop func()) {
+	w := &watchdog[Event]{
+		bus:     b,
+		config:  config,
+		states:  map[*Subscription[Event]]*watchdogState{},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go w.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(w.stop) })
+		<-w.stopped
+	}
+}
+
+func (w *watchdog[Event]) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.intervalOrDefault())
+	defer ticker.Stop()
+
+	closed := w.bus.closedChan()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			w.check(now)
+		case <-w.stop:
+			return
+		case <-closed:
+			return
+		}
+	}
+}
+
+// check updates the state of each subscription, reporting those that have been stalled for the threshold.
+func (w *watchdog[Event]) check(now time.Time) {
+	states := make(map[*Subscription[Event]]*watchdogState, len(w.states))
+
+	for _, sub := range w.bus.subscriptions() {
+		if _, ok := states[sub]; ok || sub.queue != nil {
+			continue
+		}
+
+		queueDepth := len(sub.ch)
+		received := sub.numReceived(queueDepth)
+
+		state, ok := w.states[sub]
+		if !ok {
+			// The subscription's progress is unknown until the next check, so it is considered stalled if events are waiting
+			state = &watchdogState{received: received}
+		}
+
+		states[sub] = state
+
+		isFull := cap(sub.ch) > 0 && queueDepth == cap(sub.ch)
+		isWaiting := queueDepth > 0 || atomic.LoadInt32(&sub.waiting) > 0
+		isStalled := isFull || (isWaiting && received == state.received)
+		state.received = received
+
+		if !isStalled {
+			state.stalledSince = time.Time{}
+			state.isReported = false
+			continue
+		}
+
+		if state.stalledSince.IsZero() {
+			state.stalledSince = now
+		}
+
+		if duration := now.Sub(state.stalledSince); duration >= w.thresholdOrDefault() && !state.isReported {
+			state.isReported = true
+			w.report(sub, queueDepth, duration)
+		}
+	}
+
+	w.states = states
+}
+
+func (w *watchdog[Event]) report(sub *Subscription[Event], queueDepth int, duration time.Duration) {
+	stall := &Stall[Event]{
+		Subscription: sub,
+		Topics:       w.bus.topicNames(sub.Topics()),
+		QueueDepth:   queueDepth,
+		Duration:     duration,
+	}
+
+	if w.bus.logger != nil {
+		w.bus.logger.Warn("eventbus: subscription stalled", sub.logAttrs(
+			"topics", stall.Topics,
+			"queue_depth", queueDepth,
+			"duration", duration,
+			"disconnect", w.config.Disconnect,
+		)...)
+	}
+
+	if w.config.Disconnect {
+		sub.unsubscribe(&StalledError{Duration: duration, QueueDepth: queueDepth})
+	}
+
+	if w.config.OnStall != nil {
+		w.config.OnStall(stall)
+	}
+}
+
+// subscriptions lists the subscriptions to the bus's topics.
+// Subscriptions to multiple topics are listed once for each topic.
+func (b *EventBus[Event]) subscriptions() []*Subscription[Event] {
+	var subs []*Subscription[Event]
+	collect := func(_ string, t *topic[Event]) bool {
+		subs = append(subs, t.subscriptions()...)
+		return true
+	}
+
+	if b.shardedTopics != nil {
+		b.shardedTopics.Range(collect)
+	} else {
+		b.topics.Range(collect)
+	}
+
+	return subs
+}
+
+// numReceived returns the number of events received from the subscription's channel,
+// given the number of events that are waiting in it.
+func (s *Subscription[Event]) numReceived(queueDepth int) uint64 {
+	sent := atomic.LoadUint64(&s.sent)
+
+	// The channel's length is read first, so it may include an event that was sent but not counted yet
+	if uint64(queueDepth) > sent {
+		return 0
+	}
+
+	return sent - uint64(queueDepth)
+}
+
+func (w *watchdog[Event]) thresholdOrDefault() time.Duration {
+	if w.config.Threshold <= 0 {
+		return DefaultWatchdogThreshold
+	}
+
+	return w.config.Threshold
+}
+
+func (w *watchdog[Event]) intervalOrDefault() time.Duration {
+	if w.config.Interval <= 0 {
+		return w.thresholdOrDefault() / 4
+	}
+
+	return w.config.Interval
+}
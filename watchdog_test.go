@@ -0,0 +1,242 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+)
+
+func TestWatchdog(t *testing.T) {
+	ensure := ensure.New(t)
+
+	const (
+		threshold = 20 * time.Millisecond
+		interval  = 5 * time.Millisecond
+		quiet     = 100 * time.Millisecond
+	)
+
+	ensure.Run("reports subscriptions whose events aren't received", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{Name: "slow"}, "key1", "key2")
+
+		stalls := make(chan *eventbus.Stall[string], 10)
+		stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+			Threshold: threshold,
+			Interval:  interval,
+			OnStall:   func(stall *eventbus.Stall[string]) { stalls <- stall },
+		})
+		defer stop()
+
+		bus.Publish("1", "key1")
+
+		stall := receiveStall(ensure, stalls)
+		ensure(stall.Subscription == sub).IsTrue()
+		ensure(stall.Subscription.ID()).Equals(uint64(1))
+		ensure(stall.Subscription.Name()).Equals("slow")
+		ensure(stall.Topics).Equals([]string{"key1", "key2"})
+		ensure(stall.QueueDepth).Equals(1)
+		ensure(stall.Duration >= threshold).IsTrue()
+
+		// The subscription is only reported once while it is stalled, and isn't disconnected
+		ensureNoStall(ensure, stalls, quiet)
+		ensure(sub.Err()).IsNotError()
+		ensure(<-sub.Channel()).Equals("1")
+	})
+
+	ensure.Run("doesn't report subscriptions that are idle or receiving", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: 100})
+		bus.Subscribe("idle")
+		receiving := bus.Subscribe("receiving")
+
+		stalls := make(chan *eventbus.Stall[string], 10)
+		stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+			Threshold: threshold,
+			Interval:  interval,
+			OnStall:   func(stall *eventbus.Stall[string]) { stalls <- stall },
+		})
+		defer stop()
+
+		// An event is always waiting, but events keep being received
+		bus.Publish("event", "receiving")
+		for i := 0; i < 50; i++ {
+			bus.Publish("event", "receiving")
+			<-receiving.Channel()
+			time.Sleep(time.Millisecond)
+		}
+		<-receiving.Channel()
+
+		ensureNoStall(ensure, stalls, quiet)
+	})
+
+	ensure.Run("reports subscriptions again after they recover and stall again", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[string]()
+		sub := bus.Subscribe("key1")
+
+		stalls := make(chan *eventbus.Stall[string], 10)
+		stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+			Threshold: threshold,
+			Interval:  interval,
+			OnStall:   func(stall *eventbus.Stall[string]) { stalls <- stall },
+		})
+		defer stop()
+
+		bus.Publish("1", "key1")
+		receiveStall(ensure, stalls)
+		<-sub.Channel()
+
+		// Wait for a check to see that the subscription recovered
+		time.Sleep(4 * interval)
+
+		bus.Publish("2", "key1")
+		ensure(receiveStall(ensure, stalls).QueueDepth).Equals(1)
+	})
+
+	ensure.Run("disconnects full subscriptions that block publishing", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: 1})
+		sub := bus.Subscribe("key1")
+		other := bus.Subscribe("key1")
+
+		stalls := make(chan *eventbus.Stall[string], 10)
+		stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+			Threshold:  threshold,
+			Interval:   interval,
+			OnStall:    func(stall *eventbus.Stall[string]) { stalls <- stall },
+			Disconnect: true,
+		})
+		defer stop()
+
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+
+			bus.Publish("1", "key1")
+			bus.Publish("2", "key1") // Blocks until the stalled subscriptions are disconnected
+		}()
+
+		// Both subscriptions are full
+		stall1 := receiveStall(ensure, stalls)
+		stall2 := receiveStall(ensure, stalls)
+		ensure(stall1.Subscription != stall2.Subscription).IsTrue()
+		<-published
+
+		for _, s := range []*eventbus.Subscription[string]{sub, other} {
+			var stalledErr *eventbus.StalledError
+			ensure(errors.As(s.Err(), &stalledErr)).IsTrue()
+			ensure(stalledErr.QueueDepth).Equals(1)
+			ensure(stalledErr.Duration >= threshold).IsTrue()
+			ensure(s.Err()).IsError(eventbus.ErrSubscriptionStalled)
+
+			event, err := s.Receive(context.Background())
+			ensure(err).IsNotError()
+			ensure(event).Equals("1")
+
+			_, err = s.Receive(context.Background())
+			ensure(err).IsError(eventbus.ErrSubscriptionStalled)
+		}
+
+		ensure(bus.Topics()).Equals([]string{})
+	})
+
+	ensure.Run("disconnects subscriptions with no buffer that block publishing", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{BufferSize: -1})
+		sub := bus.Subscribe("key1")
+
+		stalls := make(chan *eventbus.Stall[string], 10)
+		stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+			Threshold:  threshold,
+			Interval:   interval,
+			OnStall:    func(stall *eventbus.Stall[string]) { stalls <- stall },
+			Disconnect: true,
+		})
+		defer stop()
+
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			bus.Publish("1", "key1")
+		}()
+
+		ensure(receiveStall(ensure, stalls).QueueDepth).Equals(0)
+		<-published
+
+		_, err := sub.Receive(context.Background())
+		ensure(err).IsError(eventbus.ErrSubscriptionStalled)
+	})
+
+	ensure.Run("logs stalled subscriptions", func(ensure ensurepkg.Ensure) {
+		logger := &recordingLogger{}
+		bus := eventbus.NewWithConfig[string](&eventbus.Config{Logger: logger})
+		bus.SubscribeWithConfig(&eventbus.SubscriptionConfig[string]{Name: "slow"}, "key1")
+		bus.Publish("1", "key1")
+		logger.reset()
+
+		stalls := make(chan *eventbus.Stall[string], 10)
+		stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+			Threshold: threshold,
+			Interval:  interval,
+			OnStall:   func(stall *eventbus.Stall[string]) { stalls <- stall },
+		})
+		defer stop()
+
+		stall := receiveStall(ensure, stalls)
+		ensure(logger.lines()).Equals([]string{
+			fmt.Sprintf(
+				"WARN eventbus: subscription stalled [subscription slow subscription_id 1 topics [key1] queue_depth 1 duration %v disconnect false]",
+				stall.Duration,
+			),
+		})
+	})
+
+	ensure.Run("stops once stopped or the bus is closed", func(ensure ensurepkg.Ensure) {
+		for _, useClose := range []bool{false, true} {
+			bus := eventbus.New[string]()
+			bus.Subscribe("key1")
+
+			stalls := make(chan *eventbus.Stall[string], 10)
+			stop := bus.StartWatchdog(&eventbus.WatchdogConfig[string]{
+				Threshold: threshold,
+				Interval:  interval,
+				OnStall:   func(stall *eventbus.Stall[string]) { stalls <- stall },
+			})
+
+			if useClose {
+				bus.Close()
+			} else {
+				stop()
+			}
+
+			stop() // Waits for the watchdog to stop, and has no effect when called again
+
+			bus.Publish("1", "key1")
+			ensureNoStall(ensure, stalls, quiet)
+		}
+	})
+}
+
+func receiveStall(ensure ensurepkg.Ensure, stalls <-chan *eventbus.Stall[string]) *eventbus.Stall[string] {
+	ensure.T().Helper()
+
+	select {
+	case stall := <-stalls:
+		return stall
+	case <-time.After(5 * time.Second):
+		ensure.Failf("Timed out waiting for a stall")
+		return nil
+	}
+}
+
+func ensureNoStall(ensure ensurepkg.Ensure, stalls <-chan *eventbus.Stall[string], wait time.Duration) {
+	ensure.T().Helper()
+
+	select {
+	case stall := <-stalls:
+		ensure.Failf("Unexpected stall of subscription %d", stall.Subscription.ID())
+	case <-time.After(wait):
+	}
+}
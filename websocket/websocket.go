@@ -0,0 +1,471 @@
+// Package websocket provides an http.Handler, which bridges a Broker, such as the one returned by EventBus.Broker, to WebSocket clients.
+//
+// Each connection is mapped onto a subscription to the client's topics, which are changed by the frames the client sends.
+// Clients can subscribe to topics, unsubscribe from topics, and publish events over the same connection,
+// and they receive the events published to all of their topics.
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/internal/wsproto"
+)
+
+const (
+	// DefaultMaxMessageSize of messages sent by clients, in bytes. Used when the MaxMessageSize is not configured.
+	DefaultMaxMessageSize = 1 << 20
+
+	// DefaultPingInterval between pings. Used when the PingInterval is not configured.
+	DefaultPingInterval = 30 * time.Second
+
+	// DefaultWriteTimeout for sending each message to a client. Used when the WriteTimeout is not configured.
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// FrameType identifies what a Frame does.
+type FrameType string
+
+const (
+	// FrameSubscribe is sent by clients to subscribe to the frame's topics.
+	// Events published while the client's topics are changing may not be delivered to the client,
+	// but they are never delivered more than once.
+	FrameSubscribe FrameType = "subscribe"
+
+	// FrameUnsubscribe is sent by clients to unsubscribe from the frame's topics.
+	FrameUnsubscribe FrameType = "unsubscribe"
+
+	// FramePublish is sent by clients to publish the frame's event to the frame's topics.
+	FramePublish FrameType = "publish"
+
+	// FrameTopics is sent to clients after they subscribe or unsubscribe, listing all of the topics they are subscribed to.
+	FrameTopics FrameType = "topics"
+
+	// FrameEvent is sent to clients with an event published to at least one of their topics.
+	FrameEvent FrameType = "event"
+
+	// FrameError is sent to clients when one of their frames could not be handled.
+	FrameError FrameType = "error"
+)
+
+// Frame is sent over the connection in both directions, encoded as a JSON text message.
+type Frame struct {
+	Type   FrameType       `json:"type"`
+	Topics []string        `json:"topics,omitempty"`
+	Event  json.RawMessage `json:"event,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Config can be passed to NewHandler to customize the Handler.
+type Config[Event any] struct {
+	// Topics returns the keys of the topics each connection is initially subscribed to.
+	// If not set, connections start without any topics.
+	Topics func(r *http.Request) []string
+
+	// CheckOrigin checks whether the request's Origin header is allowed to connect.
+	// Browsers send cookies with WebSocket handshakes from any website, so other websites must be rejected
+	// to prevent them from using the visitor's session (cross-site WebSocket hijacking).
+	// Rejected requests fail with a 403 Forbidden.
+	// If not set, only requests without an Origin header, or with an Origin whose host matches the request's Host, are allowed.
+	CheckOrigin func(r *http.Request) bool
+
+	// AuthorizeSubscribe checks whether the client may subscribe to the topics.
+	// If it returns an error, the client is not subscribed, and the error's message is sent to the client.
+	// If not set, clients may subscribe to any topics.
+	AuthorizeSubscribe func(r *http.Request, topicKeys []string) error
+
+	// AuthorizePublish checks whether the client may publish the event to the topics.
+	// If it returns an error, the event is not published, and the error's message is sent to the client.
+	// If not set, clients may publish any events to any topics.
+	AuthorizePublish func(r *http.Request, event Event, topicKeys []string) error
+
+	// SubscriptionConfig customizes the subscriptions created for each connection,
+	// if the broker is an eventbus.ConfigurableSubscriber, such as the Broker returned by EventBus.Broker.
+	// DisconnectOnOverflow is always enabled, so clients that don't keep up with their events are disconnected,
+	// instead of blocking the broker's publishers, including the clients publishing to their own topics.
+	// If not set, the broker's default subscription configuration is used.
+	SubscriptionConfig *eventbus.SubscriptionConfig[Event]
+
+	// MaxMessageSize is the maximum size of messages sent by clients, in bytes.
+	// Clients sending larger messages are disconnected.
+	// If not set or zero, it defaults to DefaultMaxMessageSize.
+	// If negative, the size is not limited, but memory is only allocated as the message's data is received.
+	MaxMessageSize int64
+
+	// PingInterval is how often clients are pinged to keep idle connections open.
+	// If not set or zero, it defaults to DefaultPingInterval.
+	// If negative, clients are not pinged.
+	PingInterval time.Duration
+
+	// WriteTimeout limits how long sending each message to a client can take, after which the client is disconnected.
+	// If not set or zero, it defaults to DefaultWriteTimeout.
+	// If negative, sending can take any amount of time.
+	WriteTimeout time.Duration
+
+	// OnError is called when an event can't be encoded, which skips the event.
+	// If not set, errors are ignored.
+	OnError func(r *http.Request, err error)
+}
+
+// Handler bridges WebSocket clients to the broker.
+// Events are encoded as JSON within each Frame.
+type Handler[Event any] struct {
+	broker eventbus.Broker[Event]
+	config *Config[Event]
+}
+
+// connection is a client's connection.
+// Subscriptions can't change their topics, so changing the client's topics replaces its subscription,
+// whose events are forwarded to the events channel, which is sent to the client.
+type connection[Event any] struct {
+	conn   *wsproto.Conn
+	events chan Event
+	done   chan struct{} // Closed once the connection is closed
+
+	mu        sync.Mutex
+	topics    []string
+	sub       eventbus.Receiver[Event] // Nil while the client has no topics
+	forwarded chan struct{}            // Closed once the subscription's events are forwarded
+	isClosed  bool
+}
+
+var _ http.Handler = &Handler[any]{}
+
+// NewHandler creates a new Handler bridging clients to the broker, such as the one returned by EventBus.Broker.
+func NewHandler[Event any](broker eventbus.Broker[Event], config *Config[Event]) *Handler[Event] {
+	return &Handler[Event]{
+		broker: broker,
+		config: config,
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection, and handles the client's frames until it disconnects.
+// The connection's subscription is unsubscribed once the client disconnects.
+func (h *Handler[Event]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	conn, err := wsproto.Upgrade(w, r)
+	if err != nil {
+		return // Upgrade already responded to the request
+	}
+
+	conn.SetReadLimit(h.maxMessageSizeOrDefault())
+	conn.SetWriteTimeout(h.writeTimeoutOrDefault())
+
+	c := &connection[Event]{
+		conn:   conn,
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+	defer c.close()
+
+	if h.config.Topics != nil {
+		h.changeTopics(c, func(topicKeys []string) []string { return addTopics(topicKeys, h.config.Topics(r)) })
+	}
+
+	go h.sendEvents(r, c)
+
+	for {
+		opcode, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		frame := &Frame{}
+		if opcode != wsproto.OpText {
+			h.sendError(conn, errors.New("frames must be sent as text messages"))
+			continue
+		}
+
+		if err := json.Unmarshal(data, frame); err != nil {
+			h.sendError(conn, fmt.Errorf("invalid frame: %w", err))
+			continue
+		}
+
+		if err := h.handleFrame(r, c, frame); err != nil {
+			h.sendError(conn, err)
+		}
+	}
+}
+
+func (h *Handler[Event]) subscribe(topicKeys []string) eventbus.Receiver[Event] {
+	config := eventbus.SubscriptionConfig[Event]{}
+	if h.config.SubscriptionConfig != nil {
+		config = *h.config.SubscriptionConfig
+	}
+
+	config.DisconnectOnOverflow = true
+
+	return eventbus.SubscribeConfigured[Event](h.broker, &config, topicKeys...)
+}
+
+// changeTopics replaces the connection's subscription with one to the changed topics, and returns them.
+// Only the goroutine reading the client's frames changes the topics.
+func (h *Handler[Event]) changeTopics(c *connection[Event], change func(topicKeys []string) []string) []string {
+	c.mu.Lock()
+	topicKeys := change(c.topics)
+	c.topics = topicKeys
+	sub, forwarded := c.sub, c.forwarded
+	c.sub = nil
+	c.mu.Unlock()
+
+	if sub != nil {
+		sub.Unsubscribe()
+		<-forwarded // The previous subscription's events are sent first, so the events stay in order
+	}
+
+	if len(topicKeys) == 0 {
+		return topicKeys
+	}
+
+	sub = h.subscribe(topicKeys)
+	forwarded = make(chan struct{})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isClosed {
+		sub.Unsubscribe()
+		return topicKeys
+	}
+
+	c.sub, c.forwarded = sub, forwarded
+	go c.forward(sub, forwarded)
+
+	return topicKeys
+}
+
+func (h *Handler[Event]) handleFrame(r *http.Request, c *connection[Event], frame *Frame) error {
+	switch frame.Type {
+	case FrameSubscribe:
+		if len(frame.Topics) == 0 {
+			return errors.New("no topics to subscribe to")
+		}
+
+		if h.config.AuthorizeSubscribe != nil {
+			if err := h.config.AuthorizeSubscribe(r, frame.Topics); err != nil {
+				return err
+			}
+		}
+
+		topicKeys := h.changeTopics(c, func(topicKeys []string) []string { return addTopics(topicKeys, frame.Topics) })
+		return h.sendFrame(c.conn, &Frame{Type: FrameTopics, Topics: topicKeys})
+
+	case FrameUnsubscribe:
+		topicKeys := h.changeTopics(c, func(topicKeys []string) []string { return removeTopics(topicKeys, frame.Topics) })
+		return h.sendFrame(c.conn, &Frame{Type: FrameTopics, Topics: topicKeys})
+
+	case FramePublish:
+		if len(frame.Topics) == 0 {
+			return errors.New("no topics to publish to")
+		}
+
+		var event Event
+		if err := json.Unmarshal(frame.Event, &event); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+
+		if h.config.AuthorizePublish != nil {
+			if err := h.config.AuthorizePublish(r, event, frame.Topics); err != nil {
+				return err
+			}
+		}
+
+		h.broker.Publish(event, frame.Topics...)
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported frame type %q", frame.Type)
+	}
+}
+
+// sendEvents sends the connection's events to the client until the connection is closed.
+// Once it stops, such as when sending fails, the connection is closed, which unsubscribes it,
+// so it doesn't block publishing, and stops reading the client's frames.
+func (h *Handler[Event]) sendEvents(r *http.Request, c *connection[Event]) {
+	defer c.close()
+
+	var ping <-chan time.Time
+	if interval := h.pingIntervalOrDefault(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-c.events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.onError(r, fmt.Errorf("unable to encode event: %w", err))
+				continue
+			}
+
+			if err := h.sendFrame(c.conn, &Frame{Type: FrameEvent, Event: data}); err != nil {
+				return
+			}
+
+		case <-ping:
+			if err := c.conn.WriteMessage(wsproto.OpPing, nil); err != nil {
+				return
+			}
+
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// forward sends the subscription's events to the connection until the subscription is closed.
+// If the subscription is closed by the broker, such as when it overflows, the connection is closed.
+func (c *connection[Event]) forward(sub eventbus.Receiver[Event], forwarded chan struct{}) {
+	defer close(forwarded)
+
+	for event := range sub.Channel() {
+		select {
+		case c.events <- event:
+		case <-c.done:
+			return
+		}
+	}
+
+	c.mu.Lock()
+	isCurrent := c.sub == sub
+	c.mu.Unlock()
+
+	if isCurrent {
+		c.close()
+	}
+}
+
+// close unsubscribes the connection, and closes it.
+func (c *connection[Event]) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isClosed {
+		return
+	}
+
+	c.isClosed = true
+	close(c.done)
+
+	if c.sub != nil {
+		c.sub.Unsubscribe()
+		c.sub = nil
+	}
+
+	c.conn.Close()
+}
+
+func (h *Handler[Event]) sendFrame(conn *wsproto.Conn, frame *Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+
+	return conn.WriteMessage(wsproto.OpText, data)
+}
+
+func (h *Handler[Event]) sendError(conn *wsproto.Conn, err error) {
+	h.sendFrame(conn, &Frame{Type: FrameError, Error: err.Error()}) //nolint:errcheck // Reading notices broken connections
+}
+
+// addTopics returns the topics with the added topics that aren't already included.
+func addTopics(topicKeys, addedTopicKeys []string) []string {
+	newTopicKeys := append([]string{}, topicKeys...)
+	for _, topicKey := range addedTopicKeys {
+		if !contains(newTopicKeys, topicKey) {
+			newTopicKeys = append(newTopicKeys, topicKey)
+		}
+	}
+
+	return newTopicKeys
+}
+
+// removeTopics returns the topics that aren't removed.
+func removeTopics(topicKeys, removedTopicKeys []string) []string {
+	remainingTopicKeys := []string{}
+	for _, topicKey := range topicKeys {
+		if !contains(removedTopicKeys, topicKey) {
+			remainingTopicKeys = append(remainingTopicKeys, topicKey)
+		}
+	}
+
+	return remainingTopicKeys
+}
+
+func contains(topicKeys []string, topicKey string) bool {
+	for _, key := range topicKeys {
+		if key == topicKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Handler[Event]) checkOrigin(r *http.Request) bool {
+	if h.config.CheckOrigin != nil {
+		return h.config.CheckOrigin(r)
+	}
+
+	return isSameOrigin(r)
+}
+
+// isSameOrigin reports whether the request has no Origin header, or an Origin whose host matches the request's Host.
+// Clients other than browsers usually don't send an Origin header.
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func (h *Handler[Event]) onError(r *http.Request, err error) {
+	if h.config.OnError != nil {
+		h.config.OnError(r, err)
+	}
+}
+
+func (h *Handler[Event]) maxMessageSizeOrDefault() int64 {
+	if h.config.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+
+	return h.config.MaxMessageSize
+}
+
+func (h *Handler[Event]) pingIntervalOrDefault() time.Duration {
+	if h.config.PingInterval == 0 {
+		return DefaultPingInterval
+	}
+
+	return h.config.PingInterval
+}
+
+func (h *Handler[Event]) writeTimeoutOrDefault() time.Duration {
+	if h.config.WriteTimeout == 0 {
+		return DefaultWriteTimeout
+	}
+
+	return h.config.WriteTimeout
+}
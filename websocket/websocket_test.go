@@ -0,0 +1,330 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JosiahWitt/ensure"
+	"github.com/JosiahWitt/ensure/ensurepkg"
+	"github.com/JosiahWitt/eventbus"
+	"github.com/JosiahWitt/eventbus/eventbustest"
+	"github.com/JosiahWitt/eventbus/internal/wsproto"
+	"github.com/JosiahWitt/eventbus/websocket"
+)
+
+type Message struct {
+	ID   string `json:"id"`
+	Body string `json:"body"`
+}
+
+func TestHandler(t *testing.T) {
+	ensure := ensure.New(t)
+
+	ensure.Run("sends events for the topics the client subscribes to", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		send(ensure, client, `{"type":"subscribe","topics":["key1","key2"]}`)
+		ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameTopics, Topics: []string{"key1", "key2"}})
+
+		bus.Publish(&Message{ID: "1", Body: "hello"}, "key1", "key2")
+		bus.Publish(&Message{ID: "2", Body: "ignored"}, "key3")
+		bus.Publish(&Message{ID: "3", Body: "world"}, "key2")
+
+		ensure(next(ensure, client)).Equals(eventFrame(`{"id":"1","body":"hello"}`))
+		ensure(next(ensure, client)).Equals(eventFrame(`{"id":"3","body":"world"}`))
+	})
+
+	ensure.Run("subscribes to the initial topics", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{
+			Topics: func(r *http.Request) []string { return []string{r.URL.Query().Get("room")} },
+		}))
+		defer server.Close()
+
+		client := dial(ensure, server, "?room=lobby")
+		defer client.Close()
+
+		waitFor(func() bool { return bus.NumTopics() == 1 })
+		bus.Publish(&Message{ID: "1"}, "lobby")
+
+		ensure(next(ensure, client)).Equals(eventFrame(`{"id":"1","body":""}`))
+	})
+
+	ensure.Run("stops sending events for the topics the client unsubscribes from", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		send(ensure, client, `{"type":"subscribe","topics":["key1","key2"]}`)
+		ensure(next(ensure, client).Type).Equals(websocket.FrameTopics)
+
+		send(ensure, client, `{"type":"unsubscribe","topics":["key1"]}`)
+		ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameTopics, Topics: []string{"key2"}})
+		ensure(bus.Topics()).Equals([]string{"key2"})
+
+		bus.Publish(&Message{ID: "1"}, "key1")
+		bus.Publish(&Message{ID: "2"}, "key2")
+
+		ensure(next(ensure, client)).Equals(eventFrame(`{"id":"2","body":""}`))
+	})
+
+	ensure.Run("publishes the events the client sends", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		sub := bus.Subscribe("key1")
+		defer sub.Unsubscribe()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		send(ensure, client, `{"type":"subscribe","topics":["key2"]}`)
+		ensure(next(ensure, client).Type).Equals(websocket.FrameTopics)
+
+		send(ensure, client, `{"type":"publish","topics":["key1","key2"],"event":{"id":"1","body":"hello"}}`)
+
+		ensure(<-sub.Channel()).Equals(&Message{ID: "1", Body: "hello"})
+		ensure(next(ensure, client)).Equals(eventFrame(`{"id":"1","body":"hello"}`))
+	})
+
+	ensure.Run("bridges clients to brokers other than an EventBus", func(ensure ensurepkg.Ensure) {
+		broker := eventbustest.NewFakeBus[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](broker, &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		send(ensure, client, `{"type":"subscribe","topics":["key1"]}`)
+		ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameTopics, Topics: []string{"key1"}})
+
+		send(ensure, client, `{"type":"subscribe","topics":["key2"]}`)
+		ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameTopics, Topics: []string{"key1", "key2"}})
+
+		send(ensure, client, `{"type":"publish","topics":["key2"],"event":{"id":"1","body":"hello"}}`)
+		ensure(next(ensure, client)).Equals(eventFrame(`{"id":"1","body":"hello"}`))
+		broker.AssertPublished(ensure.T(), &Message{ID: "1", Body: "hello"}, "key2")
+
+		send(ensure, client, `{"type":"unsubscribe","topics":["key1","key2"]}`)
+		ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameTopics})
+		ensure(broker.Topics()).Equals([]string{})
+	})
+
+	ensure.Run("sends errors for frames that can't be handled", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{
+			AuthorizeSubscribe: func(r *http.Request, topicKeys []string) error {
+				for _, topicKey := range topicKeys {
+					if strings.HasPrefix(topicKey, "private:") {
+						return errors.New("not allowed to subscribe to private topics")
+					}
+				}
+
+				return nil
+			},
+			AuthorizePublish: func(r *http.Request, msg *Message, topicKeys []string) error {
+				if msg.Body == "" {
+					return errors.New("messages must have a body")
+				}
+
+				return nil
+			},
+		}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		table := []struct {
+			Frame         string
+			ExpectedError string
+		}{
+			{Frame: `{"type":`, ExpectedError: "invalid frame: unexpected end of JSON input"},
+			{Frame: `{"type":"shout"}`, ExpectedError: `unsupported frame type "shout"`},
+			{Frame: `{"type":"subscribe"}`, ExpectedError: "no topics to subscribe to"},
+			{Frame: `{"type":"subscribe","topics":["private:1"]}`, ExpectedError: "not allowed to subscribe to private topics"},
+			{Frame: `{"type":"publish","event":{"id":"1"}}`, ExpectedError: "no topics to publish to"},
+			{Frame: `{"type":"publish","topics":["key1"],"event":"1"}`, ExpectedError: "invalid event: json: cannot unmarshal string into Go value of type websocket_test.Message"},
+			{Frame: `{"type":"publish","topics":["key1"],"event":{"id":"1"}}`, ExpectedError: "messages must have a body"},
+		}
+
+		for _, entry := range table {
+			send(ensure, client, entry.Frame)
+			ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameError, Error: entry.ExpectedError})
+		}
+
+		ensure(client.WriteMessage(wsproto.OpBinary, []byte("{}"))).IsNotError()
+		ensure(next(ensure, client)).Equals(&websocket.Frame{Type: websocket.FrameError, Error: "frames must be sent as text messages"})
+
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("disconnects clients sending messages that are too large", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{MaxMessageSize: 10}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		send(ensure, client, `{"type":"subscribe","topics":["key1"]}`)
+
+		_, _, err := client.ReadMessage()
+		ensure(err).IsError(wsproto.ErrClosed)
+	})
+
+	ensure.Run("unsubscribes when the client disconnects", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+
+		send(ensure, client, `{"type":"subscribe","topics":["key1","key2"]}`)
+		ensure(next(ensure, client).Type).Equals(websocket.FrameTopics)
+		ensure(bus.NumTopics()).Equals(2)
+
+		client.Close()
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.NumTopics()).Equals(0)
+	})
+
+	ensure.Run("disconnects clients that don't read their events without blocking publishing", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.NewWithConfig[*Message](&eventbus.Config{BufferSize: 1})
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{
+			WriteTimeout: 200 * time.Millisecond,
+		}))
+		defer server.Close()
+
+		client := dial(ensure, server)
+		defer client.Close()
+
+		send(ensure, client, `{"type":"subscribe","topics":["room"]}`)
+		ensure(next(ensure, client).Type).Equals(websocket.FrameTopics)
+
+		// The client publishes to its own topic, but stops reading, so the events fill the connection's buffers
+		body := strings.Repeat("x", 64<<10)
+		frame := `{"type":"publish","topics":["room"],"event":{"id":"1","body":"` + body + `"}}`
+		for i := 0; i < 500; i++ {
+			if err := client.WriteMessage(wsproto.OpText, []byte(frame)); err != nil {
+				break // The client was disconnected
+			}
+		}
+
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			bus.Publish(&Message{ID: "2"}, "room")
+		}()
+
+		select {
+		case <-published:
+		case <-time.After(5 * time.Second):
+			ensure.Failf("Timed out waiting for publishing to the client's topic")
+		}
+
+		waitFor(func() bool { return bus.NumTopics() == 0 })
+		ensure(bus.Topics()).Equals([]string{})
+	})
+
+	ensure.Run("rejects cross-origin requests", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		_, err := dialWithOrigin(server, "https://attacker.example")
+		ensure(err.Error()).Equals("wsproto: handshake failed with status 403")
+		ensure(bus.NumTopics()).Equals(0)
+
+		_, err = dialWithOrigin(server, "://attacker.example")
+		ensure(err.Error()).Equals("wsproto: handshake failed with status 403")
+
+		client, err := dialWithOrigin(server, server.URL)
+		ensure(err).IsNotError()
+		client.Close()
+	})
+
+	ensure.Run("allows the origins accepted by CheckOrigin", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{
+			CheckOrigin: func(r *http.Request) bool { return r.Header.Get("Origin") == "https://app.example" },
+		}))
+		defer server.Close()
+
+		client, err := dialWithOrigin(server, "https://app.example")
+		ensure(err).IsNotError()
+		client.Close()
+
+		_, err = dialWithOrigin(server, server.URL)
+		ensure(err.Error()).Equals("wsproto: handshake failed with status 403")
+	})
+
+	ensure.Run("rejects requests that are not WebSocket handshakes", func(ensure ensurepkg.Ensure) {
+		bus := eventbus.New[*Message]()
+		server := httptest.NewServer(websocket.NewHandler[*Message](bus.Broker(), &websocket.Config[*Message]{}))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		ensure(err).IsNotError()
+		defer resp.Body.Close()
+
+		ensure(resp.StatusCode).Equals(http.StatusBadRequest)
+	})
+}
+
+func dial(ensure ensurepkg.Ensure, server *httptest.Server, query ...string) *wsproto.Conn {
+	ensure.T().Helper()
+
+	conn, err := wsproto.Dial("ws" + strings.TrimPrefix(server.URL, "http") + strings.Join(query, ""))
+	ensure(err).IsNotError()
+
+	return conn
+}
+
+func dialWithOrigin(server *httptest.Server, origin string) (*wsproto.Conn, error) {
+	return wsproto.DialWithHeader("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {origin}})
+}
+
+func send(ensure ensurepkg.Ensure, conn *wsproto.Conn, frame string) {
+	ensure.T().Helper()
+	ensure(conn.WriteMessage(wsproto.OpText, []byte(frame))).IsNotError()
+}
+
+func next(ensure ensurepkg.Ensure, conn *wsproto.Conn) *websocket.Frame {
+	ensure.T().Helper()
+
+	opcode, data, err := conn.ReadMessage()
+	ensure(err).IsNotError()
+	ensure(opcode).Equals(wsproto.OpText)
+
+	frame := &websocket.Frame{}
+	ensure(json.Unmarshal(data, frame)).IsNotError()
+
+	return frame
+}
+
+func eventFrame(event string) *websocket.Frame {
+	return &websocket.Frame{Type: websocket.FrameEvent, Event: json.RawMessage(event)}
+}
+
+func waitFor(condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}